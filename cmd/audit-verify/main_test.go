@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"fmt"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.AuditLog{}, &model.AuditHead{}); err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewRepository(&ds.DataSource{DB: db}, nil)
+
+	// an empty log is intact
+	if intact, err := verify(ctx, repo, 10); err != nil || !intact {
+		t.Fatalf("empty log: intact %v, %v", intact, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := repo.Audit.Record(ctx, &model.AuditLog{Action: fmt.Sprint("POST /api/", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if intact, err := verify(ctx, repo, 10); err != nil || !intact {
+		t.Fatalf("recorded log: intact %v, %v", intact, err)
+	}
+
+	if err := db.Exec("UPDATE audit_logs SET status = 500 WHERE seq = 1").Error; err != nil {
+		t.Fatal(err)
+	}
+	if intact, err := verify(ctx, repo, 10); err != nil || intact {
+		t.Fatalf("tampered log: intact %v, %v", intact, err)
+	}
+}
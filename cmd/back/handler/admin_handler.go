@@ -1,12 +1,14 @@
 package handler
 
 import (
+	"context"
 	"cryptoshare/dto"
 	"cryptoshare/middleware"
 	"cryptoshare/model"
 	"cryptoshare/repository"
 	"cryptoshare/utils"
-	"log"
+	"fmt"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/copier"
@@ -27,7 +29,8 @@ func newAdminHandler(h *Handler) *adminHandler {
 func (ctr *adminHandler) register() {
 
 	group := ctr.R.Group("/api/admins")
-	group.Use(middleware.AuthMiddleware(ctr.repo))
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaAdmins))
 
 	group.GET("", ctr.getAdmins)
 	group.POST("", ctr.createAdmin)
@@ -81,6 +84,7 @@ func (ctr *adminHandler) getAdmins(c *gin.Context) {
 	c.JSON(res.HttpStatusCode, res)
 }
 
+// createAdmin proposes the admin, it is created once other admins approve
 func (ctr *adminHandler) createAdmin(c *gin.Context) {
 	req := dto.AdminCreateReq{}
 	if err := c.ShouldBind(&req); err != nil {
@@ -89,54 +93,81 @@ func (ctr *adminHandler) createAdmin(c *gin.Context) {
 		return
 	}
 
-	admin := model.Admin{}
-	if err := copier.Copy(&admin, &req); err != nil {
-		res := utils.GenerateServerError(err)
-		c.JSON(res.HttpStatusCode, res)
-		return
+	if req.Password != nil {
+		hashedPassword, err := utils.HashPassword(*req.Password)
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+		req.Password = &hashedPassword
 	}
 
-	if err := ctr.repo.Admin.Create(c.Request.Context(), &admin); err != nil {
-		res := utils.GenerateGormErrorResponse(err)
-		c.JSON(res.HttpStatusCode, res)
-		return
-	}
-
-	res := utils.GenerateSuccessResponse(nil)
+	res := propose(c, ctr.repo, model.ProposalAdminCreate, fmt.Sprintf("admin:%s", *req.Username), req)
 	c.JSON(res.HttpStatusCode, res)
-
 }
 
+// updateAdmin applies the edit, a new password for another admin is
+// proposed and only set once other admins approve it
 func (ctr *adminHandler) updateAdmin(c *gin.Context) {
+	by := c.MustGet("admin").(*model.Admin)
 	req := dto.AdminEditReq{}
 	if err := c.ShouldBind(&req); err != nil {
 		res := utils.GenerateValidationErrorResponse(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
-	admin := model.Admin{}
-	if err := copier.Copy(&admin, &req); err != nil {
-		res := utils.GenerateServerError(err)
+
+	before, err := ctr.repo.Admin.FindByField("id", strconv.FormatUint(*req.ID, 10))
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
 
-	if admin.Password != nil {
-		hashedPassword, err := utils.HashPassword(*admin.Password)
-		log.Println(err)
-		admin.Password = &hashedPassword
+	if req.Password != nil {
+		hashedPassword, err := utils.HashPassword(*req.Password)
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+		req.Password = &hashedPassword
+
+		if *req.ID != *by.ID {
+			res := propose(c, ctr.repo, model.ProposalAdminUpdate, fmt.Sprintf("admin:%d", *req.ID), req)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
 	}
 
-	if err := ctr.repo.Admin.Update(c.Request.Context(), &admin); err != nil {
+	if err := updateAdmin(c.Request.Context(), ctr.repo, &req, before); err != nil {
 		res := utils.GenerateGormErrorResponse(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	middleware.Audit(c, fmt.Sprintf("admin:%d", *req.ID), before, req)
 
 	res := utils.GenerateSuccessResponse(nil)
 	c.JSON(res.HttpStatusCode, res)
 }
 
+// updateAdmin saves the edit, the password in req is already hashed. A reset
+// password signs the admin out everywhere.
+func updateAdmin(ctx context.Context, repo *repository.Repository, req *dto.AdminEditReq, before *model.Admin) error {
+	admin := model.Admin{}
+	if err := copier.Copy(&admin, req); err != nil {
+		return err
+	}
+	if err := repo.Admin.Update(ctx, &admin); err != nil {
+		return err
+	}
+	if admin.Password == nil {
+		return nil
+	}
+	return repo.Token.RevokeOtherSessions(ctx, *before.Username, true, "")
+}
+
 func (ctr *adminHandler) deleteAdmins(c *gin.Context) {
 	req := dto.ReqByIDs{}
 	if err := c.ShouldBind(&req); err != nil {
@@ -148,10 +179,11 @@ func (ctr *adminHandler) deleteAdmins(c *gin.Context) {
 	ids := utils.IdsIntToInCon(req.IDS)
 
 	if err := ctr.repo.Admin.DeleteMany(c.Request.Context(), ids); err != nil {
-		res := utils.GenerateGormErrorResponse(err)
+		res := roleErrorResponse(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	middleware.Audit(c, fmt.Sprintf("admins:%s", ids), nil, req)
 
 	res := utils.GenerateSuccessResponse(nil)
 	c.JSON(res.HttpStatusCode, res)
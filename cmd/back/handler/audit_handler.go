@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type auditHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+}
+
+func newAuditHandler(h *Handler) *auditHandler {
+	return &auditHandler{
+		R:    h.R,
+		repo: h.repo,
+	}
+}
+
+func (ctr *auditHandler) register() {
+	group := ctr.R.Group("/api/audit")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaAudit))
+	group.GET("", ctr.getLogs)
+}
+
+func (ctr *auditHandler) getLogs(c *gin.Context) {
+	req := dto.AuditListReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list, total, err := ctr.repo.Audit.List(c.Request.Context(), &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := gin.H{
+		"list":  list,
+		"total": total,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
@@ -1,16 +1,18 @@
 package handler
 
 import (
+	"cryptoshare/conf"
 	"cryptoshare/dto"
 	"cryptoshare/middleware"
 	"cryptoshare/model"
 	"cryptoshare/repository"
+	"cryptoshare/service"
 	"cryptoshare/utils"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,25 +20,43 @@ import (
 type authHandler struct {
 	R    *gin.Engine
 	repo *repository.Repository
+	svc  *service.Service
 }
 
 func newAuthHandler(h *Handler) *authHandler {
 	return &authHandler{
 		R:    h.R,
 		repo: h.repo,
+		svc:  h.svc,
 	}
 }
 
 func (ctr *authHandler) register() {
 	group := ctr.R.Group("/api/auth")
-	group.POST("/login", ctr.login)
-	group.Use(middleware.AuthMiddleware(ctr.repo))
-
+	group.POST("/login", middleware.GeoMiddleware(ctr.repo, middleware.GeoPolicyLogin), ctr.login)
 	group.POST("/refresh", ctr.refresh)
-	group.POST("/logout", ctr.logout)
+	group.POST("/logout", middleware.SessionMiddleware(), ctr.logout)
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+
+	group.GET("/2fa", ctr.getTwoFactor)
 	group.POST("/generate/secret-key", ctr.generateSecretKey)
-	group.POST("/enable/2fa", middleware.OTPMiddleware("admin"), ctr.enable2FactorAuth)
+	group.POST("/enable/2fa", ctr.enable2FactorAuth)
+	group.POST("/disable/2fa", ctr.disable2FactorAuth)
+	group.POST("/2fa/recovery-codes", ctr.resetRecoveryCodes)
+	group.GET("/webauthn/credentials", ctr.getPasskeys)
+	group.POST("/webauthn/register/begin", middleware.AdminFactorMiddleware(ctr.repo), ctr.beginPasskeyRegistration)
+	group.POST("/webauthn/register/finish", ctr.finishPasskeyRegistration)
+	group.DELETE("/webauthn/credentials/:id", middleware.PasskeyMiddleware(ctr.repo), ctr.deletePasskey)
+	group.PUT("/webauthn/required", middleware.PasskeyMiddleware(ctr.repo), ctr.setPasskeyRequired)
+	group.POST("/password", ctr.changePassword)
+	group.GET("/sessions", ctr.getSessions)
+	group.DELETE("/sessions", ctr.revokeOtherSessions)
+	group.DELETE("/sessions/:id", ctr.revokeSession)
 }
+
+// login answers every failure the same way, whether the admin exists or
+// not. Failures are counted per account and IP, each one waits a little
+// longer and too many lock the account for a while.
 func (ctr *authHandler) login(c *gin.Context) {
 	req := dto.LoginReq{}
 	res := &dto.Response{}
@@ -45,102 +65,181 @@ func (ctr *authHandler) login(c *gin.Context) {
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	ctx := c.Request.Context()
+
+	account := repository.LoginAccount(model.AuditActorAdmin, req.Email)
 	admin, err := ctr.repo.Admin.FindOrByField("email", "username", req.Email)
-	if err != nil {
-		res := utils.GenerateGormErrorResponse(err)
+	if err != nil && !utils.IsErrNotFound(err) {
+		res := utils.GenerateServerError(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	if err == nil {
+		account = repository.LoginAccount(model.AuditActorAdmin, fmt.Sprint(*admin.ID))
+	} else {
+		admin = nil
+	}
 
-	// otp validation
-	if *admin.OTPEnabled && req.OTP == "" {
-		res.ErrCode = 400
-		res.ErrMsg = "OTP is required."
-		c.JSON(http.StatusBadRequest, res)
+	if res := middleware.LoginLocked(c, ctr.repo, account); res != nil {
+		c.JSON(res.HttpStatusCode, res)
 		return
 	}
 
-	if *admin.OTPEnabled {
-		validOTP := utils.Validate2fa(req.OTP, *admin.OTPSecret)
-		if !validOTP {
+	var valid bool
+	if admin == nil {
+		valid = utils.CheckNoPassword(req.Password)
+	} else {
+		valid = utils.CheckPasswordHash(req.Password, *admin.Password)
+	}
+
+	// second factor, only asked for once the password is right. Admins that
+	// require a passkey get its challenge, the others give an otp or a
+	// recovery code.
+	if valid && admin.RequiresPasskey() {
+		if req.ChallengeID == "" || len(req.Assertion) == 0 {
+			res := middleware.CheckPasskey(c, ctr.repo, admin, &req.PasskeyReq)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+		_, err := ctr.repo.WebAuthn.FinishAssertion(ctx, admin, req.ChallengeID, req.Assertion)
+		switch {
+		case err == nil:
+		case errors.Is(err, repository.ErrInvalidChallenge), errors.Is(err, repository.ErrInvalidPasskey),
+			errors.Is(err, repository.ErrPasskeyClone):
+			valid = false
+		default:
+			res := middleware.PasskeyErrorResponse(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+	} else if valid && *admin.OTPEnabled {
+		if req.OTP == "" {
 			res.ErrCode = 400
-			res.ErrMsg = "invalid otp"
+			res.ErrMsg = "OTP is required."
 			c.JSON(http.StatusBadRequest, res)
 			return
 		}
+		valid, err = ctr.repo.Recovery.Check(ctx, model.AuditActorAdmin, fmt.Sprint(*admin.ID), *admin.OTPSecret, req.OTP)
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
 	}
 
-	validPassword := utils.CheckPasswordHash(req.Password, *admin.Password)
-	if !validPassword {
-		res.ErrCode = 400
-		res.ErrMsg = "invalid password"
-		c.JSON(http.StatusBadRequest, res)
+	if !valid {
+		locked := middleware.LoginFailed(c, ctr.repo, account)
+		if locked && admin != nil {
+			ctr.mailLockout(admin)
+		}
+		res := utils.GenerateInvalidCredentialsResponse()
+		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	if err := ctr.repo.Lockout.Succeed(ctx, account); err != nil {
+		log.Println(err, "Error clearing login failures ", account)
+	}
+	middleware.AuditActor(c, model.AuditActorAdmin, fmt.Sprint(*admin.ID), *admin.Username)
 
-	accessToken, err := utils.GenerateAccessToken(*admin.Username, true)
+	tokens, err := ctr.repo.Token.Issue(c.Request.Context(), middleware.NewSession(c, *admin.Username, true))
 	if err != nil {
 		res := utils.GenerateServerError(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
-	// Finally, we set the client cookie for "token" as the JWT we just generated
-	// we also set an expiry time which is the same as the token itself
-	fmt.Println(c.Request.Host, "DSds")
-	c.SetCookie("token", accessToken, int(time.Minute)*24, "/", c.Request.Host, true, true)
+	middleware.SetTokenCookies(c, tokens)
 
-	res = utils.GenerateSuccessResponse(accessToken)
+	res = utils.GenerateSuccessResponse(tokens)
 	c.JSON(res.HttpStatusCode, res)
+}
 
+// mailLockout is best effort, the lock is already in place
+func (ctr *authHandler) mailLockout(admin *model.Admin) {
+	subject := "Your admin account was locked"
+	body := fmt.Sprintf("Hello %s,\n\nThere were too many failed sign-in attempts on your admin account, so it is locked for %s. "+
+		"If this wasn't you, tell another admin right away.\n", *admin.Name, conf.LoginLockout)
+	if err := ctr.svc.Mail.Send(*admin.Email, subject, body); err != nil {
+		log.Println(err, "Error sending mail to ", *admin.Email)
+	}
 }
 
 func (ctr *authHandler) refresh(c *gin.Context) {
-
-	tokens := strings.Split(c.GetHeader("Authorization"), "Bearer ")
-
-	refreshToken, err := utils.GenerateRefreshToken(tokens[1])
+	tokens, err := ctr.repo.Token.Refresh(c.Request.Context(), middleware.RefreshTokenFrom(c))
 	if err != nil {
-		log.Println(err.Error())
-		if strings.Contains(err.Error(), "not expired") {
-			res := utils.GenerateSuccessResponse(tokens[1])
+		if errors.Is(err, repository.ErrInvalidRefreshToken) || errors.Is(err, repository.ErrRefreshTokenReused) {
+			middleware.ClearTokenCookies(c)
+			res := utils.GenerateAuthErrorResponse(err)
+			res.ErrMsg = err.Error()
 			c.JSON(res.HttpStatusCode, res)
 			return
 		}
 		res := utils.GenerateServerError(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
-
 	}
-	c.SetCookie("token", refreshToken, int(time.Minute)*24, "/", c.Request.Host, true, true)
+	middleware.SetTokenCookies(c, tokens)
 
-	res := utils.GenerateSuccessResponse(refreshToken)
+	res := utils.GenerateSuccessResponse(tokens)
 	c.JSON(res.HttpStatusCode, res)
-
 }
 
+// logout ends the session of the refresh token, or of the access token when
+// the client only has that one
 func (ctr *authHandler) logout(c *gin.Context) {
-	// immediately clear the token cookie
-	c.SetCookie("token", "", 0, "/", c.Request.Host, true, true)
+	var err error
+	if raw := middleware.RefreshTokenFrom(c); raw != "" {
+		err = ctr.repo.Token.Revoke(c.Request.Context(), raw)
+	} else if session := c.GetString("session_id"); session != "" {
+		err = ctr.repo.Token.RevokeSession(c.Request.Context(), session)
+	}
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	// immediately clear the token cookies
+	middleware.ClearTokenCookies(c)
 
 	res := utils.GenerateSuccessResponse("successfully logged out")
 	c.JSON(res.HttpStatusCode, res)
 }
 
+func (ctr *authHandler) getTwoFactor(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	left, err := ctr.repo.Recovery.Remaining(c.Request.Context(), model.AuditActorAdmin, fmt.Sprint(*admin.ID))
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(&dto.TwoFactorStatusResp{
+		State:             admin.TwoFactorState(),
+		RecoveryCodesLeft: left,
+	})
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// generateSecretKey starts a 2fa setup, the secret stays pending until
+// enable2FactorAuth confirms it. An enabled 2fa must be disabled first.
 func (ctr *authHandler) generateSecretKey(c *gin.Context) {
 	admin := c.MustGet("admin").(*model.Admin)
+	if *admin.OTPEnabled {
+		res := utils.GenerateConflictResponse(errors.New("2fa is already enabled"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
 	key, err := utils.Create2fa(*admin.Username)
 	if err != nil {
 		res := utils.GenerateServerError(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
-
 	updateFields := &model.UpdateFields{
 		Field: "id",
 		Value: admin.ID,
 		Data: map[string]any{
-			"otp_secret":   key.Secret(),
-			"otp_auth_url": key.URL(),
+			"otp_pending_secret": key.Secret(),
 		},
 	}
 	_, err = ctr.repo.Admin.UpdateByFields(c.Request.Context(), updateFields)
@@ -149,22 +248,76 @@ func (ctr *authHandler) generateSecretKey(c *gin.Context) {
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
-	data := gin.H{
-		"otp_secret":   key.Secret(),
-		"otp_auth_url": key.URL(),
-	}
-	res := utils.GenerateSuccessResponse(data)
+
+	res := utils.GenerateSuccessResponse(&dto.TwoFactorSetupResp{
+		OTPSecret:  key.Secret(),
+		OTPAuthURL: key.URL(),
+	})
 	c.JSON(res.HttpStatusCode, res)
 }
 
+// enable2FactorAuth confirms the pending setup with its first code and
+// returns the recovery codes, they are not shown again
 func (ctr *authHandler) enable2FactorAuth(c *gin.Context) {
 	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.OTPReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if admin.TwoFactorState() != model.TwoFactorPending {
+		res := utils.GenerateConflictResponse(fmt.Errorf("2fa is %s, there is no setup to confirm", admin.TwoFactorState()))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !utils.Validate2fa(req.OTP, *admin.OTPPendingSecret) {
+		res := utils.GenerateWrongOTPResponse(nil)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	updateFields := &model.UpdateFields{
+		Field: "id",
+		Value: admin.ID,
+		Data: map[string]any{
+			"otp_enabled":        true,
+			"otp_secret":         *admin.OTPPendingSecret,
+			"otp_pending_secret": nil,
+		},
+	}
+	if _, err := ctr.repo.Admin.UpdateByFields(c.Request.Context(), updateFields); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	codes, err := ctr.repo.Recovery.Replace(c.Request.Context(), model.AuditActorAdmin, fmt.Sprint(*admin.ID))
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(&dto.RecoveryCodesResp{RecoveryCodes: codes})
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// disable2FactorAuth turns 2fa off given the password and a code, the other
+// sessions are signed out as the account just lost a factor
+func (ctr *authHandler) disable2FactorAuth(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	if res := ctr.checkBothFactors(c, admin); res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
 
 	updateFields := &model.UpdateFields{
 		Field: "id",
 		Value: admin.ID,
 		Data: map[string]any{
-			"otp_enabled": true,
+			"otp_enabled":        false,
+			"otp_secret":         nil,
+			"otp_pending_secret": nil,
 		},
 	}
 	_, err := ctr.repo.Admin.UpdateByFields(c.Request.Context(), updateFields)
@@ -173,6 +326,270 @@ func (ctr *authHandler) enable2FactorAuth(c *gin.Context) {
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	if err := ctr.repo.Recovery.Delete(c.Request.Context(), model.AuditActorAdmin, fmt.Sprint(*admin.ID)); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Token.RevokeOtherSessions(c.Request.Context(), *admin.Username, true, c.GetString("session_id")); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// resetRecoveryCodes replaces the recovery codes given the password and a
+// code, the old ones stop working
+func (ctr *authHandler) resetRecoveryCodes(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	if res := ctr.checkBothFactors(c, admin); res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	codes, err := ctr.repo.Recovery.Replace(c.Request.Context(), model.AuditActorAdmin, fmt.Sprint(*admin.ID))
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(&dto.RecoveryCodesResp{RecoveryCodes: codes})
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// checkBothFactors binds a TwoFactorReq and checks it against the enabled
+// 2fa, the code may be a recovery code
+func (ctr *authHandler) checkBothFactors(c *gin.Context, admin *model.Admin) *dto.Response {
+	req := dto.TwoFactorReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		return utils.GenerateValidationErrorResponse(err)
+	}
+	if !*admin.OTPEnabled || admin.OTPSecret == nil {
+		return utils.GenerateConflictResponse(errors.New("2fa is not enabled"))
+	}
+	if !utils.CheckPasswordHash(req.Password, *admin.Password) {
+		return utils.GenerateBadRequestErrorResponse(errors.New("invalid password"))
+	}
+	valid, err := ctr.repo.Recovery.Check(c.Request.Context(), model.AuditActorAdmin, fmt.Sprint(*admin.ID), *admin.OTPSecret, req.OTP)
+	if err != nil {
+		return utils.GenerateServerError(err)
+	}
+	if !valid {
+		return utils.GenerateWrongOTPResponse(nil)
+	}
+	return nil
+}
+
+func (ctr *authHandler) getPasskeys(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	list, err := ctr.repo.WebAuthn.Credentials(c.Request.Context(), *admin.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(list)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// beginPasskeyRegistration starts adding a passkey given the password and the
+// current second factor, one of the passkeys when the admin requires them and
+// the totp code otherwise
+func (ctr *authHandler) beginPasskeyRegistration(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.PasskeyBeginReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !utils.CheckPasswordHash(req.Password, *admin.Password) {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("invalid password"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	id, options, err := ctr.repo.WebAuthn.BeginRegistration(c.Request.Context(), admin)
+	if err != nil {
+		res := middleware.PasskeyErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(&dto.PasskeyChallengeResp{ChallengeID: id, Options: options})
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// finishPasskeyRegistration keeps the passkey created for the challenge
+func (ctr *authHandler) finishPasskeyRegistration(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.PasskeyRegisterReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	credential, err := ctr.repo.WebAuthn.FinishRegistration(c.Request.Context(), admin, req.ChallengeID, req.Name, req.Credential)
+	if err != nil {
+		res := middleware.PasskeyErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	middleware.Audit(c, fmt.Sprintf("admin:%d", *admin.ID), nil, credential)
+
+	res := utils.GenerateSuccessResponse(credential)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// deletePasskey removes a passkey, the request is signed by any of them
+func (ctr *authHandler) deletePasskey(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	if err := ctr.repo.WebAuthn.DeleteCredential(c.Request.Context(), admin, uint(id)); err != nil {
+		res := middleware.PasskeyErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	middleware.Audit(c, fmt.Sprintf("admin:%d", *admin.ID), gin.H{"passkey": id}, nil)
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// setPasskeyRequired turns passkeys on or off for logins and sensitive
+// actions, the request is signed by a passkey so one is known to work.
+// Turning them off falls back to the totp code, so it has to be enabled.
+func (ctr *authHandler) setPasskeyRequired(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.PasskeyRequiredReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !*req.Required && (admin.OTPEnabled == nil || !*admin.OTPEnabled) {
+		res := utils.GenerateConflictResponse(errors.New("enable 2fa before turning passkeys off"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	updateFields := &model.UpdateFields{
+		Field: "id",
+		Value: admin.ID,
+		Data: map[string]any{
+			"passkey_required": *req.Required,
+		},
+	}
+	if _, err := ctr.repo.Admin.UpdateByFields(c.Request.Context(), updateFields); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	middleware.Audit(c, fmt.Sprintf("admin:%d", *admin.ID),
+		gin.H{"passkey_required": admin.RequiresPasskey()}, gin.H{"passkey_required": *req.Required})
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// changePassword sets a new password and signs out every other session
+func (ctr *authHandler) changePassword(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.ChangePasswordReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !utils.CheckPasswordHash(req.CurrentPassword, *admin.Password) {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("invalid password"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	hash, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	updateFields := &model.UpdateFields{
+		Field: "id",
+		Value: admin.ID,
+		Data: map[string]any{
+			"password": hash,
+		},
+	}
+	if _, err := ctr.repo.Admin.UpdateByFields(c.Request.Context(), updateFields); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Token.RevokeOtherSessions(c.Request.Context(), *admin.Username, true, c.GetString("session_id")); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *authHandler) getSessions(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	list, err := ctr.repo.Token.ListSessions(c.Request.Context(), *admin.Username, true)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	middleware.MarkCurrentSession(c, list)
+
+	res := utils.GenerateSuccessResponse(list)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *authHandler) revokeSession(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	session, err := ctr.repo.Token.FindSession(c.Request.Context(), c.Param("id"), *admin.Username, true)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Token.RevokeSession(c.Request.Context(), session.ID); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if session.ID == c.GetString("session_id") {
+		middleware.ClearTokenCookies(c)
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// revokeOtherSessions signs out everywhere but the current session
+func (ctr *authHandler) revokeOtherSessions(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	if err := ctr.repo.Token.RevokeOtherSessions(c.Request.Context(), *admin.Username, true, c.GetString("session_id")); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
 
 	res := utils.GenerateSuccessResponse(nil)
 	c.JSON(res.HttpStatusCode, res)
@@ -7,7 +7,6 @@ import (
 	"cryptoshare/repository"
 	"cryptoshare/utils"
 	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -28,11 +27,12 @@ func newBankHandler(h *Handler) *bankHandler {
 
 func (ctr *bankHandler) register() {
 	group := ctr.R.Group("/api/banks")
-	group.Use(middleware.AuthMiddleware(ctr.repo))
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaBanks))
 	group.GET("", ctr.getBanks)
 
 	// group.Use(middleware.OTPMiddleware("admin"))
-	group.POST("", ctr.addBank)
+	group.POST("", middleware.IdempotencyMiddleware(ctr.repo), ctr.addBank)
 	group.PATCH("", ctr.editBank)
 	group.DELETE("", ctr.deleteBanks)
 }
@@ -66,6 +66,7 @@ func (ctr *bankHandler) getBanks(c *gin.Context) {
 
 }
 
+// addBank proposes the bank, it is added once other admins approve
 func (ctr *bankHandler) addBank(c *gin.Context) {
 	req := dto.CreateBankReq{}
 	if err := c.ShouldBind(&req); err != nil {
@@ -90,45 +91,51 @@ func (ctr *bankHandler) addBank(c *gin.Context) {
 
 	bank.PrivateKey = &encrytedPrivateKey
 
-	scanRecord := ctr.repo.Bank.GetAddressScanRecord(*bank.AddressType, *bank.WalletAddress)
-	bank.ScanRecord = &scanRecord
-
-	if err := ctr.repo.Bank.Create(c.Request.Context(), &bank); err != nil {
-		res := utils.GenerateGormErrorResponse(err)
-		c.JSON(res.HttpStatusCode, res)
-		return
-	}
-	res := &dto.Response{
-		ErrCode: 0,
-		ErrMsg:  "Success",
-	}
-	c.JSON(http.StatusOK, res)
+	res := propose(c, ctr.repo, model.ProposalBankCreate, fmt.Sprintf("bank:%s", *bank.WalletAddress), bank)
+	c.JSON(res.HttpStatusCode, res)
 }
 
 func (ctr *bankHandler) editBank(c *gin.Context) {
 	req := dto.UpdateBankReq{}
-	fmt.Println("DSSDS")
 	if err := c.ShouldBind(&req); err != nil {
-		fmt.Println("DSSDS<", err.Error())
 		res := utils.GenerateValidationErrorResponse(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
-	log.Println("<>?????")
 	bank := model.Bank{}
-	log.Printf("%+v\n", req)
-
-	log.Printf("%+v\n", bank)
 	if err := copier.Copy(&bank, &req); err != nil {
 		res := utils.GenerateServerError(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	before, err := ctr.repo.Bank.FindByID(req.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	// a new private key needs other admins to approve it
+	if req.PrivateKey != nil {
+		encrytedPrivateKey, err := utils.EncryptAES(*req.PrivateKey)
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+		req.PrivateKey = &encrytedPrivateKey
+
+		res := propose(c, ctr.repo, model.ProposalBankUpdate, fmt.Sprintf("bank:%d", req.ID), req)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
 	if err := ctr.repo.Bank.Update(c.Request.Context(), &bank); err != nil {
 		res := utils.GenerateGormErrorResponse(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	middleware.Audit(c, fmt.Sprintf("bank:%d", req.ID), before, req)
 	res := &dto.Response{
 		ErrCode: 0,
 		ErrMsg:  "Success",
@@ -152,6 +159,7 @@ func (ctr *bankHandler) deleteBanks(c *gin.Context) {
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	middleware.Audit(c, fmt.Sprintf("banks:%s", ids), nil, req)
 	res := &dto.Response{
 		ErrCode: 0,
 		ErrMsg:  "Success",
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type geoHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+}
+
+func newGeoHandler(h *Handler) *geoHandler {
+	return &geoHandler{
+		R:    h.R,
+		repo: h.repo,
+	}
+}
+
+func (ctr *geoHandler) register() {
+	group := ctr.R.Group("/api/geo")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaGeo))
+	group.GET("", ctr.getPolicies)
+	group.GET("/allowlist", ctr.getAllowlist)
+	group.POST("/allowlist", ctr.allow)
+	group.DELETE("/allowlist/:id", ctr.disallow)
+}
+
+// getPolicies shows the configured country lists, they are set through env
+func (ctr *geoHandler) getPolicies(c *gin.Context) {
+	data := gin.H{
+		"policies":      conf.GeoPolicies,
+		"block_unknown": conf.GeoBlockUnknown,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *geoHandler) getAllowlist(c *gin.Context) {
+	list, err := ctr.repo.Geo.ListAllowed(c.Request.Context())
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(list)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *geoHandler) allow(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.GeoAllowIPReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	entry := &model.GeoAllowIP{
+		CIDR:      req.CIDR,
+		Note:      req.Note,
+		CreatedBy: *admin.ID,
+	}
+	if err := ctr.repo.Geo.Allow(c.Request.Context(), entry); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(entry)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *geoHandler) disallow(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	if err := ctr.repo.Geo.Disallow(c.Request.Context(), id); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
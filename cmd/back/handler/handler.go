@@ -3,6 +3,7 @@ package handler
 import (
 	"cryptoshare/ds"
 	"cryptoshare/middleware"
+	"cryptoshare/payment"
 	"cryptoshare/repository"
 	"cryptoshare/service"
 
@@ -12,6 +13,8 @@ import (
 type Handler struct {
 	R    *gin.Engine
 	repo *repository.Repository
+	svc  *service.Service
+	pay  *payment.Payments
 }
 
 type HConfig struct {
@@ -20,16 +23,20 @@ type HConfig struct {
 }
 
 func NewHandler(c *HConfig) *Handler {
-	svc := service.NewService()
+	svc := service.NewService(c.DS.RDB)
 	repo := repository.NewRepository(c.DS, svc)
 	return &Handler{
 		R:    c.R,
 		repo: repo,
+		svc:  svc,
+		pay:  payment.New(repo, svc),
 	}
 }
 
 func (h *Handler) Register() {
 	h.R.Use(middleware.Cors())
+	h.R.Use(middleware.GeoMiddleware(h.repo, middleware.GeoPolicyDefault))
+	h.R.Use(middleware.AuditMiddleware(h.repo))
 
 	// auth routes
 	authHandler := newAuthHandler(h)
@@ -39,7 +46,55 @@ func (h *Handler) Register() {
 	adminHandler := newAdminHandler(h)
 	adminHandler.register()
 
+	// login lockout routes
+	lockoutHandler := newLockoutHandler(h)
+	lockoutHandler.register()
+
+	// assisted 2fa reset routes
+	twoFactorHandler := newTwoFactorHandler(h)
+	twoFactorHandler.register()
+
+	// role and permission routes
+	roleHandler := newRoleHandler(h)
+	roleHandler.register()
+
+	// audit log routes
+	auditHandler := newAuditHandler(h)
+	auditHandler.register()
+
+	// four-eyes proposal routes
+	proposalHandler := newProposalHandler(h)
+	proposalHandler.register()
+
 	// bank routes
 	bankHandler := newBankHandler(h)
 	bankHandler.register()
+
+	// transaction routes
+	txHandler := newTxHandler(h)
+	txHandler.register()
+
+	// batch payout routes
+	payoutHandler := newPayoutHandler(h)
+	payoutHandler.register()
+
+	// transaction limit routes
+	limitHandler := newLimitHandler(h)
+	limitHandler.register()
+
+	// kyc review routes
+	kycHandler := newKYCHandler(h)
+	kycHandler.register()
+
+	// address screening routes
+	screeningHandler := newScreeningHandler(h)
+	screeningHandler.register()
+
+	// withdrawal risk review routes
+	riskHandler := newRiskHandler(h)
+	riskHandler.register()
+
+	// geo-blocking allowlist routes
+	geoHandler := newGeoHandler(h)
+	geoHandler.register()
 }
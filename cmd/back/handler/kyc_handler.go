@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type kycHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func newKYCHandler(h *Handler) *kycHandler {
+	return &kycHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+	}
+}
+
+func (ctr *kycHandler) register() {
+	group := ctr.R.Group("/api/kyc")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaKYC))
+	group.GET("", ctr.getSubmissions)
+	group.GET("/:id", ctr.getSubmission)
+	group.GET("/:id/documents/:document", ctr.getDocument)
+	group.POST("/:id/approve", ctr.approve)
+	group.POST("/:id/reject", ctr.reject)
+}
+
+func (ctr *kycHandler) getSubmissions(c *gin.Context) {
+	req := dto.KYCListReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list, total, err := ctr.repo.KYC.List(c.Request.Context(), &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := gin.H{
+		"list":  list,
+		"total": total,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *kycHandler) getSubmission(c *gin.Context) {
+	submission, res := ctr.findSubmission(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res = utils.GenerateSuccessResponse(submission)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// getDocument streams an uploaded file of the submission from the blob store
+func (ctr *kycHandler) getDocument(c *gin.Context) {
+	submission, res := ctr.findSubmission(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	var document *model.KYCDocument
+	for _, d := range submission.Documents {
+		if strconv.FormatUint(d.ID, 10) == c.Param("document") {
+			document = d
+		}
+	}
+	if document == nil {
+		res := utils.GenerateGormErrorResponse(gorm.ErrRecordNotFound)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	blob, err := ctr.svc.Blob.Get(c.Request.Context(), document.BlobKey)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	defer blob.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=kyc-%d-%s", submission.ID, document.Kind))
+	c.Header("Cache-Control", "no-store")
+	c.DataFromReader(http.StatusOK, document.Size, document.ContentType, blob, nil)
+}
+
+func (ctr *kycHandler) approve(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	submission, res := ctr.findSubmission(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	approved, err := ctr.repo.KYC.Approve(c.Request.Context(), submission, *admin.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !approved {
+		res := utils.GenerateConflictResponse(errors.New("submission was already reviewed"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	ctr.mail(submission, "Identity verification approved",
+		fmt.Sprintf("Your identity verification was approved, your account is now at level %d.", submission.Level))
+	res = utils.GenerateSuccessResponse(submission)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *kycHandler) reject(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.KYCRejectReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	submission, res := ctr.findSubmission(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	rejected, err := ctr.repo.KYC.Reject(c.Request.Context(), submission, *admin.ID, req.Reason)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !rejected {
+		res := utils.GenerateConflictResponse(errors.New("submission was already reviewed"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	ctr.mail(submission, "Identity verification rejected",
+		fmt.Sprintf("Your identity verification was rejected: %s\nYou can send a new submission.", req.Reason))
+	res = utils.GenerateSuccessResponse(submission)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *kycHandler) findSubmission(c *gin.Context) (*model.KYCSubmission, *dto.Response) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, utils.GenerateBadRequestErrorResponse(err)
+	}
+	submission, err := ctr.repo.KYC.FindByID(c.Request.Context(), id)
+	if err != nil {
+		return nil, utils.GenerateGormErrorResponse(err)
+	}
+	return submission, nil
+}
+
+// mail tells the user about the review, the decision stands if it fails
+func (ctr *kycHandler) mail(submission *model.KYCSubmission, subject, body string) {
+	if submission.User == nil {
+		return
+	}
+	if err := ctr.svc.Mail.Send(submission.User.Email, subject, body); err != nil {
+		log.Println(err, "Error sending kyc email")
+	}
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type limitHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+}
+
+func newLimitHandler(h *Handler) *limitHandler {
+	return &limitHandler{
+		R:    h.R,
+		repo: h.repo,
+	}
+}
+
+func (ctr *limitHandler) register() {
+	group := ctr.R.Group("/api/limits")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaLimits))
+
+	group.GET("/tiers", ctr.getTierLimits)
+	group.PUT("/tiers", ctr.saveTierLimit)
+	group.GET("/users/:id", ctr.getUserLimits)
+	group.PUT("/users/:id", ctr.saveUserLimit)
+	group.DELETE("/users/:id/:currency", ctr.deleteUserLimit)
+	group.PUT("/users/:id/tier", ctr.setUserTier)
+}
+
+func (ctr *limitHandler) getTierLimits(c *gin.Context) {
+	list, err := ctr.repo.Limit.ListTierLimits(c.Request.Context())
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(list)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *limitHandler) saveTierLimit(c *gin.Context) {
+	req := dto.TierLimitReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	limit := &model.TierLimit{
+		Tier:     req.Tier,
+		Currency: req.Currency,
+		PerTx:    req.PerTx,
+		Daily:    req.Daily,
+		Monthly:  req.Monthly,
+	}
+	if err := ctr.repo.Limit.SaveTierLimit(c.Request.Context(), limit); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(limit)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// getUserLimits shows the user's tier, overrides and the caps that apply
+func (ctr *limitHandler) getUserLimits(c *gin.Context) {
+	user, err := ctr.repo.User.FindByField("id", c.Param("id"))
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	overrides, err := ctr.repo.Limit.ListUserLimits(c.Request.Context(), user.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	limits := make([]*dto.EffectiveLimit, 0, len(model.LimitCurrencies))
+	for _, currency := range model.LimitCurrencies {
+		limit, err := ctr.repo.Limit.Effective(c.Request.Context(), user, currency, time.Now())
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+		limits = append(limits, limit)
+	}
+
+	data := gin.H{
+		"tier":      user.Tier,
+		"overrides": overrides,
+		"limits":    limits,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *limitHandler) saveUserLimit(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.UserLimitReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	user, err := ctr.repo.User.FindByField("id", c.Param("id"))
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	limit := &model.UserLimit{
+		UserID:    user.ID,
+		Currency:  req.Currency,
+		PerTx:     req.PerTx,
+		Daily:     req.Daily,
+		Monthly:   req.Monthly,
+		UpdatedBy: *admin.ID,
+	}
+	if err := ctr.repo.Limit.SaveUserLimit(c.Request.Context(), limit); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(limit)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// deleteUserLimit drops an override, the user is back on the tier's caps
+func (ctr *limitHandler) deleteUserLimit(c *gin.Context) {
+	user, err := ctr.repo.User.FindByField("id", c.Param("id"))
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	if err := ctr.repo.Limit.DeleteUserLimit(c.Request.Context(), user.ID, c.Param("currency")); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *limitHandler) setUserTier(c *gin.Context) {
+	req := dto.UserTierReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	user, err := ctr.repo.User.FindByField("id", c.Param("id"))
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	updateFields := &model.UpdateFields{
+		Field: "id",
+		Value: user.ID,
+		Data:  map[string]any{"tier": req.Tier},
+	}
+	if _, err := ctr.repo.User.UpdateByFields(updateFields); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type lockoutHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+}
+
+func newLockoutHandler(h *Handler) *lockoutHandler {
+	return &lockoutHandler{
+		R:    h.R,
+		repo: h.repo,
+	}
+}
+
+func (ctr *lockoutHandler) register() {
+	group := ctr.R.Group("/api/lockouts")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+
+	users := group.Group("")
+	users.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaUsers))
+	users.GET("/users/:id", ctr.getUserStatus)
+	users.POST("/users/:id/unlock", ctr.unlockUser)
+	users.POST("/ips/unlock", ctr.unlockIP)
+
+	admins := group.Group("")
+	admins.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaAdmins))
+	admins.GET("/admins/:id", ctr.getAdminStatus)
+	admins.POST("/admins/:id/unlock", ctr.unlockAdmin)
+}
+
+func (ctr *lockoutHandler) getUserStatus(c *gin.Context) {
+	account, res := ctr.userAccount(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	ctr.status(c, account)
+}
+
+func (ctr *lockoutHandler) unlockUser(c *gin.Context) {
+	account, res := ctr.userAccount(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	ctr.unlock(c, account)
+}
+
+func (ctr *lockoutHandler) getAdminStatus(c *gin.Context) {
+	account, res := ctr.adminAccount(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	ctr.status(c, account)
+}
+
+func (ctr *lockoutHandler) unlockAdmin(c *gin.Context) {
+	account, res := ctr.adminAccount(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	ctr.unlock(c, account)
+}
+
+// unlockIP lets an address that failed too often try again
+func (ctr *lockoutHandler) unlockIP(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.UnlockIPReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	if err := ctr.repo.Lockout.UnlockIP(c.Request.Context(), req.IP); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	log.Printf("login lock of ip %s lifted by admin %d\n", req.IP, *admin.ID)
+	middleware.Audit(c, fmt.Sprintf("ip:%s", req.IP), nil, req)
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *lockoutHandler) status(c *gin.Context, account string) {
+	status, err := ctr.repo.Lockout.Status(c.Request.Context(), account)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(status)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *lockoutHandler) unlock(c *gin.Context, account string) {
+	admin := c.MustGet("admin").(*model.Admin)
+	if err := ctr.repo.Lockout.Unlock(c.Request.Context(), account); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	log.Printf("login lock of %s lifted by admin %d\n", account, *admin.ID)
+	middleware.Audit(c, account, nil, nil)
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *lockoutHandler) userAccount(c *gin.Context) (string, *dto.Response) {
+	user, err := ctr.repo.User.FindByField("id", c.Param("id"))
+	if err != nil {
+		return "", utils.GenerateGormErrorResponse(err)
+	}
+	return repository.LoginAccount(model.AuditActorUser, user.ID.String()), nil
+}
+
+func (ctr *lockoutHandler) adminAccount(c *gin.Context) (string, *dto.Response) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return "", utils.GenerateBadRequestErrorResponse(err)
+	}
+	admin, err := ctr.repo.Admin.FindByField("id", strconv.FormatUint(id, 10))
+	if err != nil {
+		return "", utils.GenerateGormErrorResponse(err)
+	}
+	return repository.LoginAccount(model.AuditActorAdmin, fmt.Sprint(*admin.ID)), nil
+}
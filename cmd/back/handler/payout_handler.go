@@ -0,0 +1,498 @@
+package handler
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/payment"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// most rows accepted in one csv
+	maxPayoutRows = 500
+	// biggest csv accepted
+	maxPayoutFileSize = 1 << 20
+)
+
+var payoutCSVHeader = []string{"address", "amount", "network", "token", "reference"}
+
+type payoutHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	svc  *service.Service
+	pay  *payment.Payments
+}
+
+func newPayoutHandler(h *Handler) *payoutHandler {
+	return &payoutHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+		pay:  h.pay,
+	}
+}
+
+func (ctr *payoutHandler) register() {
+	group := ctr.R.Group("/api/payouts")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaPayouts))
+	group.GET("", ctr.getBatches)
+	group.POST("", ctr.uploadBatch)
+	group.GET("/:id", ctr.getBatch)
+	group.GET("/:id/report", ctr.getReport)
+	group.POST("/:id/execute", middleware.IdempotencyMiddleware(ctr.repo), middleware.AdminFactorMiddleware(ctr.repo), ctr.executeBatch)
+}
+
+func (ctr *payoutHandler) getBatches(c *gin.Context) {
+	req := dto.PayoutBatchListReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list, total, err := ctr.repo.Payout.List(c.Request.Context(), &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := gin.H{
+		"list":  list,
+		"total": total,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// uploadBatch checks every row of the csv and the bank balance, and saves the
+// batch for preview. Nothing is sent until it is executed.
+func (ctr *payoutHandler) uploadBatch(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.PayoutBatchCreateReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if file.Size > maxPayoutFileSize {
+		res := utils.GenerateBadRequestErrorResponse(fmt.Errorf("file is bigger than %d bytes", maxPayoutFileSize))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	bank, err := ctr.repo.Bank.FindByID(req.BankID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	defer f.Close()
+
+	rows, rowErrors, err := parsePayoutCSV(f, *bank.AddressType)
+	if err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	for _, row := range rows {
+		if ctr.pay.ScreenPayout(c.Request.Context(), row, file.Filename) {
+			rowErrors = append(rowErrors, &dto.PayoutRowError{Line: row.Line, Error: payment.ErrScreenedPayout.Error()})
+		}
+	}
+	if len(rowErrors) > 0 {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("csv has invalid rows"))
+		res.Data = rowErrors
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	batch := &model.PayoutBatch{
+		BankID:    *bank.ID,
+		CreatedBy: *admin.ID,
+		FileName:  file.Filename,
+		Network:   *bank.AddressType,
+		Status:    model.PayoutBatchPreviewed,
+		RowCount:  len(rows),
+		Rows:      rows,
+	}
+	preview, err := previewPayout(ctr.svc, batch, bank)
+	if err != nil {
+		res := utils.GenerateServiceUnavailableResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := checkPayoutFunds(preview); err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		res.Data = preview
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	if err := ctr.repo.Payout.Create(c.Request.Context(), batch); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(preview)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *payoutHandler) getBatch(c *gin.Context) {
+	batch, res := ctr.findBatch(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res = utils.GenerateSuccessResponse(batch)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// executeBatch starts sending the batch, batches worth
+// conf.FourEyesWithdrawalUSDT or more become a proposal first
+func (ctr *payoutHandler) executeBatch(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	batch, res := ctr.findBatch(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if batch.Status != model.PayoutBatchPreviewed {
+		res := utils.GenerateBadRequestErrorResponse(fmt.Errorf("batch is %s", batch.Status))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	// large batches wait for other admins, the total covers every line
+	value, err := payoutValueUSDT(c.Request.Context(), ctr.svc, batch)
+	if err != nil {
+		res := utils.GenerateServiceUnavailableResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if value >= conf.FourEyesWithdrawalUSDT {
+		res := propose(c, ctr.repo, model.ProposalPayoutExecute, fmt.Sprintf("payout:%d", batch.ID), &payoutProposal{
+			BatchID:   batch.ID,
+			FileName:  batch.FileName,
+			Network:   batch.Network,
+			RowCount:  batch.RowCount,
+			ValueUSDT: value,
+		})
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	preview, res := startPayout(c.Request.Context(), ctr.repo, ctr.svc, ctr.pay, batch, *admin.ID)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res = utils.GenerateSuccessResponse(preview.PayoutBatch)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// startPayout checks the balance again and sends the rows in the background,
+// the batch shows the progress. It returns the error response when the
+// batch can't start.
+func startPayout(ctx context.Context, repo *repository.Repository, svc *service.Service, pay *payment.Payments, batch *model.PayoutBatch, adminID uint64) (*dto.PayoutPreviewResp, *dto.Response) {
+	bank, err := repo.Bank.FindByID(batch.BankID)
+	if err != nil {
+		return nil, utils.GenerateGormErrorResponse(err)
+	}
+	privateKey, err := utils.DecryptAES(*bank.PrivateKey)
+	if err != nil {
+		return nil, utils.GenerateServerError(err)
+	}
+
+	preview, err := previewPayout(svc, batch, bank)
+	if err != nil {
+		return nil, utils.GenerateServiceUnavailableResponse(err)
+	}
+	if err := checkPayoutFunds(preview); err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		res.Data = preview
+		return nil, res
+	}
+
+	started, err := repo.Payout.StartExecution(ctx, batch, adminID)
+	if err != nil {
+		return nil, utils.GenerateGormErrorResponse(err)
+	}
+	if !started {
+		return nil, utils.GenerateConflictResponse(errors.New("batch is already executing"))
+	}
+
+	// the worker resumes the batch if this server stops before it is done
+	go pay.ExecutePayout(context.Background(), batch, bank, privateKey)
+	return preview, nil
+}
+
+// payoutValueUSDT prices the pending rows of the batch for the four-eyes
+// threshold
+func payoutValueUSDT(ctx context.Context, svc *service.Service, batch *model.PayoutBatch) (float64, error) {
+	rates := map[string]float64{"USDT": 1}
+	value := 0.0
+	for _, row := range batch.Rows {
+		if row.Status != model.PayoutRowPending {
+			continue
+		}
+		rate, ok := rates[row.Token]
+		if !ok {
+			var err error
+			rate, err = service.FreshRate(ctx, svc.Price, row.Token, "USDT", conf.PriceMaxAge)
+			if err != nil {
+				return 0, err
+			}
+			rates[row.Token] = rate
+		}
+		value += row.Amount * rate
+	}
+	return value, nil
+}
+
+// payoutProposal is the payload of a payout batch execution
+type payoutProposal struct {
+	BatchID   uint64  `json:"batch_id"`
+	FileName  string  `json:"file_name"`
+	Network   string  `json:"network"`
+	RowCount  int     `json:"row_count"`
+	ValueUSDT float64 `json:"value_usdt"`
+}
+
+// getReport downloads the rows with their status and transaction hash
+func (ctr *payoutHandler) getReport(c *gin.Context) {
+	batch, res := ctr.findBatch(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="payout-%d-report.csv"`, batch.ID))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(append([]string{"line"}, append(payoutCSVHeader, "status", "tx_hash", "error", "sent_at")...))
+	for _, row := range batch.Rows {
+		sentAt := ""
+		if row.SentAt != nil {
+			sentAt = row.SentAt.Format(time.RFC3339)
+		}
+		w.Write([]string{
+			strconv.Itoa(row.Line),
+			row.Address,
+			strconv.FormatFloat(row.Amount, 'f', -1, 64),
+			row.Network,
+			row.Token,
+			row.Reference,
+			row.Status,
+			row.TxHash,
+			row.Error,
+			sentAt,
+		})
+	}
+	w.Flush()
+}
+
+func (ctr *payoutHandler) findBatch(c *gin.Context) (*model.PayoutBatch, *dto.Response) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, utils.GenerateBadRequestErrorResponse(err)
+	}
+	batch, err := ctr.repo.Payout.FindByID(c.Request.Context(), id)
+	if err != nil {
+		return nil, utils.GenerateGormErrorResponse(err)
+	}
+	return batch, nil
+}
+
+// previewPayout sums the batch per currency and estimates the fees of every row
+func previewPayout(svc *service.Service, batch *model.PayoutBatch, bank *model.Bank) (*dto.PayoutPreviewResp, error) {
+	preview := &dto.PayoutPreviewResp{
+		PayoutBatch: batch,
+		Totals:      map[string]float64{},
+		Fees:        map[string]float64{},
+	}
+
+	estimates := map[string]*service.FeeEstimate{}
+	for _, row := range batch.Rows {
+		if row.Status != model.PayoutRowPending {
+			continue
+		}
+		preview.Totals[row.Token] += row.Amount
+
+		estimate, ok := estimates[row.Token]
+		if !ok {
+			var err error
+			estimate, err = svc.EstimateFee(row.Network, row.Token, *bank.WalletAddress, row.Address, row.Amount)
+			if err != nil {
+				return nil, err
+			}
+			estimates[row.Token] = estimate
+		}
+		preview.Fees[estimate.NativeCurrency] += utils.FromBaseUnits(estimate.Fee(service.FeeTierNormal), estimate.Decimals)
+	}
+
+	balances, err := svc.Balances(batch.Network, *bank.WalletAddress)
+	if err != nil {
+		return nil, err
+	}
+	preview.Balances = balances
+	return preview, nil
+}
+
+// checkPayoutFunds fails when the bank can't cover the payouts and their fees
+func checkPayoutFunds(preview *dto.PayoutPreviewResp) error {
+	needed := map[string]float64{}
+	for currency, total := range preview.Totals {
+		needed[currency] += total
+	}
+	for currency, fee := range preview.Fees {
+		needed[currency] += fee
+	}
+	for currency, amount := range needed {
+		if balance := preview.Balances[currency]; balance < amount {
+			return fmt.Errorf("bank has %v %s, the batch needs %v", balance, currency, amount)
+		}
+	}
+	return nil
+}
+
+// parsePayoutCSV reads rows of address,amount,network,token,reference, the
+// header line is optional. Problems of every row are returned together.
+func parsePayoutCSV(r io.Reader, network string) ([]*model.PayoutRow, []*dto.PayoutRowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows := make([]*model.PayoutRow, 0)
+	rowErrors := make([]*dto.PayoutRowError, 0)
+	seen := map[string]int{}
+	references := map[string]int{}
+
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line++
+		if line == 1 {
+			// spreadsheet exports may start with a byte order mark
+			record[0] = strings.TrimPrefix(record[0], "\ufeff")
+			if strings.EqualFold(strings.TrimSpace(record[0]), payoutCSVHeader[0]) {
+				continue
+			}
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(rows)+len(rowErrors) >= maxPayoutRows {
+			return nil, nil, fmt.Errorf("csv has more than %d rows", maxPayoutRows)
+		}
+
+		row, err := parsePayoutRecord(record, network)
+		if err != nil {
+			rowErrors = append(rowErrors, &dto.PayoutRowError{Line: line, Error: err.Error()})
+			continue
+		}
+		row.Line = line
+
+		key := fmt.Sprintf("%s|%s|%s|%v", strings.ToLower(row.Address), row.Network, row.Token, row.Amount)
+		if first, ok := seen[key]; ok {
+			rowErrors = append(rowErrors, &dto.PayoutRowError{Line: line, Error: fmt.Sprintf("duplicate of line %d", first)})
+			continue
+		}
+		seen[key] = line
+		if row.Reference != "" {
+			if first, ok := references[row.Reference]; ok {
+				rowErrors = append(rowErrors, &dto.PayoutRowError{Line: line, Error: fmt.Sprintf("reference already used on line %d", first)})
+				continue
+			}
+			references[row.Reference] = line
+		}
+
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 && len(rowErrors) == 0 {
+		return nil, nil, errors.New("csv has no rows")
+	}
+	return rows, rowErrors, nil
+}
+
+func parsePayoutRecord(record []string, network string) (*model.PayoutRow, error) {
+	if len(record) < 4 || len(record) > len(payoutCSVHeader) {
+		return nil, fmt.Errorf("expected the columns %s", strings.Join(payoutCSVHeader, ","))
+	}
+	for i := range record {
+		record[i] = strings.TrimSpace(record[i])
+	}
+
+	row := &model.PayoutRow{
+		Address: record[0],
+		Network: strings.ToUpper(record[2]),
+		Token:   strings.ToUpper(record[3]),
+		Status:  model.PayoutRowPending,
+	}
+	if len(record) == len(payoutCSVHeader) {
+		row.Reference = record[4]
+	}
+	if len(row.Reference) > 255 {
+		return nil, errors.New("reference is longer than 255 characters")
+	}
+
+	amount, err := strconv.ParseFloat(record[1], 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) || amount <= 0 {
+		return nil, fmt.Errorf("invalid amount %q", record[1])
+	}
+	row.Amount = amount
+
+	if row.Network != network {
+		return nil, fmt.Errorf("network %s does not match the %s bank", row.Network, network)
+	}
+	if !service.IsNetworkCurrency(row.Network, row.Token) {
+		return nil, fmt.Errorf("token %s is not supported on %s", row.Token, row.Network)
+	}
+	if !utils.IsValidAddress(row.Network, row.Address) {
+		return nil, fmt.Errorf("invalid %s address %q", row.Network, row.Address)
+	}
+	return row, nil
+}
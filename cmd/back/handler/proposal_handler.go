@@ -0,0 +1,372 @@
+package handler
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/payment"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/copier"
+)
+
+// proposalExecutor runs an approved proposal of one kind, approvedBy is the
+// admin whose approval completed it
+type proposalExecutor func(ctx context.Context, proposal *model.Proposal, approvedBy uint64) error
+
+type proposalHandler struct {
+	R         *gin.Engine
+	repo      *repository.Repository
+	svc       *service.Service
+	pay       *payment.Payments
+	executors map[string]proposalExecutor
+}
+
+func newProposalHandler(h *Handler) *proposalHandler {
+	ctr := &proposalHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+		pay:  h.pay,
+	}
+	ctr.executors = map[string]proposalExecutor{
+		model.ProposalBankCreate:          ctr.createBank,
+		model.ProposalBankUpdate:          ctr.updateBank,
+		model.ProposalAdminCreate:         ctr.createAdmin,
+		model.ProposalAdminUpdate:         ctr.updateAdmin,
+		model.ProposalAdminTwoFactorReset: ctr.resetAdminTwoFactor,
+		model.ProposalAdminRoles:          ctr.setAdminRoles,
+		model.ProposalWithdrawalApproval:  ctr.approveWithdrawal,
+		model.ProposalPayoutExecute:       ctr.executePayout,
+	}
+	return ctr
+}
+
+func (ctr *proposalHandler) register() {
+	group := ctr.R.Group("/api/proposals")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.GET("", middleware.PermissionMiddleware(ctr.repo, model.PermAreaProposals), ctr.getProposals)
+	group.GET("/:id", middleware.PermissionMiddleware(ctr.repo, model.PermAreaProposals), ctr.getProposal)
+
+	// deciding takes write on the area of the proposal, checked per proposal
+	group.POST("/:id/approve", middleware.AdminFactorMiddleware(ctr.repo), ctr.approve)
+	group.POST("/:id/reject", middleware.AdminFactorMiddleware(ctr.repo), ctr.reject)
+}
+
+func (ctr *proposalHandler) getProposals(c *gin.Context) {
+	req := dto.ProposalListReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list, total, err := ctr.repo.Proposal.List(c.Request.Context(), &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := gin.H{
+		"list":  list,
+		"total": total,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// getProposal shows a proposal with its whole history
+func (ctr *proposalHandler) getProposal(c *gin.Context) {
+	proposal, res := ctr.findProposal(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res = utils.GenerateSuccessResponse(proposal)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// approve adds the admin's approval, the one that completes the proposal
+// also runs it
+func (ctr *proposalHandler) approve(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.ProposalDecisionReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	proposal, res := ctr.findDecidable(c, admin)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	proposal, ready, err := ctr.repo.Proposal.Approve(c.Request.Context(), proposal.ID, *admin.ID, req.Note)
+	if err != nil {
+		res := proposalErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	middleware.Audit(c, fmt.Sprintf("proposal:%d", proposal.ID), nil, gin.H{"event": model.ProposalEventApproved, "note": req.Note})
+
+	if ready {
+		execErr := ctr.executors[proposal.Kind](context.Background(), proposal, *admin.ID)
+		if execErr != nil {
+			log.Println(execErr, "Error executing proposal ", proposal.ID)
+		}
+		if err := ctr.repo.Proposal.Finish(context.Background(), proposal, execErr); err != nil {
+			log.Println(err, "Error saving proposal ", proposal.ID)
+		}
+	}
+
+	ctr.respond(c, proposal.ID)
+}
+
+// reject ends the proposal, the proposer may reject to withdraw it
+func (ctr *proposalHandler) reject(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	req := dto.ProposalDecisionReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	proposal, res := ctr.findDecidable(c, admin)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	proposal, err := ctr.repo.Proposal.Reject(c.Request.Context(), proposal.ID, *admin.ID, req.Note)
+	if err != nil {
+		res := proposalErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	middleware.Audit(c, fmt.Sprintf("proposal:%d", proposal.ID), nil, gin.H{"event": proposal.Status, "note": req.Note})
+
+	ctr.respond(c, proposal.ID)
+}
+
+func (ctr *proposalHandler) respond(c *gin.Context, id uint64) {
+	proposal, err := ctr.repo.Proposal.FindByID(c.Request.Context(), id)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(proposal)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *proposalHandler) createBank(ctx context.Context, proposal *model.Proposal, approvedBy uint64) error {
+	bank := model.Bank{}
+	if err := json.Unmarshal(proposal.Payload, &bank); err != nil {
+		return err
+	}
+	scanRecord := ctr.repo.Bank.GetAddressScanRecord(*bank.AddressType, *bank.WalletAddress)
+	bank.ScanRecord = &scanRecord
+	return ctr.repo.Bank.Create(ctx, &bank)
+}
+
+func (ctr *proposalHandler) updateBank(ctx context.Context, proposal *model.Proposal, approvedBy uint64) error {
+	req := dto.UpdateBankReq{}
+	if err := json.Unmarshal(proposal.Payload, &req); err != nil {
+		return err
+	}
+	bank := model.Bank{}
+	if err := copier.Copy(&bank, &req); err != nil {
+		return err
+	}
+	// the proposal keeps the key encrypted, Update encrypts it again
+	if bank.PrivateKey != nil {
+		privateKey, err := utils.DecryptAES(*bank.PrivateKey)
+		if err != nil {
+			return err
+		}
+		bank.PrivateKey = &privateKey
+	}
+	return ctr.repo.Bank.Update(ctx, &bank)
+}
+
+func (ctr *proposalHandler) createAdmin(ctx context.Context, proposal *model.Proposal, approvedBy uint64) error {
+	req := dto.AdminCreateReq{}
+	if err := json.Unmarshal(proposal.Payload, &req); err != nil {
+		return err
+	}
+	admin := model.Admin{}
+	if err := copier.Copy(&admin, &req); err != nil {
+		return err
+	}
+	return ctr.repo.Admin.Create(ctx, &admin)
+}
+
+func (ctr *proposalHandler) updateAdmin(ctx context.Context, proposal *model.Proposal, approvedBy uint64) error {
+	req := dto.AdminEditReq{}
+	if err := json.Unmarshal(proposal.Payload, &req); err != nil {
+		return err
+	}
+	before, err := ctr.repo.Admin.FindByField("id", strconv.FormatUint(*req.ID, 10))
+	if err != nil {
+		return err
+	}
+	return updateAdmin(ctx, ctr.repo, &req, before)
+}
+
+func (ctr *proposalHandler) resetAdminTwoFactor(ctx context.Context, proposal *model.Proposal, approvedBy uint64) error {
+	payload := adminResetProposal{}
+	if err := json.Unmarshal(proposal.Payload, &payload); err != nil {
+		return err
+	}
+	if err := resetAdminTwoFactor(ctx, ctr.repo, ctr.svc, payload.AdminID); err != nil {
+		return err
+	}
+	log.Printf("2fa of admin %d reset, approved by admin %d\n", payload.AdminID, approvedBy)
+	return nil
+}
+
+func (ctr *proposalHandler) setAdminRoles(ctx context.Context, proposal *model.Proposal, approvedBy uint64) error {
+	payload := adminRolesProposal{}
+	if err := json.Unmarshal(proposal.Payload, &payload); err != nil {
+		return err
+	}
+	if err := ctr.repo.Role.SetAdminRoles(ctx, payload.AdminID, payload.RoleIDs, proposal.ProposedBy); err != nil {
+		return err
+	}
+	log.Printf("roles of admin %d set to %v, approved by admin %d\n", payload.AdminID, payload.RoleIDs, approvedBy)
+	return nil
+}
+
+func (ctr *proposalHandler) approveWithdrawal(ctx context.Context, proposal *model.Proposal, approvedBy uint64) error {
+	payload := withdrawalProposal{}
+	if err := json.Unmarshal(proposal.Payload, &payload); err != nil {
+		return err
+	}
+	assessment, err := ctr.repo.Risk.FindByID(ctx, payload.AssessmentID)
+	if err != nil {
+		return err
+	}
+
+	decided, err := ctr.repo.Risk.Decide(ctx, assessment, model.RiskReviewApproved, approvedBy)
+	if err != nil {
+		return err
+	}
+	if !decided {
+		return errors.New("withdrawal was already reviewed")
+	}
+	return sendReviewed(ctr.pay, ctr.repo, assessment)
+}
+
+func (ctr *proposalHandler) executePayout(ctx context.Context, proposal *model.Proposal, approvedBy uint64) error {
+	payload := payoutProposal{}
+	if err := json.Unmarshal(proposal.Payload, &payload); err != nil {
+		return err
+	}
+	batch, err := ctr.repo.Payout.FindByID(ctx, payload.BatchID)
+	if err != nil {
+		return err
+	}
+	if batch.Status != model.PayoutBatchPreviewed {
+		return fmt.Errorf("batch is %s", batch.Status)
+	}
+
+	if _, res := startPayout(ctx, ctr.repo, ctr.svc, ctr.pay, batch, approvedBy); res != nil {
+		return errors.New(res.ErrMsg)
+	}
+	return nil
+}
+
+// findDecidable loads a proposal the admin may approve or reject
+func (ctr *proposalHandler) findDecidable(c *gin.Context, admin *model.Admin) (*model.Proposal, *dto.Response) {
+	proposal, res := ctr.findProposal(c)
+	if res != nil {
+		return nil, res
+	}
+	permissions, err := ctr.repo.Role.Permissions(c.Request.Context(), *admin.ID)
+	if err != nil {
+		return nil, utils.GenerateServerError(err)
+	}
+	area := model.ProposalAreas[proposal.Kind]
+	if !model.HasPermission(permissions, area, model.PermActionWrite) {
+		return nil, utils.GenerateForbiddenResponse(fmt.Errorf("missing permission %s:%s", area, model.PermActionWrite))
+	}
+	return proposal, nil
+}
+
+func (ctr *proposalHandler) findProposal(c *gin.Context) (*model.Proposal, *dto.Response) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, utils.GenerateBadRequestErrorResponse(err)
+	}
+	proposal, err := ctr.repo.Proposal.FindByID(c.Request.Context(), id)
+	if err != nil {
+		return nil, utils.GenerateGormErrorResponse(err)
+	}
+	return proposal, nil
+}
+
+// withdrawalProposal is the payload of a withdrawal approval
+type withdrawalProposal struct {
+	AssessmentID uint64  `json:"assessment_id"`
+	UserID       string  `json:"user_id"`
+	Currency     string  `json:"currency"`
+	Amount       float64 `json:"amount"`
+	ToAddress    string  `json:"to_address"`
+}
+
+// propose holds a sensitive request until other admins approve it. Secrets
+// in payload must already be encrypted or hashed. It returns the 202
+// response with the proposal, or the error response.
+func propose(c *gin.Context, repo *repository.Repository, kind, target string, payload any) *dto.Response {
+	admin := c.MustGet("admin").(*model.Admin)
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return utils.GenerateServerError(err)
+	}
+
+	proposal := &model.Proposal{
+		Kind:       kind,
+		Target:     target,
+		Payload:    raw,
+		Summary:    utils.RedactJSON(raw),
+		Required:   conf.ProposalApprovals,
+		ProposedBy: *admin.ID,
+		ExpiresAt:  time.Now().Add(conf.ProposalTTL),
+	}
+	if err := repo.Proposal.Create(c.Request.Context(), proposal); err != nil {
+		return proposalErrorResponse(err)
+	}
+	middleware.Audit(c, fmt.Sprintf("proposal:%d", proposal.ID), nil, proposal)
+
+	res := utils.GenerateAcceptedResponse(fmt.Errorf("waiting for %d admin approvals", proposal.Required))
+	res.Data = proposal
+	return res
+}
+
+func proposalErrorResponse(err error) *dto.Response {
+	switch {
+	case errors.Is(err, repository.ErrProposalNotPending),
+		errors.Is(err, repository.ErrProposalExpired),
+		errors.Is(err, repository.ErrProposalPending),
+		errors.Is(err, repository.ErrAlreadyApproved):
+		return utils.GenerateConflictResponse(err)
+	case errors.Is(err, repository.ErrOwnProposal):
+		return utils.GenerateForbiddenResponse(err)
+	}
+	return utils.GenerateGormErrorResponse(err)
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/payment"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type riskHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	svc  *service.Service
+	pay  *payment.Payments
+}
+
+func newRiskHandler(h *Handler) *riskHandler {
+	return &riskHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+		pay:  h.pay,
+	}
+}
+
+func (ctr *riskHandler) register() {
+	group := ctr.R.Group("/api/risk")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaRisk))
+	group.GET("", ctr.getAssessments)
+	group.GET("/:id", ctr.getAssessment)
+	group.POST("/:id/approve", middleware.IdempotencyMiddleware(ctr.repo), middleware.AdminFactorMiddleware(ctr.repo), ctr.approve)
+	group.POST("/:id/reject", ctr.reject)
+}
+
+// getAssessments lists scored withdrawals, review_status=pending is the
+// manual review queue
+func (ctr *riskHandler) getAssessments(c *gin.Context) {
+	req := dto.RiskListReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list, total, err := ctr.repo.Risk.List(c.Request.Context(), &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := gin.H{
+		"list":  list,
+		"total": total,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *riskHandler) getAssessment(c *gin.Context) {
+	assessment, res := ctr.findAssessment(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res = utils.GenerateSuccessResponse(assessment)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// approve sends the held withdrawal. Large ones become a proposal that
+// other admins have to approve first.
+func (ctr *riskHandler) approve(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	assessment, res := ctr.findAssessment(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if assessment.ReviewStatus != model.RiskReviewPending {
+		res := utils.GenerateConflictResponse(errors.New("withdrawal was already reviewed"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	value, err := ctr.valueUSDT(c.Request.Context(), assessment)
+	if err != nil {
+		res := utils.GenerateServiceUnavailableResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if value >= conf.FourEyesWithdrawalUSDT {
+		res := propose(c, ctr.repo, model.ProposalWithdrawalApproval, fmt.Sprintf("risk:%d", assessment.ID), &withdrawalProposal{
+			AssessmentID: assessment.ID,
+			UserID:       assessment.UserID.String(),
+			Currency:     assessment.Currency,
+			Amount:       assessment.Amount,
+			ToAddress:    assessment.ToAddress,
+		})
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	decided, err := ctr.repo.Risk.Decide(c.Request.Context(), assessment, model.RiskReviewApproved, *admin.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !decided {
+		res := utils.GenerateConflictResponse(errors.New("withdrawal was already reviewed"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	if err := sendReviewed(ctr.pay, ctr.repo, assessment); err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		res.Data = assessment
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	res = utils.GenerateSuccessResponse(assessment)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// valueUSDT prices the withdrawal for the four-eyes threshold
+func (ctr *riskHandler) valueUSDT(ctx context.Context, assessment *model.RiskAssessment) (float64, error) {
+	if assessment.Currency == "USDT" {
+		return assessment.Amount, nil
+	}
+	rate, err := service.FreshRate(ctx, ctr.svc.Price, assessment.Currency, "USDT", conf.PriceMaxAge)
+	if err != nil {
+		return 0, err
+	}
+	return assessment.Amount * rate, nil
+}
+
+func (ctr *riskHandler) reject(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	assessment, res := ctr.findAssessment(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	decided, err := ctr.repo.Risk.Decide(c.Request.Context(), assessment, model.RiskReviewRejected, *admin.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !decided {
+		res := utils.GenerateConflictResponse(errors.New("withdrawal was already reviewed"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Schedule.FinishHeldRun(c.Request.Context(), assessment); err != nil {
+		log.Println(err, "Error saving scheduled run of risk review ", assessment.ID)
+	}
+
+	res = utils.GenerateSuccessResponse(assessment)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *riskHandler) findAssessment(c *gin.Context) (*model.RiskAssessment, *dto.Response) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, utils.GenerateBadRequestErrorResponse(err)
+	}
+	assessment, err := ctr.repo.Risk.FindByID(c.Request.Context(), id)
+	if err != nil {
+		return nil, utils.GenerateGormErrorResponse(err)
+	}
+	return assessment, nil
+}
+
+// sendReviewed sends a withdrawal approved in review and records how it
+// went on the assessment. Every other check runs again, only the risk score
+// is skipped.
+func sendReviewed(pay *payment.Payments, repo *repository.Repository, assessment *model.RiskAssessment) error {
+	user, err := repo.User.FindByField("id", assessment.UserID.String())
+	var tx *model.Transaction
+	if err == nil {
+		tx, err = pay.Withdraw(context.Background(), &payment.Withdrawal{
+			User: user,
+			Req: &dto.WithdrawReq{
+				Network:   assessment.Network,
+				Currency:  assessment.Currency,
+				Amount:    assessment.Amount,
+				ToAddress: assessment.ToAddress,
+			},
+			Type:   assessment.Type,
+			IP:     assessment.IP,
+			Area:   assessment.Area,
+			Device: assessment.Device,
+			Review: assessment,
+		})
+	}
+	if err != nil {
+		assessment.ReviewStatus = model.RiskReviewFailed
+		assessment.Error = utils.Truncate(err.Error(), 500)
+	} else {
+		assessment.ReviewStatus = model.RiskReviewSent
+		assessment.TxHash = tx.TxHash
+	}
+	if err := repo.Risk.Finish(context.Background(), assessment); err != nil {
+		log.Println(err, "Error saving risk review ", assessment.ID)
+	}
+	if err := repo.Schedule.FinishHeldRun(context.Background(), assessment); err != nil {
+		log.Println(err, "Error saving scheduled run of risk review ", assessment.ID)
+	}
+	return err
+}
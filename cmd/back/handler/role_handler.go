@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type roleHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+}
+
+func newRoleHandler(h *Handler) *roleHandler {
+	return &roleHandler{
+		R:    h.R,
+		repo: h.repo,
+	}
+}
+
+func (ctr *roleHandler) register() {
+	group := ctr.R.Group("/api/roles")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.GET("/me", ctr.getMyPermissions)
+
+	group.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaRoles))
+	group.GET("", ctr.getRoles)
+	group.GET("/permissions", ctr.getPermissions)
+	group.POST("", ctr.createRole)
+	group.PUT("/:id", ctr.updateRole)
+	group.DELETE("/:id", ctr.deleteRole)
+	group.GET("/admins/:id", ctr.getAdminRoles)
+	group.PUT("/admins/:id", ctr.setAdminRoles)
+}
+
+// getMyPermissions lets the ui hide what the admin can't do
+func (ctr *roleHandler) getMyPermissions(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	roles, err := ctr.repo.Role.AdminRoles(c.Request.Context(), *admin.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	permissions, err := ctr.repo.Role.Permissions(c.Request.Context(), *admin.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := gin.H{
+		"roles":       roles,
+		"permissions": permissions,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *roleHandler) getRoles(c *gin.Context) {
+	list, err := ctr.repo.Role.List(c.Request.Context())
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(list)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// getPermissions lists what custom roles can be made of
+func (ctr *roleHandler) getPermissions(c *gin.Context) {
+	data := gin.H{
+		"areas":   model.PermAreas,
+		"actions": []string{model.PermActionRead, model.PermActionWrite},
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *roleHandler) createRole(c *gin.Context) {
+	req := dto.RoleReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if res := checkPermissions(req.Permissions); res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	role := &model.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+	if err := ctr.repo.Role.Create(c.Request.Context(), role); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	middleware.Audit(c, fmt.Sprintf("role:%d", role.ID), nil, role)
+
+	res := utils.GenerateSuccessResponse(role)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *roleHandler) updateRole(c *gin.Context) {
+	req := dto.RoleReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if res := checkPermissions(req.Permissions); res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	role, res := ctr.findRole(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	before := *role
+	role.Name = req.Name
+	role.Description = req.Description
+	role.Permissions = req.Permissions
+	if err := ctr.repo.Role.Update(c.Request.Context(), role); err != nil {
+		res := roleErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	middleware.Audit(c, fmt.Sprintf("role:%d", role.ID), before, role)
+
+	res = utils.GenerateSuccessResponse(role)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *roleHandler) deleteRole(c *gin.Context) {
+	role, res := ctr.findRole(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	if err := ctr.repo.Role.Delete(c.Request.Context(), role); err != nil {
+		res := roleErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	middleware.Audit(c, fmt.Sprintf("role:%d", role.ID), role, nil)
+
+	res = utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *roleHandler) getAdminRoles(c *gin.Context) {
+	admin, res := ctr.findAdmin(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list, err := ctr.repo.Role.AdminRoles(c.Request.Context(), *admin.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res = utils.GenerateSuccessResponse(list)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// setAdminRoles proposes the roles, they are granted once other admins
+// approve them
+func (ctr *roleHandler) setAdminRoles(c *gin.Context) {
+	req := dto.AdminRolesReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	admin, res := ctr.findAdmin(c)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	payload := adminRolesProposal{AdminID: *admin.ID, RoleIDs: req.RoleIDs}
+	res = propose(c, ctr.repo, model.ProposalAdminRoles, fmt.Sprintf("admin:%d", *admin.ID), payload)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// adminRolesProposal is the payload of a role grant
+type adminRolesProposal struct {
+	AdminID uint64   `json:"admin_id"`
+	RoleIDs []uint64 `json:"role_ids"`
+}
+
+func (ctr *roleHandler) findRole(c *gin.Context) (*model.Role, *dto.Response) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, utils.GenerateBadRequestErrorResponse(err)
+	}
+	role, err := ctr.repo.Role.FindByID(c.Request.Context(), id)
+	if err != nil {
+		return nil, utils.GenerateGormErrorResponse(err)
+	}
+	return role, nil
+}
+
+func (ctr *roleHandler) findAdmin(c *gin.Context) (*model.Admin, *dto.Response) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, utils.GenerateBadRequestErrorResponse(err)
+	}
+	admin, err := ctr.repo.Admin.FindByField("id", strconv.FormatUint(id, 10))
+	if err != nil {
+		return nil, utils.GenerateGormErrorResponse(err)
+	}
+	return admin, nil
+}
+
+func checkPermissions(permissions []string) *dto.Response {
+	for _, perm := range permissions {
+		if !model.ValidPermission(perm) {
+			return utils.GenerateBadRequestErrorResponse(fmt.Errorf("unknown permission %q", perm))
+		}
+	}
+	return nil
+}
+
+func roleErrorResponse(err error) *dto.Response {
+	switch {
+	case errors.Is(err, repository.ErrBuiltinRole):
+		return utils.GenerateForbiddenResponse(err)
+	case errors.Is(err, repository.ErrLastSuperAdmin):
+		return utils.GenerateConflictResponse(err)
+	}
+	return utils.GenerateGormErrorResponse(err)
+}
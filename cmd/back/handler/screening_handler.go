@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type screeningHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func newScreeningHandler(h *Handler) *screeningHandler {
+	return &screeningHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+	}
+}
+
+func (ctr *screeningHandler) register() {
+	group := ctr.R.Group("/api/screening")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaScreening))
+	group.GET("", ctr.getStatus)
+	group.POST("/reload", ctr.reload)
+	group.GET("/hits", ctr.getHits)
+	group.GET("/deposits", ctr.getHeldDeposits)
+	group.POST("/deposits/:id/release", ctr.releaseDeposit)
+	group.POST("/deposits/:id/reject", ctr.rejectDeposit)
+}
+
+// getStatus shows the denylists loaded by this process
+func (ctr *screeningHandler) getStatus(c *gin.Context) {
+	res := utils.GenerateSuccessResponse(ctr.svc.Screen.Status())
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// reload reads the denylist files again right away, the other processes
+// pick the change up on their next refresh
+func (ctr *screeningHandler) reload(c *gin.Context) {
+	if err := ctr.svc.Screen.Reload(); err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(ctr.svc.Screen.Status())
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *screeningHandler) getHits(c *gin.Context) {
+	req := dto.ScreeningHitListReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list, total, err := ctr.repo.Screening.ListHits(c.Request.Context(), &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := gin.H{
+		"list":  list,
+		"total": total,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *screeningHandler) getHeldDeposits(c *gin.Context) {
+	req := dto.PageReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list, total, err := ctr.repo.Deposit.ListHeld(c.Request.Context(), &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := gin.H{
+		"list":  list,
+		"total": total,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// releaseDeposit clears a held deposit, the worker confirms and credits it
+// like any other
+func (ctr *screeningHandler) releaseDeposit(c *gin.Context) {
+	ctr.review(c, model.DepositStatusDetected)
+}
+
+// rejectDeposit keeps a held deposit from ever being credited
+func (ctr *screeningHandler) rejectDeposit(c *gin.Context) {
+	ctr.review(c, model.DepositStatusRejected)
+}
+
+func (ctr *screeningHandler) review(c *gin.Context, status string) {
+	admin := c.MustGet("admin").(*model.Admin)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	deposit, err := ctr.repo.Deposit.FindByID(c.Request.Context(), id)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	reviewed, err := ctr.repo.Deposit.Review(c.Request.Context(), deposit, status, *admin.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !reviewed {
+		res := utils.GenerateConflictResponse(errors.New("deposit is not held"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	log.Printf("held deposit %d set to %s by admin %d\n", deposit.ID, status, *admin.ID)
+
+	res := utils.GenerateSuccessResponse(deposit)
+	c.JSON(res.HttpStatusCode, res)
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// twoFactorHandler lets support reset the 2fa of users and admins that lost
+// both their authenticator and their recovery codes. The acting admin
+// confirms with their own code and every reset is audited, resets of admins
+// also need other admins to approve them.
+type twoFactorHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func newTwoFactorHandler(h *Handler) *twoFactorHandler {
+	return &twoFactorHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+	}
+}
+
+func (ctr *twoFactorHandler) register() {
+	group := ctr.R.Group("/api/two-factor")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+
+	users := group.Group("")
+	users.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaUsers))
+	users.POST("/users/:id/reset", middleware.AdminFactorMiddleware(ctr.repo), ctr.resetUser)
+
+	admins := group.Group("")
+	admins.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaAdmins))
+	admins.POST("/admins/:id/reset", middleware.AdminFactorMiddleware(ctr.repo), ctr.resetAdmin)
+}
+
+// resetUser turns the 2fa of a user off and signs them out everywhere, they
+// set it up again on their next login
+func (ctr *twoFactorHandler) resetUser(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	ctx := c.Request.Context()
+	user, err := ctr.repo.User.FindByField("id", c.Param("id"))
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	before := gin.H{"state": user.TwoFactorState()}
+
+	updateFields := &model.UpdateFields{
+		Field: "id",
+		Value: user.ID,
+		Data: map[string]any{
+			"otp_enabled":        false,
+			"otp_secret":         "",
+			"otp_pending_secret": "",
+			"otp_changed_at":     time.Now(),
+		},
+	}
+	if _, err := ctr.repo.User.UpdateByFields(updateFields); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Recovery.Delete(ctx, model.AuditActorUser, user.ID.String()); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Token.RevokeOtherSessions(ctx, user.Username, false, ""); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	log.Printf("2fa of user %s reset by admin %d\n", user.ID, *admin.ID)
+	middleware.Audit(c, fmt.Sprintf("user:%s", user.ID), before, gin.H{"state": model.TwoFactorDisabled})
+	mailReset(ctr.svc, user.Email, user.Name)
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// resetAdmin proposes the same for another admin, removing their passkeys
+// too. It runs once other admins approve it, admins reset their own 2fa
+// through the auth routes.
+func (ctr *twoFactorHandler) resetAdmin(c *gin.Context) {
+	admin := c.MustGet("admin").(*model.Admin)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if id == *admin.ID {
+		res := utils.GenerateForbiddenResponse(errors.New("admins can't reset their own 2fa here"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if _, err := ctr.repo.Admin.FindByField("id", strconv.FormatUint(id, 10)); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := propose(c, ctr.repo, model.ProposalAdminTwoFactorReset, fmt.Sprintf("admin:%d", id), adminResetProposal{AdminID: id})
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// adminResetProposal is the payload of an admin 2fa reset
+type adminResetProposal struct {
+	AdminID uint64 `json:"admin_id"`
+}
+
+// resetAdminTwoFactor turns the 2fa of the admin off, removes their passkeys
+// and recovery codes and signs them out everywhere
+func resetAdminTwoFactor(ctx context.Context, repo *repository.Repository, svc *service.Service, id uint64) error {
+	target, err := repo.Admin.FindByField("id", strconv.FormatUint(id, 10))
+	if err != nil {
+		return err
+	}
+
+	updateFields := &model.UpdateFields{
+		Field: "id",
+		Value: target.ID,
+		Data: map[string]any{
+			"otp_enabled":        false,
+			"otp_secret":         nil,
+			"otp_pending_secret": nil,
+			"passkey_required":   false,
+		},
+	}
+	if _, err := repo.Admin.UpdateByFields(ctx, updateFields); err != nil {
+		return err
+	}
+	if err := repo.Recovery.Delete(ctx, model.AuditActorAdmin, fmt.Sprint(id)); err != nil {
+		return err
+	}
+	if err := repo.WebAuthn.DeleteAll(ctx, id); err != nil {
+		return err
+	}
+	if err := repo.Token.RevokeOtherSessions(ctx, *target.Username, true, ""); err != nil {
+		return err
+	}
+	mailReset(svc, *target.Email, *target.Name)
+	return nil
+}
+
+// mailReset is best effort, the reset is already done
+func mailReset(svc *service.Service, email, name string) {
+	subject := "Your two-factor authentication was reset"
+	body := fmt.Sprintf("Hello %s,\n\nSupport reset the two-factor authentication of your account and signed it out everywhere. "+
+		"Set it up again after your next sign-in. If you didn't ask for this, contact support right away.\n", name)
+	if err := svc.Mail.Send(email, subject, body); err != nil {
+		log.Println(err, "Error sending mail to ", email)
+	}
+}
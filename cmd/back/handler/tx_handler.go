@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+type txHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func newTxHandler(h *Handler) *txHandler {
+	return &txHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+	}
+}
+
+func (ctr *txHandler) register() {
+	group := ctr.R.Group("/api/transactions")
+	group.Use(middleware.AdminAuthMiddleware(ctr.repo))
+	group.Use(middleware.PermissionMiddleware(ctr.repo, model.PermAreaTx))
+
+	group.GET("", ctr.getTransactions)
+	group.POST("/speed-up", middleware.IdempotencyMiddleware(ctr.repo), ctr.speedUp)
+	group.POST("/cancel", middleware.IdempotencyMiddleware(ctr.repo), ctr.cancel)
+}
+
+func (ctr *txHandler) getTransactions(c *gin.Context) {
+	req := dto.TransactionListReq{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list, total, err := ctr.repo.Tx.List(c.Request.Context(), &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := gin.H{
+		"list":  list,
+		"total": total,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *txHandler) speedUp(c *gin.Context) {
+	ctr.replace(c, model.TxTypeSpeedUp)
+}
+
+func (ctr *txHandler) cancel(c *gin.Context) {
+	ctr.replace(c, model.TxTypeCancel)
+}
+
+// replace sends a transaction with the same nonce as a pending one, signed by
+// the bank that sent the original, and links both in the history.
+func (ctr *txHandler) replace(c *gin.Context, txType string) {
+	req := dto.ReplaceTxReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	original, err := ctr.findOrTrack(c, req.TxHash)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if original.Network != "ERC20" {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("only ERC20 transactions can be replaced"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if original.State != model.StateTransfer {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("transaction is not pending"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	bank, err := ctr.repo.Bank.FindByAddress(original.FromAddress)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	privateKey, err := utils.DecryptAES(*bank.PrivateKey)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	signedTx, err := ctr.svc.ERC20.ReplaceTransaction(privateKey, original.TxHash, req.BumpPercent, txType == model.TxTypeCancel)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	replacement := &model.Transaction{
+		BankID:      bank.ID,
+		TxHash:      signedTx.Hash().Hex(),
+		Type:        txType,
+		Network:     original.Network,
+		Currency:    original.Currency,
+		FromAddress: original.FromAddress,
+		ToAddress:   original.ToAddress,
+		Amount:      original.Amount,
+		Nonce:       signedTx.Nonce(),
+		GasLimit:    signedTx.Gas(),
+		GasPrice:    signedTx.GasFeeCap().String(),
+		GasTipCap:   signedTx.GasTipCap().String(),
+		State:       model.StateTransfer,
+	}
+	if txType == model.TxTypeCancel {
+		replacement.Currency = "ETH"
+		replacement.ToAddress = original.FromAddress
+		replacement.Amount = 0
+	}
+
+	// the replacement is already broadcast, so a failure here only loses the
+	// link. Answering with an error would let a retry broadcast another one.
+	if err := ctr.repo.Tx.Replace(c.Request.Context(), original, replacement); err != nil {
+		log.Println(err, "Error saving replacement transaction ", replacement.TxHash)
+	}
+
+	res := utils.GenerateSuccessResponse(replacement)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// findOrTrack returns the history entry for txHash. Transactions sent by a bank
+// outside of the history are picked up from the chain so they can be replaced.
+func (ctr *txHandler) findOrTrack(c *gin.Context, txHash string) (*model.Transaction, error) {
+	tx, err := ctr.repo.Tx.FindByHash(c.Request.Context(), txHash)
+	if err == nil || !utils.IsErrNotFound(err) {
+		return tx, err
+	}
+
+	chainTx, from, err := ctr.svc.ERC20.GetTransaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+	bank, err := ctr.repo.Bank.FindByAddress(from)
+	if err != nil {
+		if utils.IsErrNotFound(err) {
+			return nil, errors.New("transaction was not sent by a bank")
+		}
+		return nil, err
+	}
+
+	tx = &model.Transaction{
+		BankID:      bank.ID,
+		TxHash:      chainTx.Hash().Hex(),
+		Type:        model.TxTypeTransfer,
+		Network:     "ERC20",
+		Currency:    "ETH",
+		FromAddress: from,
+		Nonce:       chainTx.Nonce(),
+		GasLimit:    chainTx.Gas(),
+		GasPrice:    chainTx.GasFeeCap().String(),
+		GasTipCap:   chainTx.GasTipCap().String(),
+		State:       model.StateTransfer,
+	}
+	if to := chainTx.To(); to != nil {
+		tx.ToAddress = to.Hex()
+	}
+	if len(chainTx.Data()) > 0 {
+		tx.Currency = "USDT"
+	}
+	if err := ctr.repo.Tx.Create(c.Request.Context(), tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
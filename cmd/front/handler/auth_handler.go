@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"cryptoshare/conf"
 	"cryptoshare/dto"
 	"cryptoshare/middleware"
 	"cryptoshare/model"
 	"cryptoshare/repository"
+	"cryptoshare/service"
 	"cryptoshare/utils"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
@@ -16,25 +20,38 @@ import (
 type authHandler struct {
 	R    *gin.Engine
 	repo *repository.Repository
+	svc  *service.Service
 }
 
 func newAuthHandler(h *Handler) *authHandler {
 	return &authHandler{
 		R:    h.R,
 		repo: h.repo,
+		svc:  h.svc,
 	}
 }
 
 func (ctr *authHandler) register() {
 	group := ctr.R.Group("/api/auth")
-	group.POST("/login", ctr.login)
-	group.POST("/register", ctr.singup)
+	group.POST("/login", middleware.GeoMiddleware(ctr.repo, middleware.GeoPolicyLogin), ctr.login)
+	group.POST("/register", middleware.GeoMiddleware(ctr.repo, middleware.GeoPolicySignup), ctr.singup)
+	group.POST("/refresh", ctr.refresh)
+	group.POST("/logout", middleware.SessionMiddleware(), ctr.logout)
+	group.POST("/verify-email", ctr.verifyEmail)
+	group.POST("/verify-email/resend", ctr.resendVerification)
+	group.POST("/password/forgot", ctr.forgotPassword)
+	group.POST("/password/reset", ctr.resetPassword)
 	group.Use(middleware.AuthMiddleware(ctr.repo))
 
-	group.POST("/refresh", ctr.refresh)
-	group.POST("/logout", ctr.logout)
+	group.GET("/2fa", ctr.getTwoFactor)
 	group.POST("/generate/secret-key", ctr.generateSecretKey)
-	group.POST("/enable/2fa", middleware.OTPMiddleware("admin"), ctr.enable2FactorAuth)
+	group.POST("/enable/2fa", ctr.enable2FactorAuth)
+	group.POST("/disable/2fa", ctr.disable2FactorAuth)
+	group.POST("/2fa/recovery-codes", ctr.resetRecoveryCodes)
+	group.POST("/password", ctr.changePassword)
+	group.GET("/sessions", ctr.getSessions)
+	group.DELETE("/sessions", ctr.revokeOtherSessions)
+	group.DELETE("/sessions/:id", ctr.revokeSession)
 }
 func (ctr *authHandler) singup(c *gin.Context) {
 	req := dto.SingupReq{}
@@ -71,11 +88,209 @@ func (ctr *authHandler) singup(c *gin.Context) {
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	// the account can't sign in until the address is verified
+	ctr.mailToken(c, user, model.UserTokenVerifyEmail)
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+
+}
+
+// verifyEmail spends a verification token and activates the account
+func (ctr *authHandler) verifyEmail(c *gin.Context) {
+	req := dto.VerifyEmailReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	token, err := ctr.repo.UserToken.Consume(c.Request.Context(), req.Token, model.UserTokenVerifyEmail)
+	if err != nil {
+		res := userTokenErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	updateFields := &model.UpdateFields{
+		Field: "id",
+		Value: token.UserID,
+		Data: map[string]any{
+			"email_verified_at": time.Now(),
+		},
+	}
+	if _, err := ctr.repo.User.UpdateByFields(updateFields); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// resendVerification mails a new verification token. It answers the same
+// for unknown or verified addresses.
+func (ctr *authHandler) resendVerification(c *gin.Context) {
+	req := dto.EmailReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	user, err := ctr.repo.User.FindByField("email", req.Email)
+	if err != nil && !utils.IsErrNotFound(err) {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err == nil && user.EmailVerifiedAt == nil {
+		ctr.mailToken(c, user, model.UserTokenVerifyEmail)
+	}
 
 	res := utils.GenerateSuccessResponse(nil)
 	c.JSON(res.HttpStatusCode, res)
+}
+
+// forgotPassword mails a password reset token. It answers the same whether
+// the address has an account or not.
+func (ctr *authHandler) forgotPassword(c *gin.Context) {
+	req := dto.EmailReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	user, err := ctr.repo.User.FindByField("email", req.Email)
+	if err != nil && !utils.IsErrNotFound(err) {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err == nil {
+		ctr.mailToken(c, user, model.UserTokenResetPassword)
+	}
 
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
 }
+
+// resetPassword spends a reset token and sets the new password. Every
+// session of the account is signed out and its login lock is lifted.
+func (ctr *authHandler) resetPassword(c *gin.Context) {
+	req := dto.ResetPasswordReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	ctx := c.Request.Context()
+
+	hash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	token, err := ctr.repo.UserToken.Consume(ctx, req.Token, model.UserTokenResetPassword)
+	if err != nil {
+		res := userTokenErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	user, err := ctr.repo.User.FindByField("id", token.UserID.String())
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	middleware.AuditActor(c, model.AuditActorUser, user.ID.String(), user.Username)
+
+	now := time.Now()
+	data := map[string]any{
+		"password":            hash,
+		"password_changed_at": now,
+	}
+	// the token came through the address, so it is verified too
+	if user.EmailVerifiedAt == nil {
+		data["email_verified_at"] = now
+	}
+	updateFields := &model.UpdateFields{
+		Field: "id",
+		Value: user.ID,
+		Data:  data,
+	}
+	if _, err := ctr.repo.User.UpdateByFields(updateFields); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Token.RevokeOtherSessions(ctx, user.Username, false, ""); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	account := repository.LoginAccount(model.AuditActorUser, user.ID.String())
+	if err := ctr.repo.Lockout.Unlock(ctx, account); err != nil {
+		log.Println(err, "Error unlocking ", account)
+	}
+	middleware.ClearTokenCookies(c)
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// mailToken issues a token of purpose and mails its link, at most once per
+// EmailResendInterval. It is best effort, failures are only logged so the
+// answer doesn't tell anything about the account.
+func (ctr *authHandler) mailToken(c *gin.Context, user *model.User, purpose string) {
+	ctx := c.Request.Context()
+	send, err := ctr.repo.UserToken.Throttle(ctx, user.ID, purpose)
+	if err != nil || !send {
+		if err != nil {
+			log.Println(err, "Error throttling mail to ", user.Email)
+		}
+		return
+	}
+
+	var subject, body string
+	switch purpose {
+	case model.UserTokenVerifyEmail:
+		token, err := ctr.repo.UserToken.Issue(ctx, user.ID, purpose, conf.EmailVerifyTTL)
+		if err != nil {
+			log.Println(err, "Error issuing token for ", user.Email)
+			return
+		}
+		subject = "Verify your email"
+		body = fmt.Sprintf("Hello %s,\n\nConfirm your email address to activate your account:\n\n"+
+			"https://%s/verify-email?token=%s\n\nThe link expires in %s.\n", user.Name, conf.AppHost, token, conf.EmailVerifyTTL)
+	case model.UserTokenResetPassword:
+		token, err := ctr.repo.UserToken.Issue(ctx, user.ID, purpose, conf.PasswordResetTTL)
+		if err != nil {
+			log.Println(err, "Error issuing token for ", user.Email)
+			return
+		}
+		subject = "Reset your password"
+		body = fmt.Sprintf("Hello %s,\n\nSomeone asked to reset the password of your account. If it was you, "+
+			"set a new one here:\n\nhttps://%s/reset-password?token=%s\n\nThe link expires in %s and works once. "+
+			"Otherwise you can ignore this email.\n", user.Name, conf.AppHost, token, conf.PasswordResetTTL)
+	}
+	if err := ctr.svc.Mail.Send(user.Email, subject, body); err != nil {
+		log.Println(err, "Error sending mail to ", user.Email)
+	}
+}
+
+func userTokenErrorResponse(err error) *dto.Response {
+	if errors.Is(err, repository.ErrInvalidUserToken) {
+		return utils.GenerateBadRequestErrorResponse(err)
+	}
+	return utils.GenerateServerError(err)
+}
+
+// login answers every failure the same way, whether the account exists or
+// not. Failures are counted per account and IP, each one waits a little
+// longer and too many lock the account for a while.
 func (ctr *authHandler) login(c *gin.Context) {
 	req := dto.LoginReq{}
 	res := &dto.Response{}
@@ -84,79 +299,162 @@ func (ctr *authHandler) login(c *gin.Context) {
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	ctx := c.Request.Context()
+
+	account := repository.LoginAccount(model.AuditActorUser, req.Email)
 	user, err := ctr.repo.User.FindByField("email", req.Email)
-	if err != nil {
-		res := utils.GenerateGormErrorResponse(err)
+	if err != nil && !utils.IsErrNotFound(err) {
+		res := utils.GenerateServerError(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	if err == nil {
+		account = repository.LoginAccount(model.AuditActorUser, user.ID.String())
+	} else {
+		user = nil
+	}
 
-	// otp validation
-	if user.OTPEnabled && req.OTP == "" {
-		res.ErrCode = 400
-		res.ErrMsg = "OTP is required."
-		c.JSON(http.StatusBadRequest, res)
+	if res := middleware.LoginLocked(c, ctr.repo, account); res != nil {
+		c.JSON(res.HttpStatusCode, res)
 		return
 	}
 
-	if user.OTPEnabled {
-		validOTP := utils.Validate2fa(req.OTP, user.OTPSecret)
-		if !validOTP {
+	var valid bool
+	if user == nil {
+		valid = utils.CheckNoPassword(req.Password)
+	} else {
+		valid = utils.CheckPasswordHash(req.Password, user.Password)
+	}
+
+	// otp validation, only asked for once the password is right. A recovery
+	// code does as well.
+	if valid && user.OTPEnabled {
+		if req.OTP == "" {
 			res.ErrCode = 400
-			res.ErrMsg = "invalid otp"
+			res.ErrMsg = "OTP is required."
 			c.JSON(http.StatusBadRequest, res)
 			return
 		}
+		valid, err = ctr.repo.Recovery.Check(ctx, model.AuditActorUser, user.ID.String(), user.OTPSecret, req.OTP)
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
 	}
 
-	validPassword := utils.CheckPasswordHash(req.Password, user.Password)
-	if !validPassword {
-		res.ErrCode = 400
-		res.ErrMsg = "invalid password"
-		c.JSON(http.StatusBadRequest, res)
+	if !valid {
+		locked := middleware.LoginFailed(c, ctr.repo, account)
+		if locked && user != nil {
+			ctr.mailLockout(user)
+		}
+		res := utils.GenerateInvalidCredentialsResponse()
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Lockout.Succeed(ctx, account); err != nil {
+		log.Println(err, "Error clearing login failures ", account)
+	}
+	middleware.AuditActor(c, model.AuditActorUser, user.ID.String(), user.Username)
+	if user.EmailVerifiedAt == nil {
+		res := utils.GenerateForbiddenResponse(errors.New("email is not verified"))
+		c.JSON(res.HttpStatusCode, res)
 		return
 	}
 
-	accessToken, err := utils.GenerateAccessToken(user.Username, false)
+	session := middleware.NewSession(c, user.Username, false)
+	if err := ctr.repo.Risk.SeenDevice(c.Request.Context(), user.ID, session.Device, c.ClientIP(), time.Now()); err != nil {
+		log.Println(err, "Error saving device")
+	}
+
+	tokens, err := ctr.repo.Token.Issue(c.Request.Context(), session)
 	if err != nil {
 		res := utils.GenerateServerError(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
-	// Finally, we set the client cookie for "token" as the JWT we just generated
-	// we also set an expiry time which is the same as the token itself
-	c.SetCookie("token", accessToken, int(time.Minute)*5, "/", c.Request.Host, true, true)
+	middleware.SetTokenCookies(c, tokens)
 
-	res = utils.GenerateSuccessResponse(accessToken)
+	res = utils.GenerateSuccessResponse(tokens)
 	c.JSON(res.HttpStatusCode, res)
+}
 
+// mailLockout is best effort, the lock is already in place
+func (ctr *authHandler) mailLockout(user *model.User) {
+	subject := "Your account was locked"
+	body := fmt.Sprintf("Hello %s,\n\nThere were too many failed sign-in attempts on your account, so it is locked for %s. "+
+		"If this wasn't you, change your password once the lock ends.\n", user.Name, conf.LoginLockout)
+	if err := ctr.svc.Mail.Send(user.Email, subject, body); err != nil {
+		log.Println(err, "Error sending mail to ", user.Email)
+	}
 }
 
 func (ctr *authHandler) refresh(c *gin.Context) {
-	token, _ := c.Cookie("token")
-	refreshToken, err := utils.GenerateRefreshToken(token)
+	tokens, err := ctr.repo.Token.Refresh(c.Request.Context(), middleware.RefreshTokenFrom(c))
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidRefreshToken) || errors.Is(err, repository.ErrRefreshTokenReused) {
+			middleware.ClearTokenCookies(c)
+			res := utils.GenerateAuthErrorResponse(err)
+			res.ErrMsg = err.Error()
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
 		res := utils.GenerateServerError(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
-	c.SetCookie("token", refreshToken, int(time.Minute)*5, "/", c.Request.Host, true, true)
+	middleware.SetTokenCookies(c, tokens)
 
-	res := utils.GenerateSuccessResponse(refreshToken)
+	res := utils.GenerateSuccessResponse(tokens)
 	c.JSON(res.HttpStatusCode, res)
-
 }
 
+// logout ends the session of the refresh token, or of the access token when
+// the client only has that one
 func (ctr *authHandler) logout(c *gin.Context) {
-	// immediately clear the token cookie
-	c.SetCookie("token", "", 0, "/", c.Request.Host, true, true)
+	var err error
+	if raw := middleware.RefreshTokenFrom(c); raw != "" {
+		err = ctr.repo.Token.Revoke(c.Request.Context(), raw)
+	} else if session := c.GetString("session_id"); session != "" {
+		err = ctr.repo.Token.RevokeSession(c.Request.Context(), session)
+	}
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	// immediately clear the token cookies
+	middleware.ClearTokenCookies(c)
 
 	res := utils.GenerateSuccessResponse("successfully logged out")
 	c.JSON(res.HttpStatusCode, res)
 }
 
+func (ctr *authHandler) getTwoFactor(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	left, err := ctr.repo.Recovery.Remaining(c.Request.Context(), model.AuditActorUser, user.ID.String())
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(&dto.TwoFactorStatusResp{
+		State:             user.TwoFactorState(),
+		RecoveryCodesLeft: left,
+	})
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// generateSecretKey starts a 2fa setup, the secret stays pending until
+// enable2FactorAuth confirms it. An enabled 2fa must be disabled first.
 func (ctr *authHandler) generateSecretKey(c *gin.Context) {
 	user := c.MustGet("user").(*model.User)
+	if user.OTPEnabled {
+		res := utils.GenerateConflictResponse(errors.New("2fa is already enabled"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
 	key, err := utils.Create2fa(user.Username)
 	if err != nil {
 		res := utils.GenerateServerError(err)
@@ -167,7 +465,7 @@ func (ctr *authHandler) generateSecretKey(c *gin.Context) {
 		Field: "id",
 		Value: user.ID,
 		Data: map[string]any{
-			"otp_secret": key,
+			"otp_pending_secret": key.Secret(),
 		},
 	}
 	_, err = ctr.repo.User.UpdateByFields(updateFields)
@@ -176,26 +474,231 @@ func (ctr *authHandler) generateSecretKey(c *gin.Context) {
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
-	res := utils.GenerateSuccessResponse(key)
+
+	res := utils.GenerateSuccessResponse(&dto.TwoFactorSetupResp{
+		OTPSecret:  key.Secret(),
+		OTPAuthURL: key.URL(),
+	})
 	c.JSON(res.HttpStatusCode, res)
 }
 
+// enable2FactorAuth confirms the pending setup with its first code and
+// returns the recovery codes, they are not shown again
 func (ctr *authHandler) enable2FactorAuth(c *gin.Context) {
-	admin := c.MustGet("admin").(*model.Admin)
+	user := c.MustGet("user").(*model.User)
+	req := dto.OTPReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if user.TwoFactorState() != model.TwoFactorPending {
+		res := utils.GenerateConflictResponse(fmt.Errorf("2fa is %s, there is no setup to confirm", user.TwoFactorState()))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !utils.Validate2fa(req.OTP, user.OTPPendingSecret) {
+		res := utils.GenerateWrongOTPResponse(nil)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	updateFields := &model.UpdateFields{
+		Field: "id",
+		Value: user.ID,
+		Data: map[string]any{
+			"otp_enabled":        true,
+			"otp_secret":         user.OTPPendingSecret,
+			"otp_pending_secret": "",
+			"otp_changed_at":     time.Now(),
+		},
+	}
+	if _, err := ctr.repo.User.UpdateByFields(updateFields); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	codes, err := ctr.repo.Recovery.Replace(c.Request.Context(), model.AuditActorUser, user.ID.String())
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(&dto.RecoveryCodesResp{RecoveryCodes: codes})
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// disable2FactorAuth turns 2fa off given the password and a code, the other
+// sessions are signed out as the account just lost a factor
+func (ctr *authHandler) disable2FactorAuth(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	if res := ctr.checkBothFactors(c, user); res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	updateFields := &model.UpdateFields{
+		Field: "id",
+		Value: user.ID,
+		Data: map[string]any{
+			"otp_enabled":        false,
+			"otp_secret":         "",
+			"otp_pending_secret": "",
+			"otp_changed_at":     time.Now(),
+		},
+	}
+	_, err := ctr.repo.User.UpdateByFields(updateFields)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Recovery.Delete(c.Request.Context(), model.AuditActorUser, user.ID.String()); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Token.RevokeOtherSessions(c.Request.Context(), user.Username, false, c.GetString("session_id")); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// resetRecoveryCodes replaces the recovery codes given the password and a
+// code, the old ones stop working
+func (ctr *authHandler) resetRecoveryCodes(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	if res := ctr.checkBothFactors(c, user); res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	codes, err := ctr.repo.Recovery.Replace(c.Request.Context(), model.AuditActorUser, user.ID.String())
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(&dto.RecoveryCodesResp{RecoveryCodes: codes})
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// checkBothFactors binds a TwoFactorReq and checks it against the enabled
+// 2fa, the code may be a recovery code
+func (ctr *authHandler) checkBothFactors(c *gin.Context, user *model.User) *dto.Response {
+	req := dto.TwoFactorReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		return utils.GenerateValidationErrorResponse(err)
+	}
+	if !user.OTPEnabled {
+		return utils.GenerateConflictResponse(errors.New("2fa is not enabled"))
+	}
+	if !utils.CheckPasswordHash(req.Password, user.Password) {
+		return utils.GenerateBadRequestErrorResponse(errors.New("invalid password"))
+	}
+	valid, err := ctr.repo.Recovery.Check(c.Request.Context(), model.AuditActorUser, user.ID.String(), user.OTPSecret, req.OTP)
+	if err != nil {
+		return utils.GenerateServerError(err)
+	}
+	if !valid {
+		return utils.GenerateWrongOTPResponse(nil)
+	}
+	return nil
+}
 
+// changePassword sets a new password and signs out every other session
+func (ctr *authHandler) changePassword(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.ChangePasswordReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !utils.CheckPasswordHash(req.CurrentPassword, user.Password) {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("invalid password"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	hash, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
 	updateFields := &model.UpdateFields{
 		Field: "id",
-		Value: admin.ID,
+		Value: user.ID,
 		Data: map[string]any{
-			"otp_enabled": true,
+			"password":            hash,
+			"password_changed_at": time.Now(),
 		},
 	}
-	_, err := ctr.repo.Admin.UpdateByFields(c.Request.Context(), updateFields)
+	if _, err := ctr.repo.User.UpdateByFields(updateFields); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Token.RevokeOtherSessions(c.Request.Context(), user.Username, false, c.GetString("session_id")); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *authHandler) getSessions(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	list, err := ctr.repo.Token.ListSessions(c.Request.Context(), user.Username, false)
 	if err != nil {
 		res := utils.GenerateGormErrorResponse(err)
 		c.JSON(res.HttpStatusCode, res)
 		return
 	}
+	middleware.MarkCurrentSession(c, list)
+
+	res := utils.GenerateSuccessResponse(list)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *authHandler) revokeSession(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	session, err := ctr.repo.Token.FindSession(c.Request.Context(), c.Param("id"), user.Username, false)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := ctr.repo.Token.RevokeSession(c.Request.Context(), session.ID); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if session.ID == c.GetString("session_id") {
+		middleware.ClearTokenCookies(c)
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// revokeOtherSessions signs out everywhere but the current session
+func (ctr *authHandler) revokeOtherSessions(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	if err := ctr.repo.Token.RevokeOtherSessions(c.Request.Context(), user.Username, false, c.GetString("session_id")); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
 
 	res := utils.GenerateSuccessResponse(nil)
 	c.JSON(res.HttpStatusCode, res)
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type feeHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func newFeeHandler(h *Handler) *feeHandler {
+	return &feeHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+	}
+}
+
+func (ctr *feeHandler) register() {
+	group := ctr.R.Group("/api/fees")
+	group.Use(middleware.AuthMiddleware(ctr.repo))
+
+	group.GET("/estimate", ctr.estimate)
+}
+
+// estimate quotes the fee of a transfer and locks it for conf.FeeQuoteTTL,
+// the returned quote_id can be passed to the withdrawal endpoint.
+func (ctr *feeHandler) estimate(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.FeeEstimateReq{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !service.IsNetworkCurrency(req.Network, req.Currency) {
+		res := utils.GenerateBadRequestErrorResponse(service.ErrUnsupportedCurrency)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	// gas is estimated from the user's wallet when there is one
+	from := ""
+	wallet, err := ctr.repo.Wallet.FindByUserAndNetwork(c.Request.Context(), user.ID, req.Network)
+	if err == nil {
+		from = wallet.Address
+	} else if !utils.IsErrNotFound(err) {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	estimate, err := ctr.svc.EstimateFee(req.Network, req.Currency, from, req.To, req.Amount)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	price, err := service.FreshRate(c.Request.Context(), ctr.svc.Price, estimate.NativeCurrency, "USDT", conf.PriceMaxAge)
+	if err != nil {
+		res := utils.GenerateServiceUnavailableResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	quote := &dto.FeeQuoteResp{
+		QuoteID:        uuid.NewString(),
+		Network:        req.Network,
+		Currency:       req.Currency,
+		Amount:         req.Amount,
+		To:             req.To,
+		NativeCurrency: estimate.NativeCurrency,
+		FiatCurrency:   "USDT",
+		GasLimit:       estimate.GasLimit,
+		Tiers:          map[string]*dto.FeeTierResp{},
+		ExpiresAt:      time.Now().Add(conf.FeeQuoteTTL),
+	}
+	for tier, gasPrice := range estimate.GasPrices {
+		fee := utils.FromBaseUnits(estimate.Fee(tier), estimate.Decimals)
+		quote.Tiers[tier] = &dto.FeeTierResp{
+			GasPrice:  gasPrice.String(),
+			Fee:       fee,
+			FiatValue: fee * price,
+		}
+	}
+
+	if err := ctr.repo.FeeQuote.Lock(c.Request.Context(), user.ID.String(), quote, conf.FeeQuoteTTL); err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(quote)
+	c.JSON(res.HttpStatusCode, res)
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"cryptoshare/ds"
 	"cryptoshare/middleware"
+	"cryptoshare/payment"
 	"cryptoshare/repository"
 	"cryptoshare/service"
 	"cryptoshare/utils"
@@ -16,6 +17,7 @@ type Handler struct {
 	R    *gin.Engine
 	repo *repository.Repository
 	svc  *service.Service
+	pay  *payment.Payments
 }
 
 type HConfig struct {
@@ -24,16 +26,20 @@ type HConfig struct {
 }
 
 func NewHandler(c *HConfig) *Handler {
-	svc := service.NewService()
+	svc := service.NewService(c.DS.RDB)
 	repo := repository.NewRepository(c.DS, svc)
 	return &Handler{
 		R:    c.R,
 		repo: repo,
+		svc:  svc,
+		pay:  payment.New(repo, svc),
 	}
 }
 
 func (h *Handler) Register() {
 	h.R.Use(middleware.Cors())
+	h.R.Use(middleware.GeoMiddleware(h.repo, middleware.GeoPolicyDefault))
+	h.R.Use(middleware.AuditMiddleware(h.repo))
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("checkphrase", utils.Checkphrase)
@@ -46,4 +52,36 @@ func (h *Handler) Register() {
 	// wallet routes
 	walletHandler := newWalletHandler(h)
 	walletHandler.register()
+
+	// fee routes
+	feeHandler := newFeeHandler(h)
+	feeHandler.register()
+
+	// portfolio routes
+	portfolioHandler := newPortfolioHandler(h)
+	portfolioHandler.register()
+
+	// invoice routes
+	invoiceHandler := newInvoiceHandler(h)
+	invoiceHandler.register()
+
+	// merchant routes
+	merchantHandler := newMerchantHandler(h)
+	merchantHandler.register()
+
+	// webhook routes
+	webhookHandler := newWebhookHandler(h)
+	webhookHandler.register()
+
+	// scheduled transfer routes
+	scheduleHandler := newScheduleHandler(h)
+	scheduleHandler.register()
+
+	// withdrawal whitelist routes
+	whitelistHandler := newWhitelistHandler(h)
+	whitelistHandler.register()
+
+	// kyc routes
+	kycHandler := newKYCHandler(h)
+	kycHandler.register()
 }
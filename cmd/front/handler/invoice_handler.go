@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type invoiceHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func newInvoiceHandler(h *Handler) *invoiceHandler {
+	return &invoiceHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+	}
+}
+
+func (ctr *invoiceHandler) register() {
+	group := ctr.R.Group("/api/invoices")
+	// payers open the shared link without an account
+	group.GET("/:id", ctr.getInvoice)
+	group.GET("/:id/qrcode", ctr.getQRCode)
+
+	group.Use(middleware.AuthMiddleware(ctr.repo))
+	group.GET("", ctr.getInvoices)
+	group.POST("", ctr.createInvoice)
+}
+
+func (ctr *invoiceHandler) createInvoice(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.InvoiceCreateReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	invoice, err := createInvoice(c, ctr.repo, user, &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		if errors.Is(err, service.ErrUnsupportedCurrency) {
+			res = utils.GenerateBadRequestErrorResponse(err)
+		}
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(invoiceResp(invoice))
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *invoiceHandler) getInvoices(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.PageReq{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	invoices, total, err := ctr.repo.Invoice.ListByUser(c.Request.Context(), user.ID, &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list := make([]*dto.InvoiceResp, 0, len(invoices))
+	for _, invoice := range invoices {
+		list = append(list, invoiceResp(invoice))
+	}
+	data := gin.H{
+		"list":  list,
+		"total": total,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *invoiceHandler) getInvoice(c *gin.Context) {
+	invoice, err := ctr.repo.Invoice.FindByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(publicInvoiceResp(invoice))
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *invoiceHandler) getQRCode(c *gin.Context) {
+	invoice, err := ctr.repo.Invoice.FindByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	path, err := utils.QRCodePath(invoiceQRCodeName(invoice))
+	if err == nil {
+		// the png is generated on creation, rebuild it if storage was cleaned
+		if _, statErr := os.Stat(path); statErr != nil {
+			path, err = utils.CreateQRCode(invoiceQRCodeName(invoice), invoicePaymentURI(invoice))
+		}
+	}
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	c.File(path)
+}
+
+// createInvoice opens an invoice payable to the user's wallet on req.Network
+func createInvoice(c *gin.Context, repo *repository.Repository, user *model.User, req *dto.InvoiceCreateReq) (*model.Invoice, error) {
+	if !service.IsNetworkCurrency(req.Network, req.Currency) {
+		return nil, service.ErrUnsupportedCurrency
+	}
+
+	wallet, err := repo.Wallet.FindByUserAndNetwork(c.Request.Context(), user.ID, req.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := conf.InvoiceTTL
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	invoice := &model.Invoice{
+		UserID:    user.ID,
+		WalletID:  wallet.ID,
+		Network:   req.Network,
+		Currency:  req.Currency,
+		Address:   wallet.Address,
+		Amount:    req.Amount,
+		Memo:      req.Memo,
+		Status:    model.InvoiceStatusOpen,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := repo.Invoice.Create(c.Request.Context(), invoice); err != nil {
+		return nil, err
+	}
+
+	// the qrcode endpoint rebuilds it, so a failure here is not fatal
+	if _, err := utils.CreateQRCode(invoiceQRCodeName(invoice), invoicePaymentURI(invoice)); err != nil {
+		log.Println(err, "Error creating invoice qrcode")
+	}
+	return invoice, nil
+}
+
+func invoiceResp(invoice *model.Invoice) *dto.InvoiceResp {
+	return &dto.InvoiceResp{
+		Invoice:    invoice,
+		URL:        fmt.Sprintf("https://%s/pay/%s", conf.AppHost, invoice.ID),
+		PaymentURI: invoicePaymentURI(invoice),
+		QRCodeURL:  fmt.Sprintf("https://%s/api/invoices/%s/qrcode", conf.AppHost, invoice.ID),
+	}
+}
+
+// publicInvoiceResp is invoiceResp without the owner's user and wallet ids
+func publicInvoiceResp(invoice *model.Invoice) *dto.PublicInvoiceResp {
+	resp := invoiceResp(invoice)
+	return &dto.PublicInvoiceResp{
+		ID:         invoice.ID,
+		Network:    invoice.Network,
+		Currency:   invoice.Currency,
+		Address:    invoice.Address,
+		Amount:     invoice.Amount,
+		PaidAmount: invoice.PaidAmount,
+		Memo:       invoice.Memo,
+		Status:     invoice.Status,
+		TxHash:     invoice.TxHash,
+		ExpiresAt:  invoice.ExpiresAt,
+		PaidAt:     invoice.PaidAt,
+		CreatedAt:  invoice.CreatedAt,
+		URL:        resp.URL,
+		PaymentURI: resp.PaymentURI,
+		QRCodeURL:  resp.QRCodeURL,
+	}
+}
+
+func invoicePaymentURI(invoice *model.Invoice) string {
+	return service.PaymentURI(invoice.Network, invoice.Currency, invoice.Address, invoice.Amount)
+}
+
+func invoiceQRCodeName(invoice *model.Invoice) string {
+	return "invoice-" + invoice.ID.String()
+}
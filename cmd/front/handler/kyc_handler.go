@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// kycContentTypes are the document formats accepted, sniffed from the content
+var kycContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+type kycHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func newKYCHandler(h *Handler) *kycHandler {
+	return &kycHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+	}
+}
+
+func (ctr *kycHandler) register() {
+	group := ctr.R.Group("/api/kyc")
+	group.Use(middleware.AuthMiddleware(ctr.repo))
+	group.GET("", ctr.getStatus)
+	group.POST("", ctr.submit)
+}
+
+// getStatus shows the user's level and the last submission with its review
+func (ctr *kycHandler) getStatus(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	data := gin.H{
+		"level": user.KYCLevel,
+		"tier":  user.Tier,
+	}
+
+	submission, err := ctr.repo.KYC.Latest(c.Request.Context(), user.ID)
+	if err != nil && !utils.IsErrNotFound(err) {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err == nil {
+		data["submission"] = submission
+	}
+
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// submit saves the identity data and documents for review, a user has one
+// pending submission at a time
+func (ctr *kycHandler) submit(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.KYCSubmitReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if req.Level <= user.KYCLevel {
+		res := utils.GenerateBadRequestErrorResponse(fmt.Errorf("already at level %d", user.KYCLevel))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	pending, err := ctr.repo.KYC.HasPending(c.Request.Context(), user.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if pending {
+		res := utils.GenerateConflictResponse(errors.New("a submission is already waiting for review"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	files := make(map[string]*multipart.FileHeader)
+	for _, kind := range model.KYCDocumentKinds {
+		if file, err := c.FormFile(kind); err == nil {
+			files[kind] = file
+		}
+	}
+	if req.Level >= model.KYCLevelFull && (files[model.KYCDocumentFront] == nil || files[model.KYCDocumentSelfie] == nil) {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("level 2 needs the document front and a selfie"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	submission := &model.KYCSubmission{
+		UserID:         user.ID,
+		Level:          req.Level,
+		Status:         model.KYCPending,
+		FirstName:      req.FirstName,
+		LastName:       req.LastName,
+		DateOfBirth:    req.DateOfBirth,
+		Country:        req.Country,
+		Address:        req.Address,
+		DocumentType:   req.DocumentType,
+		DocumentNumber: req.DocumentNumber,
+	}
+	for _, kind := range model.KYCDocumentKinds {
+		file := files[kind]
+		if file == nil {
+			continue
+		}
+		document, err := ctr.store(c, user, kind, file)
+		if err != nil {
+			ctr.removeDocuments(c, submission.Documents)
+			res := utils.GenerateBadRequestErrorResponse(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+		submission.Documents = append(submission.Documents, document)
+	}
+
+	if err := ctr.repo.KYC.Create(c.Request.Context(), submission); err != nil {
+		ctr.removeDocuments(c, submission.Documents)
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(submission)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// store checks the size and format of an uploaded document and puts it in
+// the blob store
+func (ctr *kycHandler) store(c *gin.Context, user *model.User, kind string, file *multipart.FileHeader) (*model.KYCDocument, error) {
+	if file.Size > conf.KYCMaxFileSize {
+		return nil, fmt.Errorf("%s is bigger than %d bytes", kind, conf.KYCMaxFileSize)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	contentType := http.DetectContentType(head[:n])
+	if !kycContentTypes[contentType] {
+		return nil, fmt.Errorf("%s must be a jpeg, png or pdf", kind)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("kyc/%s/%s", user.ID, uuid.NewString())
+	if err := ctr.svc.Blob.Put(c.Request.Context(), key, f); err != nil {
+		return nil, err
+	}
+	return &model.KYCDocument{
+		Kind:        kind,
+		BlobKey:     key,
+		ContentType: contentType,
+		Size:        file.Size,
+	}, nil
+}
+
+func (ctr *kycHandler) removeDocuments(c *gin.Context, documents []*model.KYCDocument) {
+	for _, document := range documents {
+		if err := ctr.svc.Blob.Delete(c.Request.Context(), document.BlobKey); err != nil {
+			log.Println(err, "Error removing kyc document")
+		}
+	}
+}
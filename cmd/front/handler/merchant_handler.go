@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/payment"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type merchantHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	pay  *payment.Payments
+}
+
+func newMerchantHandler(h *Handler) *merchantHandler {
+	return &merchantHandler{
+		R:    h.R,
+		repo: h.repo,
+		pay:  h.pay,
+	}
+}
+
+func (ctr *merchantHandler) register() {
+	// merchant account and keys, managed by the user
+	group := ctr.R.Group("/api/merchants")
+	group.Use(middleware.AuthMiddleware(ctr.repo))
+	group.GET("", ctr.getMerchant)
+	group.POST("", ctr.createMerchant)
+	group.GET("/keys", ctr.getKeys)
+	group.POST("/keys", ctr.createKey)
+	group.DELETE("/keys/:id", ctr.revokeKey)
+
+	// merchant api, called by integrators with signed requests
+	api := ctr.R.Group("/api/merchant/v1")
+	api.Use(middleware.MerchantAuthMiddleware(ctr.repo))
+	api.GET("/balances", middleware.RequireScope(model.ScopeBalancesRead), ctr.getBalances)
+	api.POST("/invoices", middleware.RequireScope(model.ScopeInvoicesCreate), ctr.createInvoice)
+	api.POST("/payouts", middleware.RequireScope(model.ScopePayoutsCreate), middleware.GeoMiddleware(ctr.repo, middleware.GeoPolicyWithdrawal),
+		middleware.IdempotencyMiddleware(ctr.repo), ctr.createPayout)
+}
+
+func (ctr *merchantHandler) getMerchant(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	merchant, err := ctr.repo.Merchant.FindByUser(c.Request.Context(), user.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(merchant)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *merchantHandler) createMerchant(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.MerchantCreateReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	merchant := &model.Merchant{
+		UserID: user.ID,
+		Name:   req.Name,
+	}
+	if err := ctr.repo.Merchant.Create(c.Request.Context(), merchant); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(merchant)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *merchantHandler) getKeys(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	merchant, err := ctr.repo.Merchant.FindByUser(c.Request.Context(), user.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	keys, err := ctr.repo.Merchant.ListKeys(c.Request.Context(), merchant.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(keys)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// createKey issues a key and secret pair, the secret can't be shown again
+func (ctr *merchantHandler) createKey(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.APIKeyCreateReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	merchant, err := ctr.repo.Merchant.FindByUser(c.Request.Context(), user.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	keyPart, err := utils.RandomHex(16)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	secret, err := utils.RandomHex(32)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	encryptedSecret, err := utils.EncryptSecret(secret)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	key := "ck_" + keyPart
+	apiKey := &model.APIKey{
+		MerchantID: merchant.ID,
+		Label:      req.Label,
+		KeyPrefix:  key[:11],
+		KeyHash:    utils.SHA256Hex(key),
+		Secret:     encryptedSecret,
+		Scopes:     strings.Join(req.Scopes, ","),
+	}
+	if err := ctr.repo.Merchant.CreateKey(c.Request.Context(), apiKey); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := &dto.APIKeyCreateResp{
+		ID:     apiKey.ID.String(),
+		Key:    key,
+		Secret: secret,
+		Scopes: apiKey.Scopes,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *merchantHandler) revokeKey(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	merchant, err := ctr.repo.Merchant.FindByUser(c.Request.Context(), user.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	if err := ctr.repo.Merchant.RevokeKey(c.Request.Context(), merchant.ID, c.Param("id")); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *merchantHandler) getBalances(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	holdings, err := ctr.repo.Wallet.Holdings(c.Request.Context(), &user.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(holdings)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *merchantHandler) createInvoice(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.InvoiceCreateReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	invoice, err := createInvoice(c, ctr.repo, user, &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		if errors.Is(err, service.ErrUnsupportedCurrency) {
+			res = utils.GenerateBadRequestErrorResponse(err)
+		}
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(invoiceResp(invoice))
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *merchantHandler) createPayout(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.WithdrawReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	tx, res := sendWithdrawal(c, ctr.pay, user, &req)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res = utils.GenerateSuccessResponse(tx)
+	c.JSON(res.HttpStatusCode, res)
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type portfolioHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func newPortfolioHandler(h *Handler) *portfolioHandler {
+	return &portfolioHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+	}
+}
+
+func (ctr *portfolioHandler) register() {
+	group := ctr.R.Group("/api/portfolio")
+	group.Use(middleware.AuthMiddleware(ctr.repo))
+
+	group.GET("", ctr.getPortfolio)
+}
+
+// getPortfolio values the user's current holdings and returns the daily value
+// of the last req.Days days from the balance snapshots. The history is only
+// returned in conf.PortfolioQuote.
+func (ctr *portfolioHandler) getPortfolio(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.PortfolioReq{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	quote := strings.ToUpper(req.Quote)
+	if quote == "" {
+		quote = conf.PortfolioQuote
+	}
+	if req.Days == 0 {
+		req.Days = 30
+	}
+
+	holdings, err := ctr.repo.Wallet.Holdings(c.Request.Context(), &user.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := service.ValueHoldings(c.Request.Context(), ctr.svc.Price, holdings, quote); err != nil {
+		res := utils.GenerateServiceUnavailableResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	total := 0.0
+	for _, holding := range holdings {
+		total += holding.Value
+	}
+
+	// snapshots are valued in conf.PortfolioQuote and no past rate to other
+	// quotes is kept, so only that quote has a history
+	var series []*dto.PortfolioPoint
+	if quote == conf.PortfolioQuote {
+		now := time.Now()
+		since := time.Date(now.Year(), now.Month(), now.Day()-req.Days+1, 0, 0, 0, 0, now.Location())
+		series, err = ctr.repo.Snapshot.DailySeries(c.Request.Context(), user.ID, since)
+		if err != nil {
+			res := utils.GenerateGormErrorResponse(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+	}
+
+	data := &dto.PortfolioResp{
+		QuoteCurrency: quote,
+		TotalValue:    total,
+		Assets:        holdings,
+		Series:        series,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
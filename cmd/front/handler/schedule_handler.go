@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// runs returned with a scheduled transfer
+const scheduleRunsShown = 20
+
+type scheduleHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+}
+
+func newScheduleHandler(h *Handler) *scheduleHandler {
+	return &scheduleHandler{
+		R:    h.R,
+		repo: h.repo,
+	}
+}
+
+func (ctr *scheduleHandler) register() {
+	group := ctr.R.Group("/api/scheduled-transfers")
+	group.Use(middleware.AuthMiddleware(ctr.repo))
+	group.GET("", ctr.getSchedules)
+	group.POST("", middleware.GeoMiddleware(ctr.repo, middleware.GeoPolicyWithdrawal), ctr.createSchedule)
+	group.GET("/:id", ctr.getSchedule)
+	group.POST("/:id/pause", ctr.pauseSchedule)
+	group.POST("/:id/resume", ctr.resumeSchedule)
+	group.POST("/:id/cancel", ctr.cancelSchedule)
+}
+
+func (ctr *scheduleHandler) getSchedules(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.ScheduledTransferListReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list, total, err := ctr.repo.Schedule.ListByUser(c.Request.Context(), user.ID, &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := gin.H{
+		"list":  list,
+		"total": total,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *scheduleHandler) createSchedule(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.ScheduledTransferCreateReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	if !service.IsNetworkCurrency(req.Network, req.Currency) {
+		res := utils.GenerateBadRequestErrorResponse(service.ErrUnsupportedCurrency)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if req.Kind == model.ScheduleKindWithdrawal && !utils.IsValidAddress(req.Network, req.ToAddress) {
+		res := utils.GenerateBadRequestErrorResponse(fmt.Errorf("invalid %s address", req.Network))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if req.Kind == model.ScheduleKindInternal {
+		recipient, err := ctr.repo.User.FindByField("username", req.Username)
+		if err != nil {
+			res := utils.GenerateGormErrorResponse(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+		if recipient.ID == user.ID {
+			res := utils.GenerateBadRequestErrorResponse(errors.New("can't transfer to yourself"))
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+	}
+
+	now := time.Now()
+	startAt := now
+	if req.StartAt != nil {
+		if req.StartAt.Before(now.Add(-time.Minute)) {
+			res := utils.GenerateBadRequestErrorResponse(errors.New("start_at is in the past"))
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+		startAt = *req.StartAt
+	}
+	if req.EndAt != nil && !req.EndAt.After(startAt) {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("end_at must be after start_at"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	rule, err := utils.ParseSchedule(req.Rule, startAt)
+	if err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	schedule := &model.ScheduledTransfer{
+		UserID:    user.ID,
+		Kind:      req.Kind,
+		Network:   req.Network,
+		Currency:  req.Currency,
+		Amount:    req.Amount,
+		ToAddress: req.ToAddress,
+		Username:  req.Username,
+		Rule:      req.Rule,
+		StartAt:   startAt,
+		EndAt:     req.EndAt,
+		MaxRuns:   req.MaxRuns,
+		Status:    model.ScheduleStatusActive,
+	}
+	if req.Kind == model.ScheduleKindInternal {
+		schedule.ToAddress = ""
+	} else {
+		schedule.Username = ""
+	}
+
+	first := rule.Next(startAt.Add(-time.Nanosecond))
+	if schedule.Finished(first) {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("rule has no run before end_at"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	schedule.NextRunAt = &first
+
+	if err := ctr.repo.Schedule.Create(c.Request.Context(), schedule); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(schedule)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *scheduleHandler) getSchedule(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	schedule, err := ctr.repo.Schedule.FindByUser(c.Request.Context(), user.ID, c.Param("id"))
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	runs, err := ctr.repo.Schedule.ListRuns(c.Request.Context(), schedule.ID, scheduleRunsShown)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := &dto.ScheduledTransferResp{
+		ScheduledTransfer: schedule,
+		Runs:              runs,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *scheduleHandler) pauseSchedule(c *gin.Context) {
+	ctr.setStatus(c, []string{model.ScheduleStatusActive}, model.ScheduleStatusPaused)
+}
+
+func (ctr *scheduleHandler) resumeSchedule(c *gin.Context) {
+	ctr.setStatus(c, []string{model.ScheduleStatusPaused}, model.ScheduleStatusActive)
+}
+
+func (ctr *scheduleHandler) cancelSchedule(c *gin.Context) {
+	ctr.setStatus(c, []string{model.ScheduleStatusActive, model.ScheduleStatusPaused}, model.ScheduleStatusCancelled)
+}
+
+// setStatus moves the schedule from one of from to status. A resumed schedule
+// continues with the first run after now, runs while paused are not sent.
+func (ctr *scheduleHandler) setStatus(c *gin.Context, from []string, status string) {
+	user := c.MustGet("user").(*model.User)
+	schedule, err := ctr.repo.Schedule.FindByUser(c.Request.Context(), user.ID, c.Param("id"))
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	var next *time.Time
+	if status == model.ScheduleStatusActive {
+		rule, err := utils.ParseSchedule(schedule.Rule, schedule.StartAt)
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+		at := rule.Next(time.Now())
+		if schedule.Finished(at) {
+			status = model.ScheduleStatusCompleted
+		} else {
+			next = &at
+		}
+	}
+
+	if err := ctr.repo.Schedule.SetStatus(c.Request.Context(), schedule, from, status, next); err != nil {
+		if utils.IsErrNotFound(err) {
+			err = fmt.Errorf("scheduled transfer is %s", schedule.Status)
+		}
+		res := utils.GenerateBadRequestErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(schedule)
+	c.JSON(res.HttpStatusCode, res)
+}
@@ -2,9 +2,15 @@ package handler
 
 import (
 	"cryptoshare/dto"
+	"cryptoshare/middleware"
 	"cryptoshare/model"
+	"cryptoshare/payment"
 	"cryptoshare/repository"
+	"cryptoshare/service"
 	"cryptoshare/utils"
+	"errors"
+	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,18 +18,27 @@ import (
 type walletHandler struct {
 	R    *gin.Engine
 	repo *repository.Repository
+	pay  *payment.Payments
 }
 
 func newWalletHandler(h *Handler) *walletHandler {
 	return &walletHandler{
 		R:    h.R,
 		repo: h.repo,
+		pay:  h.pay,
 	}
 }
 
 func (ctr *walletHandler) register() {
 	group := ctr.R.Group("/api/wallets")
+	group.Use(middleware.AuthMiddleware(ctr.repo))
 	group.POST("/passphrase", ctr.parsePassphrase)
+	group.GET("/limits", ctr.getLimits)
+
+	group.Use(middleware.GeoMiddleware(ctr.repo, middleware.GeoPolicyWithdrawal))
+	group.Use(middleware.IdempotencyMiddleware(ctr.repo))
+	group.POST("/withdraw", ctr.withdraw)
+	group.POST("/internal-transfer", ctr.internalTransfer)
 }
 
 func (ctr *walletHandler) parsePassphrase(c *gin.Context) {
@@ -65,3 +80,136 @@ func (ctr *walletHandler) parsePassphrase(c *gin.Context) {
 	c.JSON(res.HttpStatusCode, res)
 
 }
+
+func (ctr *walletHandler) withdraw(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.WithdrawReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	tx, res := sendWithdrawal(c, ctr.pay, user, &req)
+	if res != nil {
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res = utils.GenerateSuccessResponse(tx)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// internalTransfer sends to another user's wallet, looked up by username
+func (ctr *walletHandler) internalTransfer(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.InternalTransferReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	area, err := utils.GetArea(c.ClientIP())
+	if err != nil {
+		log.Println(err)
+	}
+
+	tx, err := ctr.pay.InternalTransfer(c.Request.Context(), user, &req, c.ClientIP(), area, requestDevice(c))
+	if err != nil {
+		res := withdrawalErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(tx)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// getLimits shows the user's caps and what is used of them
+func (ctr *walletHandler) getLimits(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	limits := make([]*dto.EffectiveLimit, 0, len(model.LimitCurrencies))
+	for _, currency := range model.LimitCurrencies {
+		limit, err := ctr.repo.Limit.Effective(c.Request.Context(), user, currency, time.Now())
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			return
+		}
+		limits = append(limits, limit)
+	}
+
+	data := gin.H{
+		"tier":   user.Tier,
+		"limits": limits,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// sendWithdrawal sends req from the user's wallet, it is shared by the wallet
+// and merchant payout endpoints. res is the error response.
+func sendWithdrawal(c *gin.Context, pay *payment.Payments, user *model.User, req *dto.WithdrawReq) (*model.Transaction, *dto.Response) {
+	area, err := utils.GetArea(c.ClientIP())
+	if err != nil {
+		log.Println(err)
+	}
+
+	tx, err := pay.Withdraw(c.Request.Context(), &payment.Withdrawal{
+		User:   user,
+		Req:    req,
+		Type:   model.TxTypeWithdrawal,
+		IP:     c.ClientIP(),
+		Area:   area,
+		Device: requestDevice(c),
+	})
+	if err != nil {
+		// the chain service may have broadcast it before failing
+		var transferErr *payment.TransferError
+		if errors.As(err, &transferErr) {
+			middleware.IdempotencyInDoubt(c)
+		}
+		return nil, withdrawalErrorResponse(err)
+	}
+	return tx, nil
+}
+
+func requestDevice(c *gin.Context) string {
+	return utils.DeviceID(c.GetHeader("X-Device-ID"), c.Request.UserAgent())
+}
+
+func withdrawalErrorResponse(err error) *dto.Response {
+	switch {
+	case errors.Is(err, service.ErrUnsupportedCurrency),
+		errors.Is(err, repository.ErrQuoteNotFound),
+		errors.Is(err, payment.ErrQuoteMismatch),
+		errors.Is(err, payment.ErrInsufficientFunds),
+		errors.Is(err, payment.ErrSelfTransfer):
+		return utils.GenerateBadRequestErrorResponse(err)
+	case errors.Is(err, payment.ErrNotWhitelisted),
+		errors.Is(err, payment.ErrKYCRequired),
+		errors.Is(err, payment.ErrScreenedAddress),
+		errors.Is(err, payment.ErrRiskBlocked):
+		return utils.GenerateForbiddenResponse(err)
+	case errors.Is(err, service.ErrStalePrice):
+		return utils.GenerateServiceUnavailableResponse(err)
+	}
+	var reviewErr *payment.ReviewError
+	if errors.As(err, &reviewErr) {
+		res := utils.GenerateAcceptedResponse(err)
+		res.Data = reviewErr.Assessment
+		return res
+	}
+	var limitErr *payment.LimitError
+	if errors.As(err, &limitErr) {
+		res := utils.GenerateForbiddenResponse(err)
+		res.Data = limitErr
+		return res
+	}
+	var transferErr *payment.TransferError
+	if errors.As(err, &transferErr) {
+		return utils.GenerateServerError(err)
+	}
+	return utils.GenerateGormErrorResponse(err)
+}
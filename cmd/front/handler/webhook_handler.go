@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type webhookHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+}
+
+func newWebhookHandler(h *Handler) *webhookHandler {
+	return &webhookHandler{
+		R:    h.R,
+		repo: h.repo,
+	}
+}
+
+func (ctr *webhookHandler) register() {
+	group := ctr.R.Group("/api/webhooks")
+	group.Use(middleware.AuthMiddleware(ctr.repo))
+	group.GET("", ctr.getEndpoints)
+	group.POST("", ctr.createEndpoint)
+	group.DELETE("/:id", ctr.deleteEndpoint)
+	group.GET("/deliveries", ctr.getDeliveries)
+	group.POST("/deliveries/:id/replay", ctr.replayDelivery)
+}
+
+func (ctr *webhookHandler) getEndpoints(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	endpoints, err := ctr.repo.Webhook.ListEndpoints(c.Request.Context(), user.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(endpoints)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// createEndpoint registers a url, the signing secret can't be shown again
+func (ctr *webhookHandler) createEndpoint(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.WebhookCreateReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if err := service.ValidateWebhookURL(c.Request.Context(), req.URL); err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	secret, err := utils.RandomHex(32)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	encryptedSecret, err := utils.EncryptSecret(secret)
+	if err != nil {
+		res := utils.GenerateServerError(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	endpoint := &model.WebhookEndpoint{
+		UserID: user.ID,
+		URL:    req.URL,
+		Secret: encryptedSecret,
+		Events: strings.Join(req.Events, ","),
+		Active: true,
+	}
+	if err := ctr.repo.Webhook.CreateEndpoint(c.Request.Context(), endpoint); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := &dto.WebhookCreateResp{
+		WebhookEndpoint: endpoint,
+		Secret:          secret,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *webhookHandler) deleteEndpoint(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	if err := ctr.repo.Webhook.DeleteEndpoint(c.Request.Context(), user.ID, c.Param("id")); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// getDeliveries lists deliveries, status=dead is the dead-letter list
+func (ctr *webhookHandler) getDeliveries(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.WebhookDeliveryListReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	list, total, err := ctr.repo.Webhook.ListDeliveries(c.Request.Context(), user.ID, &req)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := gin.H{
+		"list":  list,
+		"total": total,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// replayDelivery sends a dead or delivered event again
+func (ctr *webhookHandler) replayDelivery(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		res := utils.GenerateBadRequestErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	if err := ctr.repo.Webhook.Replay(c.Request.Context(), user.ID, id); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
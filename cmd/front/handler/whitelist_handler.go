@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"cryptoshare/middleware"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type whitelistHandler struct {
+	R    *gin.Engine
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func newWhitelistHandler(h *Handler) *whitelistHandler {
+	return &whitelistHandler{
+		R:    h.R,
+		repo: h.repo,
+		svc:  h.svc,
+	}
+}
+
+func (ctr *whitelistHandler) register() {
+	group := ctr.R.Group("/api/whitelist")
+	group.Use(middleware.AuthMiddleware(ctr.repo))
+	group.GET("", ctr.getWhitelist)
+	group.DELETE("/:id", ctr.removeAddress)
+
+	group.Use(middleware.OTPMiddleware("user"))
+	group.POST("", ctr.addAddress)
+	group.POST("/enable", ctr.enable)
+	group.POST("/disable", ctr.disable)
+}
+
+func (ctr *whitelistHandler) getWhitelist(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	addresses, err := ctr.repo.Whitelist.ListByUser(c.Request.Context(), user.ID)
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	data := &dto.WhitelistResp{
+		Enabled:   user.WhitelistEnabled,
+		Active:    user.WhitelistActive(time.Now()),
+		OffAt:     user.WhitelistOffAt,
+		Addresses: addresses,
+	}
+	res := utils.GenerateSuccessResponse(data)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// addAddress whitelists an address, it can be withdrawn to after the cool-down
+func (ctr *whitelistHandler) addAddress(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	req := dto.WhitelistAddReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		res := utils.GenerateValidationErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	if !utils.IsValidAddress(req.Network, req.Address) {
+		res := utils.GenerateBadRequestErrorResponse(fmt.Errorf("invalid %s address", req.Network))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	address := utils.NormalizeAddress(req.Network, req.Address)
+
+	_, err := ctr.repo.Whitelist.Find(c.Request.Context(), user.ID, req.Network, address)
+	if err == nil {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("address is already whitelisted"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if !utils.IsErrNotFound(err) {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	entry := &model.WhitelistAddress{
+		UserID:   user.ID,
+		Network:  req.Network,
+		Address:  address,
+		Label:    req.Label,
+		ActiveAt: time.Now().Add(conf.WhitelistCooldown),
+	}
+	if err := ctr.repo.Whitelist.Create(c.Request.Context(), entry); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	ctr.mail(user, "New withdrawal address added",
+		fmt.Sprintf("The %s address %s was added to your withdrawal whitelist from %s. It can be used from %s.\n\nIf this wasn't you, remove it and change your password right away.",
+			entry.Network, entry.Address, c.ClientIP(), entry.ActiveAt.UTC().Format(time.RFC1123)))
+
+	res := utils.GenerateSuccessResponse(entry)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// removeAddress needs no otp, removing only restricts withdrawals further
+func (ctr *whitelistHandler) removeAddress(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	if err := ctr.repo.Whitelist.Delete(c.Request.Context(), user.ID, c.Param("id")); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// enable limits withdrawals to the whitelist right away, it also cancels a
+// pending disable
+func (ctr *whitelistHandler) enable(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	if err := ctr.setMode(user, true, nil); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	ctr.mail(user, "Withdrawal whitelist turned on",
+		fmt.Sprintf("Withdrawals from your account now only go to whitelisted addresses. This was requested from %s.", c.ClientIP()))
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+// disable turns the whitelist off after the cool-down, so a stolen session
+// can't turn it off and withdraw right away
+func (ctr *whitelistHandler) disable(c *gin.Context) {
+	user := c.MustGet("user").(*model.User)
+	if !user.WhitelistActive(time.Now()) {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("whitelist is not enabled"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+	if user.WhitelistOffAt != nil {
+		res := utils.GenerateBadRequestErrorResponse(errors.New("whitelist is already being turned off"))
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	offAt := time.Now().Add(conf.WhitelistCooldown)
+	if err := ctr.setMode(user, true, &offAt); err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		return
+	}
+
+	ctr.mail(user, "Withdrawal whitelist turning off",
+		fmt.Sprintf("Your withdrawal whitelist will be turned off at %s, as requested from %s.\n\nIf this wasn't you, turn it on again to cancel and change your password right away.",
+			offAt.UTC().Format(time.RFC1123), c.ClientIP()))
+
+	res := utils.GenerateSuccessResponse(nil)
+	c.JSON(res.HttpStatusCode, res)
+}
+
+func (ctr *whitelistHandler) setMode(user *model.User, enabled bool, offAt *time.Time) error {
+	_, err := ctr.repo.User.UpdateByFields(&model.UpdateFields{
+		Field: "id",
+		Value: user.ID,
+		Data: map[string]any{
+			"whitelist_enabled": enabled,
+			"whitelist_off_at":  offAt,
+		},
+	})
+	return err
+}
+
+// mail is best effort, the change is already saved
+func (ctr *whitelistHandler) mail(user *model.User, subject, body string) {
+	if err := ctr.svc.Mail.Send(user.Email, subject, body); err != nil {
+		log.Println(err, "Error sending mail to ", user.Email)
+	}
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"cryptoshare/cmd/front/handler"
-	_ "cryptoshare/conf"
+	"cryptoshare/conf"
 	"cryptoshare/ds"
 	"fmt"
 	"log"
@@ -30,6 +30,11 @@ func main() {
 	}
 
 	router := gin.Default()
+	// client ips, used by rate limits, geo-blocking and risk checks, come
+	// from X-Forwarded-For only when a trusted proxy sent it
+	if err := router.SetTrustedProxies(conf.TrustedProxies); err != nil {
+		log.Fatal(err)
+	}
 	h := handler.NewHandler(
 		&handler.HConfig{
 			R:  router,
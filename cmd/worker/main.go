@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	_ "cryptoshare/conf"
+	"cryptoshare/ds"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/worker"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	// to get file line and path when print
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	// load datasource
+	ds, err := ds.NewDataSource()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	svc := service.NewService(ds.RDB)
+	repo := repository.NewRepository(ds, svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := worker.NewWorker(
+		&worker.WConfig{
+			Repo: repo,
+			Svc:  svc,
+		})
+
+	w.Start(ctx)
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	<-c
+
+	// stop jobs
+	cancel()
+	log.Println("worker stopped")
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/joho/godotenv"
@@ -20,17 +22,150 @@ var (
 
 	AESKey string
 
+	// TrustedProxies are the addresses or CIDRs of the proxies in front of
+	// the apps, X-Forwarded-For is only read from them. Empty trusts none.
+	TrustedProxies []string
+
 	INFURA_BASE_URL string
 	INFURA_API_KEY  string
 
 	TATUM_BASE_URL string
 	TATUM_API_KEY  string
+
+	// fees
+	FeeQuoteTTL    time.Duration
+	GasReserveUSDT float64
+
+	// prices
+	PriceSourceURL string
+	PriceCacheTTL  time.Duration
+	PriceMaxAge    time.Duration
+	PriceOverrides map[string]float64
+
+	// portfolio
+	SnapshotInterval time.Duration
+	PortfolioQuote   string
+
+	// invoices and deposits
+	InvoiceTTL          time.Duration
+	DepositScanInterval time.Duration
+	ERC20Confirmations  uint64
+
+	// merchant api
+	MerchantSignatureWindow time.Duration
+
+	// webhooks
+	WebhookInterval    time.Duration
+	WebhookRetryBase   time.Duration
+	WebhookMaxAttempts int
+
+	// idempotency keys
+	IdempotencyTTL     time.Duration
+	IdempotencyLockTTL time.Duration
+
+	// scheduled transfers
+	ScheduleInterval time.Duration
+
+	// payout batches, an executing batch not updated for PayoutStaleAfter
+	// lost its server and is resumed by the worker
+	PayoutResumeInterval time.Duration
+	PayoutStaleAfter     time.Duration
+
+	// mail, MailDriver is one of the MailDriver constants. It defaults to
+	// smtp when SMTPHost is set, the file and log drivers are for
+	// development only.
+	MailDriver   string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+	MailDir      string
+
+	// withdrawal address whitelist
+	WhitelistCooldown time.Duration
+
+	// kyc, documents are kept under BlobDir. Users below KYCWithdrawLevel
+	// can't withdraw.
+	BlobDir          string
+	KYCMaxFileSize   int64
+	KYCWithdrawLevel int
+
+	// address screening, denylist files are read again after ScreeningRefresh
+	ScreeningFiles   []string
+	ScreeningRefresh time.Duration
+
+	// withdrawal risk scoring, a score at RiskReviewScore goes to manual
+	// review and one at RiskBlockScore is refused
+	RiskReviewScore   int
+	RiskBlockScore    int
+	RiskNewDeviceAge  time.Duration
+	RiskRecentChange  time.Duration
+	RiskAmountFactor  float64
+	RiskHistoryPeriod time.Duration
+
+	// geo-blocking, see GeoPolicy. GeoBlockUnknown refuses addresses whose
+	// country can't be resolved.
+	GeoPolicies         map[string]*GeoPolicy
+	GeoBlockUnknown     bool
+	GeoAllowlistRefresh time.Duration
+
+	// access tokens are short lived, refresh tokens rotate on every use
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// four-eyes approval, sensitive back office requests wait for
+	// ProposalApprovals other admins. Reviewed withdrawals worth
+	// FourEyesWithdrawalUSDT or more need it too.
+	ProposalApprovals      int
+	ProposalTTL            time.Duration
+	ProposalExpireInterval time.Duration
+	FourEyesWithdrawalUSDT float64
+
+	// login brute-force protection, failures are counted per account and
+	// per IP within LoginAttemptWindow. Each failure delays the answer by
+	// another LoginDelayStep and reaching a max locks for LoginLockout.
+	LoginMaxAttempts   int
+	LoginIPMaxAttempts int
+	LoginAttemptWindow time.Duration
+	LoginLockout       time.Duration
+	LoginDelayStep     time.Duration
+
+	// emailed tokens, signed with EmailTokenSecret. A user gets at most one
+	// email of each kind per EmailResendInterval.
+	EmailTokenSecret    string
+	EmailVerifyTTL      time.Duration
+	PasswordResetTTL    time.Duration
+	EmailResendInterval time.Duration
+
+	// passkeys of admins, the relying party defaults to AppHost
+	WebAuthnRPID         string
+	WebAuthnRPOrigin     string
+	WebAuthnRPName       string
+	WebAuthnChallengeTTL time.Duration
+)
+
+const (
+	MailDriverSMTP = "smtp"
+	// MailDriverFile writes emails to MailDir
+	MailDriverFile = "file"
+	MailDriverLog  = "log"
 )
 
+// GeoPolicy lists ISO 3166 country codes. With Allow set only those
+// countries pass, otherwise every country but the ones in Block does.
+type GeoPolicy struct {
+	Allow []string `json:"allow"`
+	Block []string `json:"block"`
+}
+
 func init() {
 	// Load env file
+	// tests run from their package directory and use the process environment
 	err := godotenv.Load("./conf/.env")
-	if err != nil {
+	if errors.Is(err, os.ErrNotExist) {
+		log.Println("no .env file, using the environment")
+	} else if err != nil {
 		log.Println("error opening .env file")
 		log.Fatalf(err.Error(), "FGDD")
 		return
@@ -75,6 +210,11 @@ func init() {
 
 	AESKey = os.Getenv("AES_KEY")
 	AppHost = os.Getenv("APP_DOMAIN")
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			TrustedProxies = append(TrustedProxies, proxy)
+		}
+	}
 
 	TATUM_BASE_URL = os.Getenv("TATUM_BASE_URL")
 	TATUM_API_KEY = os.Getenv("TATUM_API_KEY")
@@ -82,4 +222,170 @@ func init() {
 	INFURA_BASE_URL = os.Getenv("INFURA_BASE_URL")
 	INFURA_API_KEY = os.Getenv("INFURA_API_KEY")
 
+	FeeQuoteTTL = getEnvDuration("FEE_QUOTE_TTL", time.Minute)
+	GasReserveUSDT = getEnvFloat("GAS_RESERVE_USDT", 5)
+
+	PriceSourceURL = os.Getenv("PRICE_SOURCE_URL")
+	if PriceSourceURL == "" {
+		PriceSourceURL = "https://api.coingecko.com"
+	}
+	PriceCacheTTL = getEnvDuration("PRICE_CACHE_TTL", time.Minute)
+	PriceMaxAge = getEnvDuration("PRICE_MAX_AGE", 10*time.Minute)
+	PriceOverrides = parsePriceOverrides(os.Getenv("PRICE_OVERRIDES"))
+
+	SnapshotInterval = getEnvDuration("SNAPSHOT_INTERVAL", time.Hour)
+	PortfolioQuote = os.Getenv("PORTFOLIO_QUOTE")
+	if PortfolioQuote == "" {
+		PortfolioQuote = "USDT"
+	}
+
+	InvoiceTTL = getEnvDuration("INVOICE_TTL", 24*time.Hour)
+	DepositScanInterval = getEnvDuration("DEPOSIT_SCAN_INTERVAL", 30*time.Second)
+	ERC20Confirmations = uint64(getEnvFloat("ERC20_CONFIRMATIONS", 12))
+
+	MerchantSignatureWindow = getEnvDuration("MERCHANT_SIGNATURE_WINDOW", 5*time.Minute)
+
+	WebhookInterval = getEnvDuration("WEBHOOK_INTERVAL", 10*time.Second)
+	WebhookRetryBase = getEnvDuration("WEBHOOK_RETRY_BASE", 30*time.Second)
+	WebhookMaxAttempts = int(getEnvFloat("WEBHOOK_MAX_ATTEMPTS", 8))
+
+	IdempotencyTTL = getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour)
+	IdempotencyLockTTL = getEnvDuration("IDEMPOTENCY_LOCK_TTL", time.Minute)
+
+	ScheduleInterval = getEnvDuration("SCHEDULE_INTERVAL", 30*time.Second)
+
+	PayoutResumeInterval = getEnvDuration("PAYOUT_RESUME_INTERVAL", time.Minute)
+	PayoutStaleAfter = getEnvDuration("PAYOUT_STALE_AFTER", 10*time.Minute)
+
+	SMTPHost = os.Getenv("SMTP_HOST")
+	SMTPPort = int(getEnvFloat("SMTP_PORT", 587))
+	SMTPUsername = os.Getenv("SMTP_USERNAME")
+	SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	MailFrom = os.Getenv("MAIL_FROM")
+	MailDir = os.Getenv("MAIL_DIR")
+	MailDriver = strings.ToLower(os.Getenv("MAIL_DRIVER"))
+	if MailDriver == "" && SMTPHost != "" {
+		MailDriver = MailDriverSMTP
+	}
+
+	WhitelistCooldown = getEnvDuration("WHITELIST_COOLDOWN", 24*time.Hour)
+
+	BlobDir = os.Getenv("BLOB_DIR")
+	if BlobDir == "" {
+		BlobDir = "./data/blobs"
+	}
+	KYCMaxFileSize = int64(getEnvFloat("KYC_MAX_FILE_SIZE", 10<<20))
+	KYCWithdrawLevel = int(getEnvFloat("KYC_WITHDRAW_LEVEL", 0))
+
+	for _, file := range strings.Split(os.Getenv("SCREENING_FILES"), ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			ScreeningFiles = append(ScreeningFiles, file)
+		}
+	}
+	ScreeningRefresh = getEnvDuration("SCREENING_REFRESH", time.Hour)
+
+	RiskReviewScore = int(getEnvFloat("RISK_REVIEW_SCORE", 50))
+	RiskBlockScore = int(getEnvFloat("RISK_BLOCK_SCORE", 80))
+	RiskNewDeviceAge = getEnvDuration("RISK_NEW_DEVICE_AGE", 72*time.Hour)
+	RiskRecentChange = getEnvDuration("RISK_RECENT_CHANGE", 48*time.Hour)
+	RiskAmountFactor = getEnvFloat("RISK_AMOUNT_FACTOR", 5)
+	RiskHistoryPeriod = getEnvDuration("RISK_HISTORY_PERIOD", 90*24*time.Hour)
+
+	// GEO_<POLICY>_ALLOW and GEO_<POLICY>_BLOCK, e.g. GEO_SIGNUP_BLOCK=US,CA
+	GeoPolicies = map[string]*GeoPolicy{}
+	for _, name := range []string{"default", "signup", "login", "withdrawal"} {
+		prefix := "GEO_" + strings.ToUpper(name)
+		allow := parseCountries(os.Getenv(prefix + "_ALLOW"))
+		block := parseCountries(os.Getenv(prefix + "_BLOCK"))
+		if len(allow) > 0 || len(block) > 0 {
+			GeoPolicies[name] = &GeoPolicy{Allow: allow, Block: block}
+		}
+	}
+	GeoBlockUnknown = os.Getenv("GEO_BLOCK_UNKNOWN") == "true"
+	GeoAllowlistRefresh = getEnvDuration("GEO_ALLOWLIST_REFRESH", time.Minute)
+
+	AccessTokenTTL = getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+	RefreshTokenTTL = getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+
+	ProposalApprovals = int(getEnvFloat("PROPOSAL_APPROVALS", 1))
+	if ProposalApprovals < 1 {
+		ProposalApprovals = 1
+	}
+	ProposalTTL = getEnvDuration("PROPOSAL_TTL", 48*time.Hour)
+	ProposalExpireInterval = getEnvDuration("PROPOSAL_EXPIRE_INTERVAL", 5*time.Minute)
+	FourEyesWithdrawalUSDT = getEnvFloat("FOUR_EYES_WITHDRAWAL_USDT", 10000)
+
+	LoginMaxAttempts = int(getEnvFloat("LOGIN_MAX_ATTEMPTS", 5))
+	LoginIPMaxAttempts = int(getEnvFloat("LOGIN_IP_MAX_ATTEMPTS", 20))
+	LoginAttemptWindow = getEnvDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute)
+	LoginLockout = getEnvDuration("LOGIN_LOCKOUT", 15*time.Minute)
+	LoginDelayStep = getEnvDuration("LOGIN_DELAY_STEP", 500*time.Millisecond)
+
+	EmailTokenSecret = os.Getenv("EMAIL_TOKEN_SECRET")
+	if EmailTokenSecret == "" {
+		EmailTokenSecret = RefreshSecret
+	}
+	EmailVerifyTTL = getEnvDuration("EMAIL_VERIFY_TTL", 48*time.Hour)
+	PasswordResetTTL = getEnvDuration("PASSWORD_RESET_TTL", time.Hour)
+	EmailResendInterval = getEnvDuration("EMAIL_RESEND_INTERVAL", time.Minute)
+
+	WebAuthnRPID = os.Getenv("WEBAUTHN_RP_ID")
+	if WebAuthnRPID == "" {
+		WebAuthnRPID = AppHost
+	}
+	WebAuthnRPOrigin = os.Getenv("WEBAUTHN_RP_ORIGIN")
+	if WebAuthnRPOrigin == "" && WebAuthnRPID != "" {
+		WebAuthnRPOrigin = "https://" + WebAuthnRPID
+	}
+	WebAuthnRPName = os.Getenv("WEBAUTHN_RP_NAME")
+	if WebAuthnRPName == "" {
+		WebAuthnRPName = WebAuthnRPID
+	}
+	WebAuthnChallengeTTL = getEnvDuration("WEBAUTHN_CHALLENGE_TTL", 5*time.Minute)
+}
+
+// parseCountries reads "US, ca,KP" as upper case codes
+func parseCountries(value string) []string {
+	countries := make([]string, 0)
+	for _, country := range strings.Split(value, ",") {
+		if country = strings.ToUpper(strings.TrimSpace(country)); country != "" {
+			countries = append(countries, country)
+		}
+	}
+	return countries
+}
+
+// parsePriceOverrides reads "ETH/USDT=1300,TRX/USDT=0.06"
+func parsePriceOverrides(value string) map[string]float64 {
+	overrides := map[string]float64{}
+	for _, item := range strings.Split(value, ",") {
+		pair, rate, found := strings.Cut(strings.TrimSpace(item), "=")
+		if !found {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			log.Println("invalid price override: ", item)
+			continue
+		}
+		overrides[strings.ToUpper(pair)] = parsed
+	}
+	return overrides
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvDuration reads values like "90s" or "24h"
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
 }
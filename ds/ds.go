@@ -21,6 +21,7 @@ func NewDataSource() (*DataSource, error) {
 	DB = db
 
 	go AddDefaultAdmin()
+	go AddDefaultTierLimits()
 
 	rdb, err := LoadRDB()
 	if err != nil {
@@ -4,13 +4,18 @@ import (
 	"cryptoshare/model"
 	"cryptoshare/utils"
 	"log"
+
+	"gorm.io/gorm/clause"
 )
 
 func AddDefaultAdmin() {
+	AddDefaultRoles()
+
 	tb := DB.Model(&model.Admin{})
 	var count int64
 	tb.Count(&count)
 	if count != 0 {
+		grantExistingAdmins()
 		return
 	}
 	hashedPassword, err := utils.HashPassword("123456")
@@ -29,4 +34,92 @@ func AddDefaultAdmin() {
 		log.Panic(err)
 		return
 	}
+	if err := grantSuperAdmin(*admin.ID); err != nil {
+		log.Panic(err)
+	}
+}
+
+// AddDefaultRoles creates the built-in roles and keeps their permissions in
+// line with the code
+func AddDefaultRoles() {
+	for _, role := range model.BuiltinRoles {
+		role := *role
+		role.Builtin = true
+		err := DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description", "permissions", "builtin", "updated_at"}),
+		}).Create(&role).Error
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+}
+
+// grantExistingAdmins makes every admin a super-admin the first time roles
+// are seeded, as they could do everything before
+func grantExistingAdmins() {
+	var count int64
+	DB.Model(&model.AdminRole{}).Count(&count)
+	if count != 0 {
+		return
+	}
+	ids := make([]uint64, 0)
+	if err := DB.Model(&model.Admin{}).Pluck("id", &ids).Error; err != nil {
+		log.Panic(err)
+	}
+	for _, id := range ids {
+		if err := grantSuperAdmin(id); err != nil {
+			log.Panic(err)
+		}
+	}
+}
+
+func grantSuperAdmin(adminID uint64) error {
+	role := model.Role{}
+	if err := DB.First(&role, "name = ?", model.RoleSuperAdmin).Error; err != nil {
+		return err
+	}
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.AdminRole{AdminID: adminID, RoleID: role.ID}).Error
+}
+
+// AddDefaultTierLimits seeds the caps of every tier, admins change them later
+func AddDefaultTierLimits() {
+	tb := DB.Model(&model.TierLimit{})
+	var count int64
+	tb.Count(&count)
+	if count != 0 {
+		return
+	}
+
+	// per transaction, daily and monthly
+	defaults := map[string]map[string][3]float64{
+		model.TierUnverified: {
+			"USDT": {1000, 2000, 10000},
+			"ETH":  {0.5, 1, 5},
+			"TRX":  {10000, 20000, 100000},
+		},
+		model.TierVerified: {
+			"USDT": {50000, 100000, 1000000},
+			"ETH":  {25, 50, 500},
+			"TRX":  {500000, 1000000, 10000000},
+		},
+	}
+	limits := make([]*model.TierLimit, 0)
+	for tier, currencies := range defaults {
+		for currency, caps := range currencies {
+			limits = append(limits, &model.TierLimit{
+				Tier:     tier,
+				Currency: currency,
+				PerTx:    utils.NewFloat64(caps[0]),
+				Daily:    utils.NewFloat64(caps[1]),
+				Monthly:  utils.NewFloat64(caps[2]),
+			})
+		}
+	}
+
+	if err := tb.Create(&limits).Error; err != nil {
+		log.Panic(err)
+		return
+	}
 }
@@ -30,6 +30,9 @@ func LoadDB() (*gorm.DB, error) {
 
 	log.Println("Successfully connected to MySQL")
 
+	// accounts made before email verification existed count as verified
+	verifyExisting := !db.Migrator().HasColumn(&model.User{}, "email_verified_at")
+
 	// migrate DB
 	err = db.AutoMigrate(
 		&model.Bank{},
@@ -37,10 +40,51 @@ func LoadDB() (*gorm.DB, error) {
 		&model.User{},
 		&model.Wallet{},
 		&model.Asset{},
+		&model.Transaction{},
+		&model.BalanceSnapshot{},
+		&model.Invoice{},
+		&model.Deposit{},
+		&model.Merchant{},
+		&model.APIKey{},
+		&model.WebhookEndpoint{},
+		&model.WebhookDelivery{},
+		&model.IdempotencyKey{},
+		&model.PayoutBatch{},
+		&model.PayoutRow{},
+		&model.ScheduledTransfer{},
+		&model.ScheduledTransferRun{},
+		&model.WhitelistAddress{},
+		&model.TierLimit{},
+		&model.UserLimit{},
+		&model.KYCSubmission{},
+		&model.KYCDocument{},
+		&model.ScreeningHit{},
+		&model.RiskAssessment{},
+		&model.UserDevice{},
+		&model.GeoAllowIP{},
+		&model.RefreshToken{},
+		&model.Session{},
+		&model.Role{},
+		&model.AdminRole{},
+		&model.AuditLog{},
+		&model.AuditHead{},
+		&model.Proposal{},
+		&model.ProposalEvent{},
+		&model.UserToken{},
+		&model.RecoveryCode{},
+		&model.WebAuthnCredential{},
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if verifyExisting {
+		err = db.Model(&model.User{}).Where("email_verified_at IS NULL").
+			Update("email_verified_at", gorm.Expr("created_at")).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return db, nil
 }
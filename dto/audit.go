@@ -0,0 +1,14 @@
+package dto
+
+import "time"
+
+type AuditListReq struct {
+	PageReq
+	ActorType string     `json:"actor_type" form:"actor_type" binding:"omitempty,oneof='admin' 'user' 'system' 'anonymous'"`
+	ActorID   string     `json:"actor_id" form:"actor_id"`
+	Actor     string     `json:"actor" form:"actor"`
+	Action    string     `json:"action" form:"action"`
+	Target    string     `json:"target" form:"target"`
+	StartAt   *time.Time `json:"start_at" form:"start_at"`
+	EndAt     *time.Time `json:"end_at" form:"end_at"`
+}
@@ -1,10 +1,14 @@
 package dto
 
+import "encoding/json"
+
 type LoginReq struct {
 	// Email    string `json:"email" binding:"required,email"`
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
 	OTP      string `json:"otp"`
+	// admins that require a passkey answer its challenge instead of an otp
+	PasskeyReq
 }
 
 type SingupReq struct {
@@ -13,3 +17,89 @@ type SingupReq struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
 }
+
+// RefreshReq takes the refresh token from the body, browsers may send the
+// refresh_token cookie instead
+type RefreshReq struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
+
+type TokenResp struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn int `json:"expires_in"`
+}
+
+type ChangePasswordReq struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type VerifyEmailReq struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// EmailReq asks for an email to an address, the answer is the same whether
+// an account has it or not
+type EmailReq struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordReq struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// TwoFactorReq proves both factors, OTP may be a recovery code
+type TwoFactorReq struct {
+	Password string `json:"password" binding:"required"`
+	OTP      string `json:"otp" binding:"required"`
+}
+
+type TwoFactorSetupResp struct {
+	OTPSecret  string `json:"otp_secret"`
+	OTPAuthURL string `json:"otp_auth_url"`
+}
+
+// TwoFactorStatusResp is disabled, pending until the first code confirms
+// the setup, or enabled
+type TwoFactorStatusResp struct {
+	State             string `json:"state"`
+	RecoveryCodesLeft int64  `json:"recovery_codes_left"`
+}
+
+type RecoveryCodesResp struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// PasskeyReq carries a passkey assertion for a challenge started earlier,
+// requests without one are answered with a new challenge
+type PasskeyReq struct {
+	ChallengeID string          `json:"challenge_id"`
+	Assertion   json.RawMessage `json:"assertion"`
+}
+
+// PasskeyChallengeResp is handed to navigator.credentials, Options are
+// the creation or request options of the ceremony
+type PasskeyChallengeResp struct {
+	ChallengeID string `json:"challenge_id"`
+	Options     any    `json:"options"`
+}
+
+type PasskeyRegisterReq struct {
+	ChallengeID string          `json:"challenge_id" binding:"required"`
+	Name        string          `json:"name" binding:"required,max=100"`
+	Credential  json.RawMessage `json:"credential" binding:"required"`
+}
+
+type PasskeyRequiredReq struct {
+	PasskeyReq
+	Required *bool `json:"required" binding:"required"`
+}
+
+// PasskeyBeginReq comes with the second factor of AdminFactorMiddleware, a
+// passkey assertion or the otp
+type PasskeyBeginReq struct {
+	Password string `json:"password" binding:"required"`
+}
@@ -37,6 +37,8 @@ type TransferReq struct {
 	PrivateKey  string  `json:"private_key"`
 	IP          string  `json:"ip"`
 	Area        string  `json:"area"`
+	// GasPrice in wei, taken from a locked fee quote
+	GasPrice string `json:"gas_price"`
 }
 
 type PageReq struct {
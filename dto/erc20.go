@@ -9,3 +9,8 @@ type ERC20TransStatusResp struct {
 	State        int64  `json:"state"`
 	StateMessage string `json:"state_message"`
 }
+
+type TRC20BalanceResp struct {
+	USDTBalance float64 `json:"usdt_balance"`
+	TRXBalance  float64 `json:"trx_balance"`
+}
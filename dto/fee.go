@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+type FeeEstimateReq struct {
+	Network  string  `json:"network" form:"network" binding:"required,oneof='ERC20' 'TRC20'"`
+	Currency string  `json:"currency" form:"currency" binding:"required,oneof='ETH' 'TRX' 'USDT'"`
+	Amount   float64 `json:"amount" form:"amount" binding:"required,gt=0"`
+	To       string  `json:"to" form:"to" binding:"required"`
+}
+
+type FeeTierResp struct {
+	// GasPrice per gas (wei) or per energy/bandwidth unit (sun)
+	GasPrice  string  `json:"gas_price"`
+	Fee       float64 `json:"fee"`
+	FiatValue float64 `json:"fiat_value"`
+}
+
+type FeeQuoteResp struct {
+	QuoteID        string                  `json:"quote_id"`
+	Network        string                  `json:"network"`
+	Currency       string                  `json:"currency"`
+	Amount         float64                 `json:"amount"`
+	To             string                  `json:"to"`
+	NativeCurrency string                  `json:"native_currency"`
+	FiatCurrency   string                  `json:"fiat_currency"`
+	GasLimit       uint64                  `json:"gas_limit"`
+	Tiers          map[string]*FeeTierResp `json:"tiers"`
+	ExpiresAt      time.Time               `json:"expires_at"`
+}
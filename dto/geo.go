@@ -0,0 +1,7 @@
+package dto
+
+// GeoAllowIPReq takes a single address or a CIDR range
+type GeoAllowIPReq struct {
+	CIDR string `json:"cidr" form:"cidr" binding:"required,cidr|ip"`
+	Note string `json:"note" form:"note" binding:"max=255"`
+}
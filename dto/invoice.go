@@ -0,0 +1,44 @@
+package dto
+
+import (
+	"cryptoshare/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type InvoiceCreateReq struct {
+	Network  string  `json:"network" form:"network" binding:"required,oneof='ERC20' 'TRC20'"`
+	Currency string  `json:"currency" form:"currency" binding:"required,oneof='ETH' 'TRX' 'USDT'"`
+	Amount   float64 `json:"amount" form:"amount" binding:"required,gt=0"`
+	Memo     string  `json:"memo" form:"memo" binding:"max=255"`
+	// ExpiresIn is in seconds, defaults to conf.InvoiceTTL
+	ExpiresIn int64 `json:"expires_in" form:"expires_in" binding:"omitempty,gte=60"`
+}
+
+type InvoiceResp struct {
+	*model.Invoice
+	URL        string `json:"url"`
+	PaymentURI string `json:"payment_uri"`
+	QRCodeURL  string `json:"qr_code_url"`
+}
+
+// PublicInvoiceResp is what payers see through the shared link, it leaves
+// out the owner of the invoice
+type PublicInvoiceResp struct {
+	ID         uuid.UUID  `json:"id"`
+	Network    string     `json:"network"`
+	Currency   string     `json:"currency"`
+	Address    string     `json:"address"`
+	Amount     float64    `json:"amount"`
+	PaidAmount float64    `json:"paid_amount"`
+	Memo       string     `json:"memo"`
+	Status     string     `json:"status"`
+	TxHash     string     `json:"tx_hash"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	PaidAt     *time.Time `json:"paid_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	URL        string     `json:"url"`
+	PaymentURI string     `json:"payment_uri"`
+	QRCodeURL  string     `json:"qr_code_url"`
+}
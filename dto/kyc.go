@@ -0,0 +1,24 @@
+package dto
+
+// KYCSubmitReq is the identity data of a submission, documents come as
+// multipart files named front, back and selfie. Level 2 needs at least the
+// front and a selfie.
+type KYCSubmitReq struct {
+	Level          int    `json:"level" form:"level" binding:"required,oneof=1 2"`
+	FirstName      string `json:"first_name" form:"first_name" binding:"required,max=100"`
+	LastName       string `json:"last_name" form:"last_name" binding:"required,max=100"`
+	DateOfBirth    string `json:"date_of_birth" form:"date_of_birth" binding:"required,datetime=2006-01-02"`
+	Country        string `json:"country" form:"country" binding:"required,iso3166_1_alpha2"`
+	Address        string `json:"address" form:"address" binding:"required,max=255"`
+	DocumentType   string `json:"document_type" form:"document_type" binding:"required,oneof='passport' 'id_card' 'driving_license'"`
+	DocumentNumber string `json:"document_number" form:"document_number" binding:"required,max=50"`
+}
+
+type KYCListReq struct {
+	PageReq
+	Status string `json:"status" form:"status" binding:"omitempty,oneof='pending' 'approved' 'rejected'"`
+}
+
+type KYCRejectReq struct {
+	Reason string `json:"reason" form:"reason" binding:"required,max=500"`
+}
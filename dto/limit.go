@@ -0,0 +1,37 @@
+package dto
+
+// Limit is a set of caps, nil is unlimited
+type Limit struct {
+	PerTx   *float64 `json:"per_tx" form:"per_tx" binding:"omitempty,gte=0"`
+	Daily   *float64 `json:"daily" form:"daily" binding:"omitempty,gte=0"`
+	Monthly *float64 `json:"monthly" form:"monthly" binding:"omitempty,gte=0"`
+}
+
+type TierLimitReq struct {
+	Limit
+	Tier     string `json:"tier" form:"tier" binding:"required,oneof='unverified' 'verified'"`
+	Currency string `json:"currency" form:"currency" binding:"required,oneof='ETH' 'TRX' 'USDT'"`
+}
+
+// UserLimitReq overrides the tier caps, a cap of -1 is unlimited and a
+// missing one keeps the tier's
+type UserLimitReq struct {
+	PerTx    *float64 `json:"per_tx" form:"per_tx" binding:"omitempty,gte=0|eq=-1"`
+	Daily    *float64 `json:"daily" form:"daily" binding:"omitempty,gte=0|eq=-1"`
+	Monthly  *float64 `json:"monthly" form:"monthly" binding:"omitempty,gte=0|eq=-1"`
+	Currency string   `json:"currency" form:"currency" binding:"required,oneof='ETH' 'TRX' 'USDT'"`
+}
+
+type UserTierReq struct {
+	Tier string `json:"tier" form:"tier" binding:"required,oneof='unverified' 'verified'"`
+}
+
+// EffectiveLimit is what a user may withdraw of a currency, the tier caps
+// with the user's overrides applied, and what was used so far
+type EffectiveLimit struct {
+	Limit
+	Currency    string  `json:"currency"`
+	Overridden  bool    `json:"overridden"`
+	DailyUsed   float64 `json:"daily_used"`
+	MonthlyUsed float64 `json:"monthly_used"`
+}
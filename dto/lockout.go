@@ -0,0 +1,5 @@
+package dto
+
+type UnlockIPReq struct {
+	IP string `json:"ip" form:"ip" binding:"required,ip"`
+}
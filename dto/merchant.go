@@ -0,0 +1,18 @@
+package dto
+
+type MerchantCreateReq struct {
+	Name string `json:"name" form:"name" binding:"required,max=100"`
+}
+
+type APIKeyCreateReq struct {
+	Label  string   `json:"label" form:"label" binding:"max=100"`
+	Scopes []string `json:"scopes" form:"scopes" binding:"required,gte=1,dive,oneof='balances:read' 'invoices:create' 'payouts:create'"`
+}
+
+// APIKeyCreateResp is the only time the key and secret are shown
+type APIKeyCreateResp struct {
+	ID     string `json:"id"`
+	Key    string `json:"key"`
+	Secret string `json:"secret"`
+	Scopes string `json:"scopes"`
+}
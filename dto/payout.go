@@ -0,0 +1,27 @@
+package dto
+
+import "cryptoshare/model"
+
+type PayoutBatchCreateReq struct {
+	BankID uint64 `json:"bank_id" form:"bank_id" binding:"required"`
+}
+
+type PayoutBatchListReq struct {
+	PageReq
+	Status string `json:"status" form:"status" binding:"omitempty,oneof='previewed' 'executing' 'completed'"`
+}
+
+// PayoutRowError is a CSV line that failed validation
+type PayoutRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// PayoutPreviewResp shows what a batch will send and what it costs. Totals,
+// fees and balances are per currency, fees are estimated at the normal tier.
+type PayoutPreviewResp struct {
+	*model.PayoutBatch
+	Totals   map[string]float64 `json:"totals"`
+	Fees     map[string]float64 `json:"fees"`
+	Balances map[string]float64 `json:"balances"`
+}
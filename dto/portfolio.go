@@ -0,0 +1,36 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PortfolioReq struct {
+	// Quote currency of the values, defaults to conf.PortfolioQuote
+	Quote string `json:"quote" form:"quote"`
+	Days  int    `json:"days" form:"days" binding:"omitempty,gte=1,lte=365"`
+}
+
+type PortfolioAsset struct {
+	UserID   uuid.UUID `json:"-"`
+	WalletID uuid.UUID `json:"wallet_id"`
+	Network  string    `json:"network"`
+	Currency string    `json:"currency"`
+	Balance  float64   `json:"balance"`
+	Price    float64   `json:"price"`
+	Value    float64   `json:"value"`
+}
+
+type PortfolioPoint struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+}
+
+type PortfolioResp struct {
+	QuoteCurrency string            `json:"quote_currency"`
+	TotalValue    float64           `json:"total_value"`
+	Assets        []*PortfolioAsset `json:"assets"`
+	// Series is null for quotes other than conf.PortfolioQuote
+	Series []*PortfolioPoint `json:"series"`
+}
@@ -0,0 +1,13 @@
+package dto
+
+type ProposalListReq struct {
+	PageReq
+	Status string `json:"status" form:"status" binding:"omitempty,oneof='pending' 'executing' 'executed' 'failed' 'rejected' 'cancelled' 'expired'"`
+	Kind   string `json:"kind" form:"kind"`
+}
+
+// ProposalDecisionReq is checked by AdminFactorMiddleware as well
+type ProposalDecisionReq struct {
+	OTP  string `json:"otp" form:"otp"`
+	Note string `json:"note" form:"note" binding:"max=500"`
+}
@@ -0,0 +1,7 @@
+package dto
+
+type RiskListReq struct {
+	PageReq
+	Decision     string `json:"decision" form:"decision" binding:"omitempty,oneof='approve' 'review' 'block'"`
+	ReviewStatus string `json:"review_status" form:"review_status" binding:"omitempty,oneof='pending' 'approved' 'rejected' 'sent' 'failed' 'expired'"`
+}
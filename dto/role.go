@@ -0,0 +1,12 @@
+package dto
+
+type RoleReq struct {
+	Name        string   `json:"name" form:"name" binding:"required,max=50"`
+	Description string   `json:"description" form:"description" binding:"max=255"`
+	Permissions []string `json:"permissions" form:"permissions" binding:"required,min=1"`
+}
+
+// AdminRolesReq replaces every role of an admin
+type AdminRolesReq struct {
+	RoleIDs []uint64 `json:"role_ids" form:"role_ids" binding:"required"`
+}
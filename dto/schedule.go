@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"cryptoshare/model"
+	"time"
+)
+
+type ScheduledTransferCreateReq struct {
+	Kind     string  `json:"kind" form:"kind" binding:"required,oneof='withdrawal' 'internal'"`
+	Network  string  `json:"network" form:"network" binding:"required,oneof='ERC20' 'TRC20'"`
+	Currency string  `json:"currency" form:"currency" binding:"required,oneof='ETH' 'TRX' 'USDT'"`
+	Amount   float64 `json:"amount" form:"amount" binding:"required,gt=0"`
+	// ToAddress is for withdrawals and Username for internal transfers
+	ToAddress string `json:"to_address" form:"to_address" binding:"required_if=Kind withdrawal"`
+	Username  string `json:"username" form:"username" binding:"required_if=Kind internal"`
+	// Rule is "@every 168h", "@daily" or a cron expression like "0 9 * * 1"
+	Rule    string     `json:"rule" form:"rule" binding:"required,max=100"`
+	StartAt *time.Time `json:"start_at" form:"start_at"`
+	EndAt   *time.Time `json:"end_at" form:"end_at"`
+	MaxRuns int        `json:"max_runs" form:"max_runs" binding:"gte=0"`
+}
+
+type ScheduledTransferListReq struct {
+	PageReq
+	Status string `json:"status" form:"status" binding:"omitempty,oneof='active' 'paused' 'cancelled' 'completed'"`
+}
+
+type ScheduledTransferResp struct {
+	*model.ScheduledTransfer
+	Runs []*model.ScheduledTransferRun `json:"runs"`
+}
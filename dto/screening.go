@@ -0,0 +1,7 @@
+package dto
+
+type ScreeningHitListReq struct {
+	PageReq
+	Direction string `json:"direction" form:"direction" binding:"omitempty,oneof='withdrawal' 'payout' 'deposit'"`
+	Address   string `json:"address" form:"address"`
+}
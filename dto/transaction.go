@@ -0,0 +1,14 @@
+package dto
+
+type TransactionListReq struct {
+	PageReq
+	Address string `json:"address" form:"address"`
+	State   int    `json:"state" form:"state"`
+}
+
+type ReplaceTxReq struct {
+	TxHash string `json:"tx_hash" form:"tx_hash" binding:"required"`
+	// BumpPercent is how much the fee is raised over the pending transaction.
+	// Nodes reject replacements below 10%, so that is the floor.
+	BumpPercent uint64 `json:"bump_percent" form:"bump_percent" binding:"omitempty,gte=10,lte=500"`
+}
@@ -0,0 +1,21 @@
+package dto
+
+type WithdrawReq struct {
+	Network   string  `json:"network" form:"network" binding:"required,oneof='ERC20' 'TRC20'"`
+	Currency  string  `json:"currency" form:"currency" binding:"required,oneof='ETH' 'TRX' 'USDT'"`
+	Amount    float64 `json:"amount" form:"amount" binding:"required,gt=0"`
+	ToAddress string  `json:"to_address" form:"to_address" binding:"required"`
+	// QuoteID of a fee quote from /api/fees/estimate, locks its gas price
+	QuoteID string `json:"quote_id" form:"quote_id"`
+	FeeTier string `json:"fee_tier" form:"fee_tier" binding:"omitempty,oneof=slow normal fast"`
+}
+
+// InternalTransferReq sends to the wallet of another user on the same network
+type InternalTransferReq struct {
+	Username string  `json:"username" form:"username" binding:"required"`
+	Network  string  `json:"network" form:"network" binding:"required,oneof='ERC20' 'TRC20'"`
+	Currency string  `json:"currency" form:"currency" binding:"required,oneof='ETH' 'TRX' 'USDT'"`
+	Amount   float64 `json:"amount" form:"amount" binding:"required,gt=0"`
+	QuoteID  string  `json:"quote_id" form:"quote_id"`
+	FeeTier  string  `json:"fee_tier" form:"fee_tier" binding:"omitempty,oneof=slow normal fast"`
+}
@@ -0,0 +1,27 @@
+package dto
+
+import "cryptoshare/model"
+
+type WebhookCreateReq struct {
+	URL    string   `json:"url" form:"url" binding:"required,url,max=500"`
+	Events []string `json:"events" form:"events" binding:"required,gte=1,dive,oneof='deposit.detected' 'deposit.confirmed' 'withdrawal.sent' 'withdrawal.failed' 'invoice.paid' 'scheduled_transfer.skipped' 'scheduled_transfer.held'"`
+}
+
+// WebhookCreateResp is the only time the signing secret is returned
+type WebhookCreateResp struct {
+	*model.WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryListReq struct {
+	PageReq
+	Status string `json:"status" form:"status" binding:"omitempty,oneof='pending' 'delivered' 'dead'"`
+}
+
+// WebhookPayload is the body posted to endpoints
+type WebhookPayload struct {
+	ID        string `json:"id"`
+	Event     string `json:"event"`
+	CreatedAt int64  `json:"created_at"`
+	Data      any    `json:"data"`
+}
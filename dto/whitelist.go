@@ -0,0 +1,21 @@
+package dto
+
+import (
+	"cryptoshare/model"
+	"time"
+)
+
+type WhitelistAddReq struct {
+	OTPReq
+	Network string `json:"network" form:"network" binding:"required,oneof='ERC20' 'TRC20'"`
+	Address string `json:"address" form:"address" binding:"required"`
+	Label   string `json:"label" form:"label" binding:"max=100"`
+}
+
+type WhitelistResp struct {
+	Enabled bool `json:"enabled"`
+	// Active stays true until a disable has passed its cool-down at OffAt
+	Active    bool                      `json:"active"`
+	OffAt     *time.Time                `json:"off_at"`
+	Addresses []*model.WhitelistAddress `json:"addresses"`
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// request bodies larger than this are not kept in the audit log
+const auditMaxBody = 64 << 10
+
+// AuditMiddleware records every request that may change something, once
+// its handler is done. The redacted JSON body or form values are kept as the
+// after state unless the handler describes the change itself with Audit.
+func AuditMiddleware(r *repository.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		var body []byte
+		// bodies of unknown length (chunked) are not read, a cut copy would
+		// reach the handler
+		length := c.Request.ContentLength
+		contentType := c.ContentType()
+		if (contentType == binding.MIMEJSON || contentType == binding.MIMEPOSTForm) && length >= 0 && length <= auditMaxBody {
+			var err error
+			body, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				res := utils.GenerateBadRequestErrorResponse(err)
+				c.JSON(res.HttpStatusCode, res)
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		c.Next()
+
+		// unknown routes change nothing
+		if c.FullPath() == "" {
+			return
+		}
+
+		entry := &model.AuditLog{
+			ActorType: model.AuditActorAnonymous,
+			Action:    utils.Truncate(c.Request.Method+" "+c.FullPath(), 150),
+			Target:    utils.Truncate(c.Request.URL.Path, 255),
+			Status:    c.Writer.Status(),
+			IP:        c.ClientIP(),
+			UserAgent: utils.Truncate(c.Request.UserAgent(), 255),
+		}
+		setAuditActor(c, entry)
+		if target := c.GetString("audit_target"); target != "" {
+			entry.Target = utils.Truncate(target, 255)
+		}
+		if before, ok := c.Get("audit_before"); ok {
+			entry.Before = utils.RedactJSON(before)
+		}
+		if after, ok := c.Get("audit_after"); ok {
+			entry.After = utils.RedactJSON(after)
+		} else if sent := auditRequest(c, body); sent != nil {
+			entry.After = utils.RedactJSON(sent)
+		}
+
+		if err := r.Audit.Record(c.Request.Context(), entry); err != nil {
+			log.Println(err, "Error recording audit log ", entry.Action)
+		}
+	}
+}
+
+// auditRequest returns what the request sent, the JSON body, the form values
+// or the multipart values the handler parsed. Uploaded files are kept by name.
+func auditRequest(c *gin.Context, body []byte) any {
+	switch c.ContentType() {
+	case binding.MIMEPOSTForm:
+		values, err := url.ParseQuery(string(body))
+		if err != nil || len(values) == 0 {
+			return nil
+		}
+		return values
+	case binding.MIMEMultipartPOSTForm:
+		form := c.Request.MultipartForm
+		if form == nil {
+			return nil
+		}
+		values := url.Values{}
+		for key, items := range form.Value {
+			values[key] = items
+		}
+		for key, files := range form.File {
+			for _, file := range files {
+				values.Add(key, file.Filename)
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		return values
+	}
+	if len(body) > 0 {
+		return body
+	}
+	return nil
+}
+
+// Audit describes the change a handler made, before and after are
+// redacted of secrets when recorded. Either may be nil.
+func Audit(c *gin.Context, target string, before, after any) {
+	c.Set("audit_target", target)
+	if before != nil {
+		c.Set("audit_before", before)
+	}
+	if after != nil {
+		c.Set("audit_after", after)
+	}
+}
+
+// AuditActor names the actor of a request that isn't authenticated yet,
+// like a login
+func AuditActor(c *gin.Context, actorType, id, name string) {
+	c.Set("audit_actor", &model.AuditLog{ActorType: actorType, ActorID: id, Actor: name})
+}
+
+func setAuditActor(c *gin.Context, entry *model.AuditLog) {
+	if admin, ok := c.Get("admin"); ok {
+		admin := admin.(*model.Admin)
+		entry.ActorType = model.AuditActorAdmin
+		entry.ActorID = fmt.Sprint(*admin.ID)
+		entry.Actor = *admin.Username
+		return
+	}
+	if user, ok := c.Get("user"); ok {
+		user := user.(*model.User)
+		entry.ActorType = model.AuditActorUser
+		entry.ActorID = user.ID.String()
+		entry.Actor = user.Username
+		return
+	}
+	if actor, ok := c.Get("audit_actor"); ok {
+		actor := actor.(*model.AuditLog)
+		entry.ActorType = actor.ActorType
+		entry.ActorID = actor.ActorID
+		entry.Actor = actor.Actor
+	}
+}
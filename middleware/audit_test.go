@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"bytes"
+	"cryptoshare/model"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JSON, form and multipart requests are recorded without their secrets
+func TestAuditMiddlewareRecordsRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newTestRepository(t)
+	if err := repo.DS.DB.AutoMigrate(&model.AuditLog{}, &model.AuditHead{}); err != nil {
+		t.Fatal(err)
+	}
+
+	multipartBody := &bytes.Buffer{}
+	form := multipart.NewWriter(multipartBody)
+	form.WriteField("name", "alice")
+	form.WriteField("password", "hunter2")
+	file, _ := form.CreateFormFile("file", "payouts.csv")
+	file.Write([]byte("address,amount\n"))
+	form.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"json", "application/json", `{"name":"alice","password":"hunter2"}`, `{"name":"alice","password":"[REDACTED]"}`},
+		{"form", "application/x-www-form-urlencoded", url.Values{"name": {"alice"}, "password": {"hunter2"}}.Encode(), `{"name":["alice"],"password":"[REDACTED]"}`},
+		{"multipart", form.FormDataContentType(), multipartBody.String(), `{"file":["payouts.csv"],"name":["alice"],"password":"[REDACTED]"}`},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(AuditMiddleware(repo))
+			router.POST("/", func(c *gin.Context) {
+				req := struct {
+					Name string `json:"name" form:"name"`
+				}{}
+				if err := c.ShouldBind(&req); err != nil || req.Name != "alice" {
+					t.Errorf("handler got %q, %v", req.Name, err)
+				}
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			router.ServeHTTP(w, req)
+
+			entry := model.AuditLog{}
+			if err := repo.DS.DB.First(&entry, "seq = ?", i+1).Error; err != nil {
+				t.Fatal(err)
+			}
+			if string(entry.After) != tt.want {
+				t.Errorf("recorded %s, want %s", entry.After, tt.want)
+			}
+		})
+	}
+}
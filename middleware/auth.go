@@ -3,7 +3,9 @@ package middleware
 import (
 	"cryptoshare/repository"
 	"cryptoshare/utils"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -13,7 +15,36 @@ type authHeader struct {
 	AccessToken string `header:"Authorization"`
 }
 
+// AuthMiddleware sets the "user" or "admin" of the access token
 func AuthMiddleware(r *repository.Repository) gin.HandlerFunc {
+	return authMiddleware(r, false)
+}
+
+// AdminAuthMiddleware is AuthMiddleware for the back office, tokens of
+// front users are refused
+func AdminAuthMiddleware(r *repository.Repository) gin.HandlerFunc {
+	return authMiddleware(r, true)
+}
+
+// SessionMiddleware sets the "session_id" of a valid access token and never
+// refuses the request, it goes on routes like logout that also work without
+// one
+func SessionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := authHeader{}
+		if err := c.ShouldBindHeader(&h); err == nil {
+			accessToken := strings.Split(h.AccessToken, "Bearer ")
+			if len(accessToken) == 2 {
+				if claim, err := utils.ValidateAccessToken(accessToken[1]); err == nil && claim.SessionID != "" {
+					c.Set("session_id", claim.SessionID)
+				}
+			}
+		}
+		c.Next()
+	}
+}
+
+func authMiddleware(r *repository.Repository, adminOnly bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		h := authHeader{}
 
@@ -43,6 +74,38 @@ func AuthMiddleware(r *repository.Repository) gin.HandlerFunc {
 			return
 		}
 
+		if adminOnly && !claim.IsAdmin {
+			res := utils.GenerateForbiddenResponse(errors.New("admin token required"))
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+
+		// tokens are only good while their session is
+		if claim.SessionID == "" {
+			res := utils.GenerateAuthErrorResponse(nil)
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		revoked, err := r.Token.IsSessionRevoked(c.Request.Context(), claim.SessionID)
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		if revoked {
+			res := utils.GenerateAuthErrorResponse(nil)
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		if err := r.Token.TouchSession(c.Request.Context(), claim.SessionID); err != nil {
+			log.Println(err, "Error updating session ", claim.SessionID)
+		}
+		c.Set("session_id", claim.SessionID)
+
 		if claim.IsAdmin {
 			admin, err := r.Admin.FindByField("username", claim.Username)
 			if err != nil {
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"cryptoshare/conf"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	GeoPolicyDefault    = "default"
+	GeoPolicySignup     = "signup"
+	GeoPolicyLogin      = "login"
+	GeoPolicyWithdrawal = "withdrawal"
+)
+
+// GeoMiddleware refuses clients from countries the policy doesn't allow
+// with 451. The default policy goes on every route and the others on top of
+// it. An unconfigured policy lets everyone in, as do allowlisted, local and
+// private addresses.
+func GeoMiddleware(r *repository.Repository, policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rules := conf.GeoPolicies[policy]
+		if rules == nil {
+			c.Next()
+			return
+		}
+
+		ip := c.ClientIP()
+		if addr := net.ParseIP(ip); addr != nil && (addr.IsLoopback() || addr.IsPrivate()) {
+			c.Next()
+			return
+		}
+		allowed, err := r.Geo.IsAllowed(c.Request.Context(), ip)
+		if err != nil {
+			log.Println(err, "Error loading geo allowlist")
+		}
+		if allowed {
+			c.Next()
+			return
+		}
+
+		country, err := utils.GetCountry(ip)
+		if err != nil {
+			log.Println(err, "Error resolving country of ", ip)
+		}
+		if !geoAllowed(rules, country) {
+			res := utils.GenerateGeoBlockedResponse(fmt.Errorf("service is not available in your region (%s)", country))
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func geoAllowed(rules *conf.GeoPolicy, country string) bool {
+	if country == "" {
+		return !conf.GeoBlockUnknown
+	}
+	if len(rules.Allow) > 0 {
+		return contains(rules.Allow, country)
+	}
+	return !contains(rules.Block, country)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"cryptoshare/conf"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// responseRecorder keeps a copy of what the handler writes
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware makes a request with an Idempotency-Key header run
+// once. A retry with the same key and payload gets the stored response, a
+// different payload or a retry while the first is running gets 409. It goes
+// after the auth middleware, keys are scoped to the caller and route.
+// Requests without the header run as usual.
+func IdempotencyMiddleware(r *repository.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			res := utils.GenerateBadRequestErrorResponse(fmt.Errorf("%s is too long", IdempotencyKeyHeader))
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			res := utils.GenerateBadRequestErrorResponse(err)
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := idempotencyScope(c)
+		fingerprint := requestFingerprint(c, body)
+		ctx := c.Request.Context()
+
+		// a finished request, replay it
+		if replayIdempotent(c, r, scope, key, fingerprint) {
+			return
+		}
+
+		token, err := r.Idempotency.Lock(ctx, scope, key, conf.IdempotencyLockTTL)
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		if token == "" {
+			res := utils.GenerateConflictResponse(errors.New("a request with this idempotency key is in progress"))
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		stop := keepIdempotencyLock(r, scope, key, token)
+		// keep holds the lock for as long as the key would have been stored
+		// when the response couldn't be saved, so a retry can't run it again
+		keep := false
+		defer func() {
+			stop()
+			if keep {
+				if _, err := r.Idempotency.Extend(context.Background(), scope, key, token, conf.IdempotencyTTL); err != nil {
+					log.Println(err, "Error holding idempotency key")
+				}
+				return
+			}
+			if err := r.Idempotency.Unlock(context.Background(), scope, key, token); err != nil {
+				log.Println(err, "Error releasing idempotency key")
+			}
+		}()
+
+		// it may have finished between the lookup and the lock
+		if replayIdempotent(c, r, scope, key, fingerprint) {
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+		c.Next()
+
+		// server errors are not kept so the client can retry them, unless the
+		// handler may already have done the work
+		status := recorder.Status()
+		if status >= 500 && !c.GetBool(idempotencyInDoubtKey) {
+			return
+		}
+		record := &model.IdempotencyKey{
+			Scope:       scope,
+			Key:         key,
+			Fingerprint: fingerprint,
+			StatusCode:  status,
+			Response:    recorder.body.String(),
+			ExpiresAt:   time.Now().Add(conf.IdempotencyTTL),
+		}
+		if err := r.Idempotency.Save(context.Background(), record); err != nil {
+			keep = true
+			log.Printf("%v Error saving idempotency key %s of %s, it stays locked for %s\n", err, key, scope, conf.IdempotencyTTL)
+		}
+	}
+}
+
+const idempotencyInDoubtKey = "idempotency_in_doubt"
+
+// IdempotencyInDoubt marks a failing request that may still have done its
+// work, like a withdrawal whose broadcast failed ambiguously. Its server error
+// is stored so a retry with the same key replays it instead of running again.
+func IdempotencyInDoubt(c *gin.Context) {
+	c.Set(idempotencyInDoubtKey, true)
+}
+
+// keepIdempotencyLock extends the lock until stop is called, so a handler
+// running longer than conf.IdempotencyLockTTL isn't run twice by a retry
+func keepIdempotencyLock(r *repository.Repository, scope, key, token string) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(conf.IdempotencyLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				held, err := r.Idempotency.Extend(context.Background(), scope, key, token, conf.IdempotencyLockTTL)
+				if err != nil {
+					log.Println(err, "Error extending idempotency key")
+					continue
+				}
+				if !held {
+					log.Printf("idempotency key %s of %s was lost while running\n", key, scope)
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// replayIdempotent writes the stored response of key and reports whether the
+// request was answered
+func replayIdempotent(c *gin.Context, r *repository.Repository, scope, key, fingerprint string) bool {
+	record, err := r.Idempotency.Find(c.Request.Context(), scope, key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false
+	}
+	if err != nil {
+		res := utils.GenerateGormErrorResponse(err)
+		c.JSON(res.HttpStatusCode, res)
+		c.Abort()
+		return true
+	}
+
+	if record.Fingerprint != fingerprint {
+		res := utils.GenerateConflictResponse(errors.New("idempotency key was used with a different request"))
+		c.JSON(res.HttpStatusCode, res)
+		c.Abort()
+		return true
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(record.StatusCode, "application/json; charset=utf-8", []byte(record.Response))
+	c.Abort()
+	return true
+}
+
+// idempotencyScope is the caller and the route, so keys of different users
+// or endpoints never collide
+func idempotencyScope(c *gin.Context) string {
+	caller := "anonymous"
+	if user, ok := c.Get("user"); ok {
+		caller = "user:" + user.(*model.User).ID.String()
+	} else if admin, ok := c.Get("admin"); ok {
+		if id := admin.(*model.Admin).ID; id != nil {
+			caller = fmt.Sprintf("admin:%d", *id)
+		}
+	}
+	return fmt.Sprintf("%s:%s %s", caller, c.Request.Method, c.FullPath())
+}
+
+// requestFingerprint is the hash of the url and body of the request
+func requestFingerprint(c *gin.Context, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
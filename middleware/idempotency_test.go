@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"cryptoshare/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// a server error is retried, unless the handler marked it in doubt
+func TestIdempotencyServerErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newTestRepository(t)
+	if err := repo.DS.DB.AutoMigrate(&model.IdempotencyKey{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, inDoubt := range []bool{false, true} {
+		runs := 0
+		router := gin.New()
+		router.POST("/", IdempotencyMiddleware(repo), func(c *gin.Context) {
+			runs++
+			if inDoubt {
+				IdempotencyInDoubt(c)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "broadcast failed"})
+		})
+
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"amount":1}`))
+			req.Header.Set(IdempotencyKeyHeader, "key")
+			if inDoubt {
+				req.Header.Set(IdempotencyKeyHeader, "in-doubt-key")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("in doubt %v: got %d, want 500", inDoubt, w.Code)
+			}
+		}
+
+		want := 2
+		if inDoubt {
+			want = 1
+		}
+		if runs != want {
+			t.Errorf("in doubt %v: handler ran %d times, want %d", inDoubt, runs, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"bytes"
+	"cryptoshare/conf"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// merchantMaxBody is the largest body a signed request may have, it is read
+// whole to check the signature
+const merchantMaxBody = 1 << 20
+
+type merchantHeader struct {
+	APIKey    string `header:"X-API-Key" binding:"required"`
+	Timestamp string `header:"X-Timestamp" binding:"required"`
+	Nonce     string `header:"X-Nonce" binding:"required,max=64"`
+	Signature string `header:"X-Signature" binding:"required"`
+}
+
+// MerchantAuthMiddleware authenticates a request signed with an api key, see
+// utils.SignRequest. A nonce can be used once per signature window.
+func MerchantAuthMiddleware(r *repository.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := merchantHeader{}
+		if err := c.ShouldBindHeader(&h); err != nil {
+			res := utils.GenerateAuthErrorResponse(err)
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+
+		timestamp, err := strconv.ParseInt(h.Timestamp, 10, 64)
+		skew := time.Since(time.Unix(timestamp, 0))
+		if err != nil || skew > conf.MerchantSignatureWindow || skew < -conf.MerchantSignatureWindow {
+			res := utils.GenerateAuthErrorResponse(fmt.Errorf("request expired"))
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+
+		key, err := r.Merchant.FindActiveKey(c.Request.Context(), utils.SHA256Hex(h.APIKey))
+		if err == nil && (key.Merchant == nil || key.Merchant.User == nil) {
+			err = fmt.Errorf("merchant is deleted")
+		}
+		if err != nil {
+			res := utils.GenerateAuthErrorResponse(err)
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		// the secret is stored encrypted rather than hashed, checking the
+		// HMAC needs it, see model.APIKey
+		secret, err := utils.DecryptAES(key.Secret)
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, merchantMaxBody))
+		if err != nil {
+			res := utils.GenerateBadRequestErrorResponse(err)
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		// put the body back for the handler
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		signature := utils.SignRequest(secret, c.Request.Method, c.Request.URL.RequestURI(), h.Timestamp, h.Nonce, body)
+		if !utils.EqualSignature(signature, h.Signature) {
+			res := utils.GenerateAuthErrorResponse(fmt.Errorf("invalid signature"))
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+
+		// a request older than the window is rejected above, so the nonce
+		// only has to be remembered for both sides of the window
+		fresh, err := r.Merchant.UseNonce(c.Request.Context(), key.ID, h.Nonce, 2*conf.MerchantSignatureWindow)
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		if !fresh {
+			res := utils.GenerateAuthErrorResponse(fmt.Errorf("nonce already used"))
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+
+		if err := r.Merchant.TouchKey(c.Request.Context(), key.ID); err != nil {
+			log.Println(err, "Error updating api key last used")
+		}
+
+		c.Set("api_key", key)
+		c.Set("merchant", key.Merchant)
+		c.Set("user", key.Merchant.User)
+		c.Next()
+	}
+}
+
+// RequireScope only lets api keys with scope through
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.MustGet("api_key").(*model.APIKey)
+		if !key.HasScope(scope) {
+			res := utils.GenerateForbiddenResponse(fmt.Errorf("api key is missing the %s scope", scope))
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
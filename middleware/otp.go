@@ -1,17 +1,34 @@
 package middleware
 
 import (
+	"bytes"
 	"cryptoshare/dto"
 	"cryptoshare/model"
 	"cryptoshare/utils"
+	"errors"
+	"io"
 
 	"github.com/gin-gonic/gin"
 )
 
+// OTPMiddleware checks the otp field of the body against the 2fa secret of
+// the admin or user, which must have 2fa enabled. Recovery codes aren't
+// taken here. The body is put back so the handler can bind it too.
 func OTPMiddleware(userType string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			res := utils.GenerateBadRequestErrorResponse(err)
+			ctx.JSON(res.HttpStatusCode, res)
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
 		req := dto.OTPReq{}
-		if err := ctx.ShouldBind(&req); err != nil {
+		err = ctx.ShouldBind(&req)
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
 			res := utils.GenerateValidationErrorResponse(err)
 			ctx.JSON(res.HttpStatusCode, res)
 			ctx.Abort()
@@ -19,7 +36,16 @@ func OTPMiddleware(userType string) gin.HandlerFunc {
 		}
 		// user or admin
 		if userType == "admin" {
-			admin := ctx.MustGet(userType).(*model.Admin)
+			admin, ok := currentAdmin(ctx)
+			if !ok {
+				return
+			}
+			if admin.OTPEnabled == nil || !*admin.OTPEnabled || admin.OTPSecret == nil || *admin.OTPSecret == "" {
+				res := utils.GenerateForbiddenResponse(errors.New("2fa is not set up"))
+				ctx.JSON(res.HttpStatusCode, res)
+				ctx.Abort()
+				return
+			}
 			valid := utils.Validate2fa(req.OTP, *admin.OTPSecret)
 			if !valid {
 				res := utils.GenerateWrongOTPResponse(nil)
@@ -30,10 +56,25 @@ func OTPMiddleware(userType string) gin.HandlerFunc {
 			ctx.Next()
 			return
 		}
-		// implement for user otp validation
-		user := ctx.MustGet(userType).(*model.User)
+
+		value, _ := ctx.Get(userType)
+		user, ok := value.(*model.User)
+		if !ok {
+			res := utils.GenerateForbiddenResponse(errors.New("user only"))
+			ctx.JSON(res.HttpStatusCode, res)
+			ctx.Abort()
+			return
+		}
+		if !user.OTPEnabled || user.OTPSecret == "" {
+			res := utils.GenerateForbiddenResponse(errors.New("2fa is not set up"))
+			ctx.JSON(res.HttpStatusCode, res)
+			ctx.Abort()
+			return
+		}
 		valid := utils.Validate2fa(req.OTP, user.OTPSecret)
 		if !valid {
+			res := utils.GenerateWrongOTPResponse(nil)
+			ctx.JSON(res.HttpStatusCode, res)
 			ctx.Abort()
 			return
 		}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentAdmin returns the admin set by AuthMiddleware, the request is
+// answered with 403 when the token is not an admin's
+func currentAdmin(c *gin.Context) (*model.Admin, bool) {
+	if value, ok := c.Get("admin"); ok {
+		if admin, ok := value.(*model.Admin); ok && admin.ID != nil {
+			return admin, true
+		}
+	}
+	res := utils.GenerateForbiddenResponse(errors.New("admin only"))
+	c.JSON(res.HttpStatusCode, res)
+	c.Abort()
+	return nil, false
+}
+
+// PermissionMiddleware lets an admin through when one of their roles grants
+// the area, read for GET and HEAD and write for every other method. It goes
+// after AuthMiddleware on back office groups.
+func PermissionMiddleware(r *repository.Repository, area string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admin, ok := currentAdmin(c)
+		if !ok {
+			return
+		}
+
+		action := model.PermActionWrite
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			action = model.PermActionRead
+		}
+
+		permissions, err := r.Role.Permissions(c.Request.Context(), *admin.ID)
+		if err != nil {
+			res := utils.GenerateServerError(err)
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		if !model.HasPermission(permissions, area, action) {
+			res := utils.GenerateForbiddenResponse(fmt.Errorf("missing permission %s:%s", area, action))
+			c.JSON(res.HttpStatusCode, res)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"cryptoshare/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// a front user's token must be refused by admin-only middleware, not panic
+func TestAdminMiddlewareRefusesUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middlewares := map[string]gin.HandlerFunc{
+		"permission":   PermissionMiddleware(nil, model.PermAreaPayouts),
+		"admin factor": AdminFactorMiddleware(nil),
+		"passkey":      PasskeyMiddleware(nil),
+		"admin otp":    OTPMiddleware("admin"),
+	}
+	for name, middleware := range middlewares {
+		router := gin.New()
+		router.POST("/", func(c *gin.Context) {
+			c.Set("user", &model.User{})
+		}, middleware, func(c *gin.Context) {
+			t.Errorf("%s: handler reached without an admin", name)
+		})
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"otp":"123456"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: got %d, want 403", name, w.Code)
+		}
+	}
+}
+
+// roles grant read for GET and HEAD and write for every other method
+func TestPermissionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newTestRepository(t)
+	if err := repo.DS.DB.AutoMigrate(&model.Role{}, &model.AdminRole{}); err != nil {
+		t.Fatal(err)
+	}
+
+	roles := []*model.Role{
+		{ID: 1, Name: "reader", Permissions: []string{model.PermAreaPayouts + ":read"}},
+		{ID: 2, Name: "writer", Permissions: []string{model.PermAreaPayouts + ":write"}},
+		{ID: 3, Name: "other", Permissions: []string{model.PermAreaBanks + ":*"}},
+	}
+	if err := repo.DS.DB.Create(&roles).Error; err != nil {
+		t.Fatal(err)
+	}
+	// admin 4 has no role at all
+	assignments := []*model.AdminRole{{AdminID: 1, RoleID: 1}, {AdminID: 2, RoleID: 2}, {AdminID: 3, RoleID: 3}}
+	if err := repo.DS.DB.Create(&assignments).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		adminID uint64
+		method  string
+		want    int
+	}{
+		{"read granted", 1, http.MethodGet, http.StatusOK},
+		{"head is a read", 1, http.MethodHead, http.StatusOK},
+		{"write denied to a reader", 1, http.MethodPost, http.StatusForbidden},
+		{"delete denied to a reader", 1, http.MethodDelete, http.StatusForbidden},
+		{"write granted", 2, http.MethodPost, http.StatusOK},
+		{"read denied to a writer", 2, http.MethodGet, http.StatusForbidden},
+		{"other area denied", 3, http.MethodGet, http.StatusForbidden},
+		{"no role denied", 4, http.MethodGet, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := tt.adminID
+			router := gin.New()
+			router.Handle(tt.method, "/", func(c *gin.Context) {
+				c.Set("admin", &model.Admin{ID: &id})
+			}, PermissionMiddleware(repo, model.PermAreaPayouts), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, "/", nil))
+			if w.Code != tt.want {
+				t.Errorf("got %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewSession describes the login of the request
+func NewSession(c *gin.Context, username string, isAdmin bool) *model.Session {
+	area, err := utils.GetArea(c.ClientIP())
+	if err != nil {
+		log.Println(err)
+	}
+	return &model.Session{
+		Username:  username,
+		IsAdmin:   isAdmin,
+		Device:    utils.DeviceID(c.GetHeader("X-Device-ID"), c.Request.UserAgent()),
+		UserAgent: utils.Truncate(c.Request.UserAgent(), 255),
+		IP:        c.ClientIP(),
+		Area:      area,
+	}
+}
+
+// LoginLocked returns the response for a locked account or IP, nil when the
+// login may go on
+func LoginLocked(c *gin.Context, repo *repository.Repository, account string) *dto.Response {
+	wait, err := repo.Lockout.Locked(c.Request.Context(), account, c.ClientIP())
+	if err != nil {
+		return utils.GenerateServerError(err)
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		return utils.GenerateTooManyRequestsResponse(errors.New("too many failed attempts, try again later"))
+	}
+	return nil
+}
+
+// LoginFailed counts the failure and holds the answer back for its delay.
+// It reports whether this failure locked the account.
+func LoginFailed(c *gin.Context, repo *repository.Repository, account string) bool {
+	delay, locked, err := repo.Lockout.Fail(c.Request.Context(), account, c.ClientIP())
+	if err != nil {
+		log.Println(err, "Error counting login failure ", account)
+	}
+	select {
+	case <-time.After(delay):
+	case <-c.Request.Context().Done():
+	}
+	return locked
+}
+
+// MarkCurrentSession flags the session of the request's access token
+func MarkCurrentSession(c *gin.Context, sessions []*model.Session) {
+	current := c.GetString("session_id")
+	for _, session := range sessions {
+		session.Current = session.ID == current
+	}
+}
+
+// RefreshTokenFrom reads the refresh token from the body or, for browsers,
+// from its cookie
+func RefreshTokenFrom(c *gin.Context) string {
+	req := dto.RefreshReq{}
+	if err := c.ShouldBind(&req); err == nil && req.RefreshToken != "" {
+		return req.RefreshToken
+	}
+	token, _ := c.Cookie("refresh_token")
+	return token
+}
+
+// SetTokenCookies sets the access token for the whole api and the refresh
+// token only for the auth routes
+func SetTokenCookies(c *gin.Context, tokens *dto.TokenResp) {
+	c.SetCookie("token", tokens.AccessToken, int(conf.AccessTokenTTL.Seconds()), "/", c.Request.Host, true, true)
+	c.SetCookie("refresh_token", tokens.RefreshToken, int(conf.RefreshTokenTTL.Seconds()), "/api/auth", c.Request.Host, true, true)
+}
+
+func ClearTokenCookies(c *gin.Context) {
+	c.SetCookie("token", "", -1, "/", c.Request.Host, true, true)
+	c.SetCookie("refresh_token", "", -1, "/api/auth", c.Request.Host, true, true)
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bytes"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminFactorMiddleware guards sensitive back office actions with the
+// second factor of the admin, a passkey when the admin requires one and
+// the totp code of OTPMiddleware otherwise
+func AdminFactorMiddleware(repo *repository.Repository) gin.HandlerFunc {
+	otp := OTPMiddleware("admin")
+	passkey := PasskeyMiddleware(repo)
+	return func(ctx *gin.Context) {
+		admin, ok := currentAdmin(ctx)
+		if !ok {
+			return
+		}
+		if admin.RequiresPasskey() {
+			passkey(ctx)
+			return
+		}
+		otp(ctx)
+	}
+}
+
+// PasskeyMiddleware checks the passkey assertion of the body. A body without
+// one is answered with a challenge, the client signs it and sends the
+// request again. The body is put back so the handler can bind it too.
+func PasskeyMiddleware(repo *repository.Repository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		admin, ok := currentAdmin(ctx)
+		if !ok {
+			return
+		}
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			res := utils.GenerateBadRequestErrorResponse(err)
+			ctx.JSON(res.HttpStatusCode, res)
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		req := dto.PasskeyReq{}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				res := utils.GenerateBadRequestErrorResponse(err)
+				ctx.JSON(res.HttpStatusCode, res)
+				ctx.Abort()
+				return
+			}
+		}
+		if res := CheckPasskey(ctx, repo, admin, &req); res != nil {
+			ctx.JSON(res.HttpStatusCode, res)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// CheckPasskey verifies the assertion of req, or starts a challenge when
+// there is none. It returns nil once the passkey checks out.
+func CheckPasskey(ctx *gin.Context, repo *repository.Repository, admin *model.Admin, req *dto.PasskeyReq) *dto.Response {
+	if req.ChallengeID == "" || len(req.Assertion) == 0 {
+		id, options, err := repo.WebAuthn.BeginAssertion(ctx.Request.Context(), admin)
+		if err != nil {
+			return PasskeyErrorResponse(err)
+		}
+		res := &dto.Response{}
+		res.ErrCode = 400
+		res.ErrMsg = "passkey is required."
+		res.Data = &dto.PasskeyChallengeResp{ChallengeID: id, Options: options}
+		res.HttpStatusCode = http.StatusBadRequest
+		return res
+	}
+	if _, err := repo.WebAuthn.FinishAssertion(ctx.Request.Context(), admin, req.ChallengeID, req.Assertion); err != nil {
+		return PasskeyErrorResponse(err)
+	}
+	return nil
+}
+
+func PasskeyErrorResponse(err error) *dto.Response {
+	switch {
+	case errors.Is(err, repository.ErrInvalidChallenge), errors.Is(err, repository.ErrInvalidPasskey),
+		errors.Is(err, repository.ErrPasskeyClone):
+		res := utils.GenerateAuthErrorResponse(err)
+		res.ErrMsg = err.Error()
+		return res
+	case errors.Is(err, repository.ErrNoPasskey), errors.Is(err, repository.ErrLastRequiredPasskey):
+		return utils.GenerateForbiddenResponse(err)
+	case errors.Is(err, repository.ErrWebAuthnDisabled):
+		return utils.GenerateServiceUnavailableResponse(err)
+	}
+	return utils.GenerateGormErrorResponse(err)
+}
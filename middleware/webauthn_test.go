@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"cryptoshare/conf"
+	"cryptoshare/ds"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/go-redis/redis/v9"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestRepository(t *testing.T) *repository.Repository {
+	t.Helper()
+	conf.WebAuthnRPID = "example.com"
+	conf.WebAuthnRPOrigin = "https://example.com"
+	conf.WebAuthnRPName = "test"
+
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.WebAuthnCredential{}); err != nil {
+		t.Fatal(err)
+	}
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return repository.NewRepository(&ds.DataSource{DB: db, RDB: rdb}, nil)
+}
+
+// AdminFactorMiddleware asks admins that require passkeys for one, the totp
+// code of the others is checked as before
+func TestAdminFactorMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newTestRepository(t)
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "test", AccountName: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := key.Secret()
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newAdmin := func(id uint64, passkey bool) *model.Admin {
+		name := fmt.Sprintf("admin%d", id)
+		enabled := true
+		return &model.Admin{ID: &id, Name: &name, Username: &name, OTPEnabled: &enabled, OTPSecret: &secret, PasskeyRequired: &passkey}
+	}
+	otpAdmin := newAdmin(1, false)
+	passkeyAdmin := newAdmin(2, true)
+	err = repo.DS.DB.Create(&model.WebAuthnCredential{AdminID: 2, Name: "laptop", CredentialID: "Y3JlZGVudGlhbA", PublicKey: []byte{1}}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(admin *model.Admin, body string) (*httptest.ResponseRecorder, bool) {
+		reached := false
+		router := gin.New()
+		router.POST("/", func(c *gin.Context) {
+			c.Set("admin", admin)
+		}, AdminFactorMiddleware(repo), func(c *gin.Context) {
+			reached = true
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w, reached
+	}
+
+	if w, reached := serve(otpAdmin, fmt.Sprintf(`{"otp":%q}`, code)); !reached {
+		t.Fatalf("valid code refused: %d %s", w.Code, w.Body)
+	}
+	wrong := "000000"
+	if wrong == code {
+		wrong = "111111"
+	}
+	if _, reached := serve(otpAdmin, fmt.Sprintf(`{"otp":%q}`, wrong)); reached {
+		t.Fatal("wrong code accepted")
+	}
+
+	// a totp code doesn't do for an admin requiring passkeys, a challenge
+	// for the registered passkey is sent back
+	w, reached := serve(passkeyAdmin, fmt.Sprintf(`{"otp":%q}`, code))
+	if reached || w.Code != http.StatusBadRequest {
+		t.Fatalf("totp code accepted instead of a passkey: %d %s", w.Code, w.Body)
+	}
+	res := struct {
+		Data struct {
+			ChallengeID string                        `json:"challenge_id"`
+			Options     *protocol.CredentialAssertion `json:"options"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Data.ChallengeID == "" || res.Data.Options == nil || len(res.Data.Options.Response.AllowedCredentials) != 1 {
+		t.Fatalf("no passkey challenge in %s", w.Body)
+	}
+
+	// an assertion for an unknown challenge is refused
+	w, reached = serve(passkeyAdmin, `{"challenge_id":"unknown","assertion":{"id":"x"}}`)
+	if reached || w.Code != http.StatusUnauthorized {
+		t.Fatalf("unknown challenge: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AuditActorAdmin  = "admin"
+	AuditActorUser   = "user"
+	AuditActorSystem = "system"
+	// AuditActorAnonymous makes requests before authenticating
+	AuditActorAnonymous = "anonymous"
+)
+
+// AuditLog is one change made through the apps. Every entry is chained to
+// the one before it by PrevHash, so editing or removing any of them breaks
+// the hashes of everything recorded after.
+type AuditLog struct {
+	ID        uint64          `gorm:"column:id;primaryKey" json:"id"`
+	Seq       uint64          `gorm:"column:seq;uniqueIndex;not null" json:"seq"`
+	ActorType string          `gorm:"column:actor_type;type:varchar(10);index:idx_audit_actor" json:"actor_type"`
+	ActorID   string          `gorm:"column:actor_id;type:varchar(36);index:idx_audit_actor" json:"actor_id"`
+	Actor     string          `gorm:"column:actor;type:varchar(100)" json:"actor"`
+	Action    string          `gorm:"column:action;type:varchar(150);index" json:"action"`
+	Target    string          `gorm:"column:target;type:varchar(255);index" json:"target"`
+	Before    json.RawMessage `gorm:"column:before;type:text" json:"before"`
+	After     json.RawMessage `gorm:"column:after;type:text" json:"after"`
+	Status    int             `gorm:"column:status" json:"status"`
+	IP        string          `gorm:"column:ip;type:varchar(50)" json:"ip"`
+	UserAgent string          `gorm:"column:user_agent;type:varchar(255)" json:"user_agent"`
+	PrevHash  string          `gorm:"column:prev_hash;type:char(64)" json:"prev_hash"`
+	Hash      string          `gorm:"column:hash;type:char(64)" json:"hash"`
+	CreatedAt time.Time       `gorm:"column:created_at;index" json:"created_at"`
+}
+
+// AuditHead is the single row holding the end of the chain, appending
+// locks it so the writers of every process take turns
+type AuditHead struct {
+	ID   uint64 `gorm:"column:id;primaryKey;autoIncrement:false"`
+	Seq  uint64 `gorm:"column:seq"`
+	Hash string `gorm:"column:hash;type:char(64)"`
+}
+
+// ComputeHash hashes the entry together with the hash of the one before it
+func (a *AuditLog) ComputeHash() string {
+	fields := []string{
+		fmt.Sprint(a.Seq),
+		a.PrevHash,
+		a.ActorType,
+		a.ActorID,
+		a.Actor,
+		a.Action,
+		a.Target,
+		string(a.Before),
+		string(a.After),
+		fmt.Sprint(a.Status),
+		a.IP,
+		a.UserAgent,
+		fmt.Sprint(a.CreatedAt.UnixMilli()),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DepositStatusDetected  = "detected"
+	DepositStatusConfirmed = "confirmed"
+	// DepositStatusHeld comes from a screened address and waits for review
+	DepositStatusHeld     = "held"
+	DepositStatusRejected = "rejected"
+	// DepositStatusDropped left the canonical chain before it was confirmed
+	DepositStatusDropped = "dropped"
+)
+
+// DepositNativeLogIndex is the log index of native coin transfers, which
+// have no log
+const DepositNativeLogIndex = -1
+
+// Deposit is a transfer into a user wallet. BlockTime is when it was mined,
+// invoices are matched on it. ReviewedBy is the admin that released or
+// rejected a held deposit.
+type Deposit struct {
+	ID          uint64     `gorm:"column:id;primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"column:user_id;type:char(36);index" json:"user_id"`
+	WalletID    uuid.UUID  `gorm:"column:wallet_id;type:char(36)" json:"wallet_id"`
+	InvoiceID   *uuid.UUID `gorm:"column:invoice_id;type:char(36)" json:"invoice_id"`
+	TxHash      string     `gorm:"column:tx_hash;type:varchar(100);uniqueIndex:idx_tx_log" json:"tx_hash"`
+	LogIndex    int        `gorm:"column:log_index;uniqueIndex:idx_tx_log" json:"log_index"`
+	Network     string     `gorm:"column:network;type:enum('ERC20','TRC20');default:ERC20" json:"network"`
+	Currency    string     `gorm:"column:currency;type:enum('ETH','TRX','USDT');default:USDT" json:"currency"`
+	FromAddress string     `gorm:"column:from_address;type:varchar(255)" json:"from_address"`
+	ToAddress   string     `gorm:"column:to_address;type:varchar(255);index" json:"to_address"`
+	Amount      float64    `gorm:"column:amount" json:"amount"`
+	BlockNumber uint64     `gorm:"column:block_number" json:"block_number"`
+	BlockTime   *time.Time `gorm:"column:block_time" json:"block_time"`
+	Status      string     `gorm:"column:status;type:enum('detected','confirmed','held','rejected','dropped');default:detected;index" json:"status"`
+	ReviewedBy  *uint64    `gorm:"column:reviewed_by" json:"reviewed_by"`
+	ConfirmedAt *time.Time `gorm:"column:confirmed_at" json:"confirmed_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// GeoAllowIP is an address or CIDR range let through geo-blocking
+type GeoAllowIP struct {
+	ID        uint64    `gorm:"column:id;primaryKey" json:"id"`
+	CIDR      string    `gorm:"column:cidr;type:varchar(50);unique" json:"cidr"`
+	Note      string    `gorm:"column:note;type:varchar(255)" json:"note"`
+	CreatedBy uint64    `gorm:"column:created_by" json:"created_by"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
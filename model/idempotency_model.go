@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// IdempotencyKey keeps the response of a request sent with an Idempotency-Key
+// header, so a retry gets the same response instead of running again.
+type IdempotencyKey struct {
+	ID          uint64    `gorm:"column:id;primaryKey" json:"id"`
+	Scope       string    `gorm:"column:scope;type:varchar(191);uniqueIndex:idx_scope_key" json:"scope"`
+	Key         string    `gorm:"column:key;type:varchar(255);uniqueIndex:idx_scope_key" json:"key"`
+	Fingerprint string    `gorm:"column:fingerprint;type:char(64)" json:"fingerprint"`
+	StatusCode  int       `gorm:"column:status_code" json:"status_code"`
+	Response    string    `gorm:"column:response;type:mediumtext" json:"response"`
+	ExpiresAt   time.Time `gorm:"column:expires_at;index" json:"expires_at"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+}
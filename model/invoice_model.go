@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	InvoiceStatusOpen      = "open"
+	InvoiceStatusPaid      = "paid"
+	InvoiceStatusUnderpaid = "underpaid"
+	InvoiceStatusOverpaid  = "overpaid"
+	InvoiceStatusExpired   = "expired"
+)
+
+type Invoice struct {
+	ID         uuid.UUID  `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"column:user_id;type:char(36);index" json:"user_id"`
+	WalletID   uuid.UUID  `gorm:"column:wallet_id;type:char(36)" json:"wallet_id"`
+	Network    string     `gorm:"column:network;type:enum('ERC20','TRC20');default:ERC20" json:"network"`
+	Currency   string     `gorm:"column:currency;type:enum('ETH','TRX','USDT');default:USDT" json:"currency"`
+	Address    string     `gorm:"column:address;type:varchar(255);index" json:"address"`
+	Amount     float64    `gorm:"column:amount;not null" json:"amount"`
+	PaidAmount float64    `gorm:"column:paid_amount;default:0;not null" json:"paid_amount"`
+	Memo       string     `gorm:"column:memo;type:varchar(255)" json:"memo"`
+	Status     string     `gorm:"column:status;type:enum('open','paid','underpaid','overpaid','expired');default:open;index" json:"status"`
+	TxHash     string     `gorm:"column:tx_hash;type:varchar(100)" json:"tx_hash"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at" json:"expires_at"`
+	PaidAt     *time.Time `gorm:"column:paid_at" json:"paid_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (invoice *Invoice) BeforeCreate(*gorm.DB) error {
+	invoice.ID = uuid.New()
+	return nil
+}
+
+// Pay adds a deposit to the invoice and updates its status. An underpaid
+// invoice stays payable so the rest can be sent before it expires.
+func (invoice *Invoice) Pay(amount float64, txHash string, at time.Time) {
+	invoice.PaidAmount += amount
+	invoice.TxHash = txHash
+	invoice.PaidAt = &at
+
+	// compare in the smallest unit so float noise doesn't flip the status
+	paid, due := int64(invoice.PaidAmount*1e6+0.5), int64(invoice.Amount*1e6+0.5)
+	switch {
+	case paid < due:
+		invoice.Status = InvoiceStatusUnderpaid
+	case paid > due:
+		invoice.Status = InvoiceStatusOverpaid
+	default:
+		invoice.Status = InvoiceStatusPaid
+	}
+}
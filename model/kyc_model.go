@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// KYCLevelNone has not passed any check
+	KYCLevelNone = 0
+	// KYCLevelBasic has its identity data reviewed
+	KYCLevelBasic = 1
+	// KYCLevelFull has its identity documents reviewed as well
+	KYCLevelFull = 2
+)
+
+const (
+	KYCPending  = "pending"
+	KYCApproved = "approved"
+	KYCRejected = "rejected"
+)
+
+const (
+	KYCDocumentFront  = "front"
+	KYCDocumentBack   = "back"
+	KYCDocumentSelfie = "selfie"
+)
+
+// KYCDocumentKinds are the files a submission accepts
+var KYCDocumentKinds = []string{KYCDocumentFront, KYCDocumentBack, KYCDocumentSelfie}
+
+// KYCTier is the limit tier a user gets at level
+func KYCTier(level int) string {
+	if level >= KYCLevelFull {
+		return TierVerified
+	}
+	return TierUnverified
+}
+
+// KYCSubmission is the identity data a user sends for review to reach Level
+type KYCSubmission struct {
+	ID             uint64         `gorm:"column:id;primaryKey" json:"id"`
+	UserID         uuid.UUID      `gorm:"column:user_id;type:char(36);index" json:"user_id"`
+	Level          int            `gorm:"column:level" json:"level"`
+	Status         string         `gorm:"column:status;type:enum('pending','approved','rejected');default:pending;index" json:"status"`
+	FirstName      string         `gorm:"column:first_name;type:varchar(100)" json:"first_name"`
+	LastName       string         `gorm:"column:last_name;type:varchar(100)" json:"last_name"`
+	DateOfBirth    string         `gorm:"column:date_of_birth;type:varchar(10)" json:"date_of_birth"`
+	Country        string         `gorm:"column:country;type:varchar(2)" json:"country"`
+	Address        string         `gorm:"column:address;type:varchar(255)" json:"address"`
+	DocumentType   string         `gorm:"column:document_type;type:varchar(20)" json:"document_type"`
+	DocumentNumber string         `gorm:"column:document_number;type:varchar(50)" json:"document_number"`
+	Reason         string         `gorm:"column:reason;type:varchar(500)" json:"reason"`
+	ReviewedBy     *uint64        `gorm:"column:reviewed_by" json:"reviewed_by"`
+	ReviewedAt     *time.Time     `gorm:"column:reviewed_at" json:"reviewed_at"`
+	CreatedAt      time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	Documents      []*KYCDocument `gorm:"foreignKey:SubmissionID" json:"documents,omitempty"`
+	User           *User          `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// KYCDocument is an uploaded file of a submission, the content is in the
+// blob store under BlobKey
+type KYCDocument struct {
+	ID           uint64    `gorm:"column:id;primaryKey" json:"id"`
+	SubmissionID uint64    `gorm:"column:submission_id;index" json:"submission_id"`
+	Kind         string    `gorm:"column:kind;type:varchar(20)" json:"kind"`
+	BlobKey      string    `gorm:"column:blob_key;type:varchar(255)" json:"-"`
+	ContentType  string    `gorm:"column:content_type;type:varchar(100)" json:"content_type"`
+	Size         int64     `gorm:"column:size" json:"size"`
+	CreatedAt    time.Time `gorm:"column:created_at" json:"created_at"`
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TierUnverified = "unverified"
+	TierVerified   = "verified"
+)
+
+var UserTiers = []string{TierUnverified, TierVerified}
+
+// LimitCurrencies are the assets limits are kept for
+var LimitCurrencies = []string{"ETH", "TRX", "USDT"}
+
+// TierLimit caps withdrawals of a currency for every user of a tier, a nil
+// cap is unlimited
+type TierLimit struct {
+	ID        uint64    `gorm:"column:id;primaryKey" json:"id"`
+	Tier      string    `gorm:"column:tier;type:varchar(20);uniqueIndex:idx_tier_currency" json:"tier"`
+	Currency  string    `gorm:"column:currency;type:enum('ETH','TRX','USDT');uniqueIndex:idx_tier_currency" json:"currency"`
+	PerTx     *float64  `gorm:"column:per_tx" json:"per_tx"`
+	Daily     *float64  `gorm:"column:daily" json:"daily"`
+	Monthly   *float64  `gorm:"column:monthly" json:"monthly"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// LimitUnlimited as a cap of a UserLimit lifts the tier's cap, nil keeps it
+const LimitUnlimited = -1
+
+// UserLimit overrides the tier caps of one user, a nil cap keeps the tier's
+// and LimitUnlimited removes it
+type UserLimit struct {
+	ID        uint64    `gorm:"column:id;primaryKey" json:"id"`
+	UserID    uuid.UUID `gorm:"column:user_id;type:char(36);uniqueIndex:idx_user_currency" json:"user_id"`
+	Currency  string    `gorm:"column:currency;type:enum('ETH','TRX','USDT');uniqueIndex:idx_user_currency" json:"currency"`
+	PerTx     *float64  `gorm:"column:per_tx" json:"per_tx"`
+	Daily     *float64  `gorm:"column:daily" json:"daily"`
+	Monthly   *float64  `gorm:"column:monthly" json:"monthly"`
+	UpdatedBy uint64    `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
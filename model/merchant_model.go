@@ -0,0 +1,63 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	ScopeBalancesRead   = "balances:read"
+	ScopeInvoicesCreate = "invoices:create"
+	ScopePayoutsCreate  = "payouts:create"
+)
+
+var APIKeyScopes = []string{ScopeBalancesRead, ScopeInvoicesCreate, ScopePayoutsCreate}
+
+// Merchant lets a user call the api from a backend with api keys
+type Merchant struct {
+	ID        uuid.UUID      `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID      `gorm:"column:user_id;type:char(36);unique;not null" json:"user_id"`
+	Name      string         `gorm:"column:name;type:varchar(100);not null" json:"name"`
+	CreatedAt time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-"`
+	User      *User          `gorm:"foreignKey:UserID;references:ID" json:"-"`
+}
+
+func (merchant *Merchant) BeforeCreate(*gorm.DB) error {
+	merchant.ID = uuid.New()
+	return nil
+}
+
+// APIKey is looked up by the hash of the key. The secret signs requests with
+// HMAC, so it has to be readable and is stored AES encrypted instead of hashed.
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	MerchantID uuid.UUID  `gorm:"column:merchant_id;type:char(36);index" json:"merchant_id"`
+	Label      string     `gorm:"column:label;type:varchar(100)" json:"label"`
+	KeyPrefix  string     `gorm:"column:key_prefix;type:varchar(20)" json:"key_prefix"`
+	KeyHash    string     `gorm:"column:key_hash;type:char(64);unique;not null" json:"-"`
+	Secret     string     `gorm:"column:secret;type:varchar(255);not null" json:"-"`
+	Scopes     string     `gorm:"column:scopes;type:varchar(255)" json:"scopes"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"created_at"`
+	Merchant   *Merchant  `gorm:"foreignKey:MerchantID;references:ID" json:"-"`
+}
+
+func (key *APIKey) BeforeCreate(*gorm.DB) error {
+	key.ID = uuid.New()
+	return nil
+}
+
+func (key *APIKey) HasScope(scope string) bool {
+	for _, s := range strings.Split(key.Scopes, ",") {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -4,4 +4,5 @@ const (
 	StateSuccess  = 1
 	StateTransfer = 2
 	StateFail     = 3
+	StateReplaced = 4
 )
@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+const (
+	PayoutBatchPreviewed = "previewed"
+	PayoutBatchExecuting = "executing"
+	PayoutBatchCompleted = "completed"
+)
+
+const (
+	PayoutRowPending = "pending"
+	// PayoutRowSending is a row being broadcast, one left in it after a crash
+	// is failed instead of being sent again
+	PayoutRowSending = "sending"
+	PayoutRowSent    = "sent"
+	PayoutRowFailed  = "failed"
+)
+
+// PayoutBatch is a CSV of payouts from one bank, checked and previewed before
+// an admin executes it
+type PayoutBatch struct {
+	ID          uint64       `gorm:"column:id;primaryKey" json:"id"`
+	BankID      uint64       `gorm:"column:bank_id;index" json:"bank_id"`
+	CreatedBy   uint64       `gorm:"column:created_by" json:"created_by"`
+	ExecutedBy  *uint64      `gorm:"column:executed_by" json:"executed_by"`
+	FileName    string       `gorm:"column:file_name;type:varchar(255)" json:"file_name"`
+	Network     string       `gorm:"column:network;type:enum('ERC20','TRC20')" json:"network"`
+	Status      string       `gorm:"column:status;type:enum('previewed','executing','completed');default:previewed" json:"status"`
+	RowCount    int          `gorm:"column:row_count" json:"row_count"`
+	SentCount   int          `gorm:"column:sent_count" json:"sent_count"`
+	FailedCount int          `gorm:"column:failed_count" json:"failed_count"`
+	ExecutedAt  *time.Time   `gorm:"column:executed_at" json:"executed_at"`
+	CompletedAt *time.Time   `gorm:"column:completed_at" json:"completed_at"`
+	CreatedAt   time.Time    `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   time.Time    `gorm:"column:updated_at" json:"updated_at"`
+	Rows        []*PayoutRow `gorm:"foreignKey:BatchID" json:"rows,omitempty"`
+}
+
+type PayoutRow struct {
+	ID        uint64     `gorm:"column:id;primaryKey" json:"id"`
+	BatchID   uint64     `gorm:"column:batch_id;index" json:"batch_id"`
+	Line      int        `gorm:"column:line" json:"line"`
+	Address   string     `gorm:"column:address;type:varchar(255)" json:"address"`
+	Amount    float64    `gorm:"column:amount" json:"amount"`
+	Network   string     `gorm:"column:network;type:enum('ERC20','TRC20')" json:"network"`
+	Token     string     `gorm:"column:token;type:enum('ETH','TRX','USDT')" json:"token"`
+	Reference string     `gorm:"column:reference;type:varchar(255)" json:"reference"`
+	Status    string     `gorm:"column:status;type:enum('pending','sending','sent','failed');default:pending" json:"status"`
+	TxHash    string     `gorm:"column:tx_hash;type:varchar(100)" json:"tx_hash"`
+	Error     string     `gorm:"column:error;type:varchar(500)" json:"error"`
+	SentAt    *time.Time `gorm:"column:sent_at" json:"sent_at"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
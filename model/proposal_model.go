@@ -0,0 +1,83 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Kinds of sensitive requests that need other admins to approve them
+const (
+	ProposalBankCreate          = "bank_create"
+	ProposalBankUpdate          = "bank_update"
+	ProposalAdminCreate         = "admin_create"
+	ProposalAdminUpdate         = "admin_update"
+	ProposalAdminTwoFactorReset = "admin_2fa_reset"
+	ProposalAdminRoles          = "admin_roles"
+	ProposalWithdrawalApproval  = "withdrawal_approval"
+	ProposalPayoutExecute       = "payout_execute"
+)
+
+// ProposalAreas is the permission area an admin needs write on to approve
+// or reject a kind of proposal
+var ProposalAreas = map[string]string{
+	ProposalBankCreate:          PermAreaBanks,
+	ProposalBankUpdate:          PermAreaBanks,
+	ProposalAdminCreate:         PermAreaAdmins,
+	ProposalAdminUpdate:         PermAreaAdmins,
+	ProposalAdminTwoFactorReset: PermAreaAdmins,
+	ProposalAdminRoles:          PermAreaRoles,
+	ProposalWithdrawalApproval:  PermAreaRisk,
+	ProposalPayoutExecute:       PermAreaPayouts,
+}
+
+const (
+	ProposalStatusPending   = "pending"
+	ProposalStatusExecuting = "executing"
+	ProposalStatusExecuted  = "executed"
+	ProposalStatusFailed    = "failed"
+	ProposalStatusRejected  = "rejected"
+	ProposalStatusCancelled = "cancelled"
+	ProposalStatusExpired   = "expired"
+)
+
+const (
+	ProposalEventProposed  = "proposed"
+	ProposalEventApproved  = "approved"
+	ProposalEventRejected  = "rejected"
+	ProposalEventCancelled = "cancelled"
+	ProposalEventExecuted  = "executed"
+	ProposalEventFailed    = "failed"
+	ProposalEventExpired   = "expired"
+)
+
+// Proposal is a sensitive request held until Required admins other than the
+// proposer approve it. Payload is what runs then, secrets in it are already
+// encrypted or hashed. Summary is the redacted copy shown to reviewers.
+type Proposal struct {
+	ID         uint64           `gorm:"column:id;primaryKey" json:"id"`
+	Kind       string           `gorm:"column:kind;type:varchar(30);index:idx_proposal_kind_target" json:"kind"`
+	Target     string           `gorm:"column:target;type:varchar(100);index:idx_proposal_kind_target" json:"target"`
+	Payload    []byte           `gorm:"column:payload;type:text" json:"-"`
+	Summary    json.RawMessage  `gorm:"column:summary;type:text" json:"summary"`
+	Status     string           `gorm:"column:status;type:varchar(20);index" json:"status"`
+	Required   int              `gorm:"column:required" json:"required"`
+	Approvals  int              `gorm:"column:approvals" json:"approvals"`
+	ProposedBy uint64           `gorm:"column:proposed_by" json:"proposed_by"`
+	ExpiresAt  time.Time        `gorm:"column:expires_at;index" json:"expires_at"`
+	ExecutedAt *time.Time       `gorm:"column:executed_at" json:"executed_at"`
+	Error      string           `gorm:"column:error;type:varchar(500)" json:"error"`
+	Events     []*ProposalEvent `gorm:"foreignKey:ProposalID" json:"events,omitempty"`
+	CreatedAt  time.Time        `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt  time.Time        `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// ProposalEvent is the history of a proposal, AdminID is nil for what the
+// system did, like expiring it
+type ProposalEvent struct {
+	ID         uint64    `gorm:"column:id;primaryKey" json:"id"`
+	ProposalID uint64    `gorm:"column:proposal_id;index" json:"proposal_id"`
+	AdminID    *uint64   `gorm:"column:admin_id" json:"admin_id"`
+	Event      string    `gorm:"column:event;type:varchar(20)" json:"event"`
+	Note       string    `gorm:"column:note;type:varchar(500)" json:"note"`
+	CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// RecoveryCodeCount codes are issued when 2fa is enabled
+const RecoveryCodeCount = 10
+
+// RecoveryCode stands in for a totp code once, for users or admins that lost
+// their authenticator. Only its hash is kept.
+type RecoveryCode struct {
+	ID        uint       `gorm:"column:id;primaryKey" json:"id"`
+	OwnerType string     `gorm:"column:owner_type;type:varchar(10);index:idx_recovery_code_owner" json:"owner_type"`
+	OwnerID   string     `gorm:"column:owner_id;type:varchar(36);index:idx_recovery_code_owner" json:"owner_id"`
+	CodeHash  string     `gorm:"column:code_hash;type:char(64)" json:"-"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+}
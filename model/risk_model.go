@@ -0,0 +1,77 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RiskApprove = "approve"
+	RiskReview  = "review"
+	RiskBlock   = "block"
+)
+
+const (
+	RiskSignalCountry     = "country_mismatch"
+	RiskSignalDevice      = "new_device"
+	RiskSignalPassword    = "recent_password_change"
+	RiskSignalOTP         = "recent_2fa_change"
+	RiskSignalAmount      = "unusual_amount"
+	RiskSignalDestination = "new_destination"
+)
+
+// a review starts pending, an admin approves or rejects it and the approved
+// withdrawal ends sent or failed. The review of a scheduled run expires when
+// the next run of its schedule comes due.
+const (
+	RiskReviewPending  = "pending"
+	RiskReviewApproved = "approved"
+	RiskReviewRejected = "rejected"
+	RiskReviewSent     = "sent"
+	RiskReviewFailed   = "failed"
+	RiskReviewExpired  = "expired"
+)
+
+// RiskAssessment is the score of a withdrawal. Those sent to manual review
+// keep the request so it can be sent once approved.
+type RiskAssessment struct {
+	ID           uint64     `gorm:"column:id;primaryKey" json:"id"`
+	UserID       uuid.UUID  `gorm:"column:user_id;type:char(36);index" json:"user_id"`
+	Type         string     `gorm:"column:type;type:varchar(20)" json:"type"`
+	Network      string     `gorm:"column:network;type:enum('ERC20','TRC20')" json:"network"`
+	Currency     string     `gorm:"column:currency;type:enum('ETH','TRX','USDT')" json:"currency"`
+	ToAddress    string     `gorm:"column:to_address;type:varchar(255)" json:"to_address"`
+	Amount       float64    `gorm:"column:amount" json:"amount"`
+	IP           string     `gorm:"column:ip;type:varchar(50)" json:"ip"`
+	Area         string     `gorm:"column:area;type:varchar(255)" json:"area"`
+	Device       string     `gorm:"column:device;type:varchar(64)" json:"device"`
+	Score        int        `gorm:"column:score" json:"score"`
+	Signals      string     `gorm:"column:signals;type:varchar(255)" json:"signals"`
+	Decision     string     `gorm:"column:decision;type:enum('approve','review','block');index" json:"decision"`
+	ReviewStatus string     `gorm:"column:review_status;type:varchar(20);index" json:"review_status"`
+	ReviewedBy   *uint64    `gorm:"column:reviewed_by" json:"reviewed_by"`
+	ReviewedAt   *time.Time `gorm:"column:reviewed_at" json:"reviewed_at"`
+	TxHash       string     `gorm:"column:tx_hash;type:varchar(100)" json:"tx_hash"`
+	Error        string     `gorm:"column:error;type:varchar(500)" json:"error"`
+	CreatedAt    time.Time  `gorm:"column:created_at;index" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (a *RiskAssessment) SignalList() []string {
+	if a.Signals == "" {
+		return nil
+	}
+	return strings.Split(a.Signals, ",")
+}
+
+// UserDevice is a device the user has signed in or withdrawn from
+type UserDevice struct {
+	ID        uint64    `gorm:"column:id;primaryKey" json:"id"`
+	UserID    uuid.UUID `gorm:"column:user_id;type:char(36);uniqueIndex:idx_user_device" json:"user_id"`
+	Device    string    `gorm:"column:device;type:varchar(64);uniqueIndex:idx_user_device" json:"device"`
+	IP        string    `gorm:"column:ip;type:varchar(50)" json:"ip"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+	LastSeen  time.Time `gorm:"column:last_seen" json:"last_seen"`
+}
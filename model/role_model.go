@@ -0,0 +1,138 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Areas of the back office a permission is granted on
+const (
+	PermAreaAdmins    = "admins"
+	PermAreaRoles     = "roles"
+	PermAreaBanks     = "banks"
+	PermAreaTx        = "transactions"
+	PermAreaPayouts   = "payouts"
+	PermAreaLimits    = "limits"
+	PermAreaKYC       = "kyc"
+	PermAreaScreening = "screening"
+	PermAreaRisk      = "risk"
+	PermAreaGeo       = "geo"
+	PermAreaAudit     = "audit"
+	PermAreaProposals = "proposals"
+	PermAreaUsers     = "users"
+)
+
+var PermAreas = []string{
+	PermAreaAdmins, PermAreaRoles, PermAreaBanks, PermAreaTx, PermAreaPayouts,
+	PermAreaLimits, PermAreaKYC, PermAreaScreening, PermAreaRisk, PermAreaGeo,
+	PermAreaAudit, PermAreaProposals, PermAreaUsers,
+}
+
+const (
+	PermActionRead  = "read"
+	PermActionWrite = "write"
+)
+
+// Built-in roles, seeded on start and never changed through the api
+const (
+	RoleSuperAdmin = "super-admin"
+	RoleFinance    = "finance"
+	RoleSupport    = "support"
+	RoleReadOnly   = "read-only"
+)
+
+// Role is a named set of permissions. A permission is "<area>:<action>",
+// either part may be "*", and "*" alone grants everything.
+type Role struct {
+	ID          uint64    `gorm:"column:id;primaryKey" json:"id"`
+	Name        string    `gorm:"column:name;type:varchar(50);unique;not null" json:"name"`
+	Description string    `gorm:"column:description;type:varchar(255)" json:"description"`
+	Permissions []string  `gorm:"column:permissions;serializer:json" json:"permissions"`
+	Builtin     bool      `gorm:"column:builtin;default:false;not null" json:"builtin"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// AdminRole assigns a role to an admin
+type AdminRole struct {
+	AdminID   uint64    `gorm:"column:admin_id;primaryKey" json:"admin_id"`
+	RoleID    uint64    `gorm:"column:role_id;primaryKey;index" json:"role_id"`
+	Role      *Role     `gorm:"foreignKey:RoleID" json:"role,omitempty"`
+	CreatedBy uint64    `gorm:"column:created_by" json:"created_by"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// BuiltinRoles are the roles every deployment starts with
+var BuiltinRoles = []*Role{
+	{
+		Name:        RoleSuperAdmin,
+		Description: "Everything, including admins and roles",
+		Permissions: []string{"*"},
+	},
+	{
+		Name:        RoleFinance,
+		Description: "Banks, payouts, limits and withdrawal reviews",
+		Permissions: []string{
+			"*:read",
+			PermAreaBanks + ":*",
+			PermAreaTx + ":*",
+			PermAreaPayouts + ":*",
+			PermAreaLimits + ":*",
+			PermAreaRisk + ":*",
+		},
+	},
+	{
+		Name:        RoleSupport,
+		Description: "Users' KYC, lockouts and held deposits",
+		Permissions: []string{
+			PermAreaUsers + ":*",
+			PermAreaTx + ":read",
+			PermAreaLimits + ":read",
+			PermAreaRisk + ":read",
+			PermAreaKYC + ":*",
+			PermAreaScreening + ":*",
+		},
+	},
+	{
+		Name:        RoleReadOnly,
+		Description: "Sees everything, changes nothing",
+		Permissions: []string{"*:read"},
+	},
+}
+
+// HasPermission reports whether any of granted allows action on area
+func HasPermission(granted []string, area, action string) bool {
+	for _, perm := range granted {
+		if perm == "*" {
+			return true
+		}
+		permArea, permAction, _ := strings.Cut(perm, ":")
+		if (permArea == "*" || permArea == area) && (permAction == "*" || permAction == action) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPermission checks the format of a permission of a custom role
+func ValidPermission(perm string) bool {
+	if perm == "*" {
+		return true
+	}
+	area, action, ok := strings.Cut(perm, ":")
+	if !ok {
+		return false
+	}
+	if action != "*" && action != PermActionRead && action != PermActionWrite {
+		return false
+	}
+	if area == "*" {
+		return true
+	}
+	for _, known := range PermAreas {
+		if area == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	ScheduleKindWithdrawal = "withdrawal"
+	ScheduleKindInternal   = "internal"
+)
+
+const (
+	ScheduleStatusActive    = "active"
+	ScheduleStatusPaused    = "paused"
+	ScheduleStatusCancelled = "cancelled"
+	ScheduleStatusCompleted = "completed"
+)
+
+const (
+	ScheduleRunSent    = "sent"
+	ScheduleRunSkipped = "skipped"
+	ScheduleRunFailed  = "failed"
+	// ScheduleRunHeld waits for manual risk review, it is sent if an admin
+	// approves its assessment
+	ScheduleRunHeld = "held"
+	// ScheduleRunRejected and ScheduleRunExpired are held runs an admin
+	// rejected or nobody reviewed before the next run came due
+	ScheduleRunRejected = "rejected"
+	ScheduleRunExpired  = "expired"
+)
+
+// ScheduledTransfer sends the same transfer on every run of Rule, see
+// utils.ParseSchedule. It completes after EndAt or MaxRuns sent transfers.
+type ScheduledTransfer struct {
+	ID        uuid.UUID  `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"column:user_id;type:char(36);index" json:"user_id"`
+	Kind      string     `gorm:"column:kind;type:enum('withdrawal','internal');default:withdrawal" json:"kind"`
+	Network   string     `gorm:"column:network;type:enum('ERC20','TRC20')" json:"network"`
+	Currency  string     `gorm:"column:currency;type:enum('ETH','TRX','USDT')" json:"currency"`
+	Amount    float64    `gorm:"column:amount" json:"amount"`
+	ToAddress string     `gorm:"column:to_address;type:varchar(255)" json:"to_address"`
+	Username  string     `gorm:"column:username;type:varchar(100)" json:"username"`
+	Rule      string     `gorm:"column:rule;type:varchar(100)" json:"rule"`
+	StartAt   time.Time  `gorm:"column:start_at" json:"start_at"`
+	EndAt     *time.Time `gorm:"column:end_at" json:"end_at"`
+	// MaxRuns of 0 is unlimited
+	MaxRuns   int        `gorm:"column:max_runs" json:"max_runs"`
+	RunCount  int        `gorm:"column:run_count" json:"run_count"`
+	NextRunAt *time.Time `gorm:"column:next_run_at;index:idx_status_next" json:"next_run_at"`
+	LastRunAt *time.Time `gorm:"column:last_run_at" json:"last_run_at"`
+	Status    string     `gorm:"column:status;type:enum('active','paused','cancelled','completed');default:active;index:idx_status_next" json:"status"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"column:updated_at" json:"updated_at"`
+	User      *User      `gorm:"foreignKey:UserID;references:ID" json:"-"`
+}
+
+func (schedule *ScheduledTransfer) BeforeCreate(*gorm.DB) error {
+	schedule.ID = uuid.New()
+	return nil
+}
+
+// Finished reports whether no run may come after next
+func (schedule *ScheduledTransfer) Finished(next time.Time) bool {
+	if next.IsZero() {
+		return true
+	}
+	if schedule.EndAt != nil && next.After(*schedule.EndAt) {
+		return true
+	}
+	return schedule.MaxRuns > 0 && schedule.RunCount >= schedule.MaxRuns
+}
+
+type ScheduledTransferRun struct {
+	ID         uint64    `gorm:"column:id;primaryKey" json:"id"`
+	ScheduleID uuid.UUID `gorm:"column:schedule_id;type:char(36);index" json:"schedule_id"`
+	DueAt      time.Time `gorm:"column:due_at" json:"due_at"`
+	Status     string    `gorm:"column:status;type:enum('sent','skipped','failed','held','rejected','expired')" json:"status"`
+	TxHash     string    `gorm:"column:tx_hash;type:varchar(100)" json:"tx_hash"`
+	// AssessmentID is the risk assessment of a held run
+	AssessmentID *uint64   `gorm:"column:assessment_id" json:"assessment_id"`
+	Error        string    `gorm:"column:error;type:varchar(500)" json:"error"`
+	CreatedAt    time.Time `gorm:"column:created_at" json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ScreeningWithdrawal = "withdrawal"
+	ScreeningPayout     = "payout"
+	ScreeningDeposit    = "deposit"
+)
+
+// ScreeningHit records an address found on a denylist and what was stopped
+// because of it. Reference is the deposit hash or the payout batch.
+type ScreeningHit struct {
+	ID        uint64     `gorm:"column:id;primaryKey" json:"id"`
+	UserID    *uuid.UUID `gorm:"column:user_id;type:char(36);index" json:"user_id"`
+	Direction string     `gorm:"column:direction;type:enum('withdrawal','payout','deposit');index" json:"direction"`
+	Network   string     `gorm:"column:network;type:enum('ERC20','TRC20')" json:"network"`
+	Currency  string     `gorm:"column:currency;type:varchar(10)" json:"currency"`
+	Address   string     `gorm:"column:address;type:varchar(255);index" json:"address"`
+	Amount    float64    `gorm:"column:amount" json:"amount"`
+	List      string     `gorm:"column:list;type:varchar(255)" json:"list"`
+	Reason    string     `gorm:"column:reason;type:varchar(255)" json:"reason"`
+	Reference string     `gorm:"column:reference;type:varchar(100)" json:"reference"`
+	CreatedAt time.Time  `gorm:"column:created_at;index" json:"created_at"`
+}
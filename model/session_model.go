@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Session is one login of a user or admin, its ID is the family of the
+// refresh tokens rotated from it and the sid of its access tokens
+type Session struct {
+	ID        string     `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	Username  string     `gorm:"column:username;type:varchar(100);index:idx_session_owner" json:"username"`
+	IsAdmin   bool       `gorm:"column:is_admin;index:idx_session_owner" json:"is_admin"`
+	Device    string     `gorm:"column:device;type:varchar(64)" json:"device"`
+	UserAgent string     `gorm:"column:user_agent;type:varchar(255)" json:"user_agent"`
+	IP        string     `gorm:"column:ip;type:varchar(50)" json:"ip"`
+	Area      string     `gorm:"column:area;type:varchar(255)" json:"area"`
+	ExpiresAt time.Time  `gorm:"column:expires_at" json:"expires_at"`
+	LastSeen  time.Time  `gorm:"column:last_seen" json:"last_seen"`
+	RevokedAt *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+	// Current is set on the session of the request when listing
+	Current bool `gorm:"-" json:"current"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BalanceSnapshot is the balance and value of one asset of a user at TakenAt,
+// every snapshot run shares the same TakenAt for all assets.
+type BalanceSnapshot struct {
+	ID            uint64    `gorm:"column:id;primaryKey" json:"id"`
+	UserID        uuid.UUID `gorm:"column:user_id;type:char(36);index:idx_user_taken" json:"user_id"`
+	WalletID      uuid.UUID `gorm:"column:wallet_id;type:char(36)" json:"wallet_id"`
+	Network       string    `gorm:"column:network;type:enum('ERC20','TRC20');default:ERC20" json:"network"`
+	Currency      string    `gorm:"column:currency;type:enum('ETH','TRX','USDT');default:USDT" json:"currency"`
+	Balance       float64   `gorm:"column:balance" json:"balance"`
+	QuoteCurrency string    `gorm:"column:quote_currency;type:varchar(10)" json:"quote_currency"`
+	Price         float64   `gorm:"column:price" json:"price"`
+	Value         float64   `gorm:"column:value" json:"value"`
+	TakenAt       time.Time `gorm:"column:taken_at;index:idx_user_taken" json:"taken_at"`
+	CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`
+}
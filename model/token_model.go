@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// RefreshToken is an opaque token traded for a new access token. Only its
+// hash is stored. Every refresh rotates it, the tokens of one login share a
+// FamilyID so reuse of a rotated token revokes them all.
+type RefreshToken struct {
+	ID        uint64     `gorm:"column:id;primaryKey" json:"id"`
+	FamilyID  string     `gorm:"column:family_id;type:char(36);index" json:"family_id"`
+	TokenHash string     `gorm:"column:token_hash;type:char(64);unique" json:"-"`
+	Username  string     `gorm:"column:username;type:varchar(100)" json:"username"`
+	IsAdmin   bool       `gorm:"column:is_admin" json:"is_admin"`
+	ExpiresAt time.Time  `gorm:"column:expires_at" json:"expires_at"`
+	RotatedAt *time.Time `gorm:"column:rotated_at" json:"rotated_at"`
+	RevokedAt *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TxTypeTransfer   = "transfer"
+	TxTypeWithdrawal = "withdrawal"
+	TxTypeInternal   = "internal"
+	TxTypeSpeedUp    = "speed_up"
+	TxTypeCancel     = "cancel"
+)
+
+type Transaction struct {
+	ID           uint64     `gorm:"column:id;primaryKey" json:"id"`
+	BankID       *uint64    `gorm:"column:bank_id;index" json:"bank_id"`
+	UserID       *uuid.UUID `gorm:"column:user_id;type:char(36);index" json:"user_id"`
+	TxHash       string     `gorm:"column:tx_hash;type:varchar(100);unique;not null" json:"tx_hash"`
+	Type         string     `gorm:"column:type;type:varchar(20);default:transfer;not null" json:"type"`
+	Network      string     `gorm:"column:network;type:enum('ERC20','TRC20');default:ERC20" json:"network"`
+	Currency     string     `gorm:"column:currency;type:enum('ETH','TRX','USDT');default:USDT" json:"currency"`
+	FromAddress  string     `gorm:"column:from_address;type:varchar(255);index" json:"from_address"`
+	ToAddress    string     `gorm:"column:to_address;type:varchar(255)" json:"to_address"`
+	Amount       float64    `gorm:"column:amount" json:"amount"`
+	Nonce        uint64     `gorm:"column:nonce" json:"nonce"`
+	GasLimit     uint64     `gorm:"column:gas_limit" json:"gas_limit"`
+	GasPrice     string     `gorm:"column:gas_price;type:varchar(100)" json:"gas_price"`
+	GasTipCap    string     `gorm:"column:gas_tip_cap;type:varchar(100)" json:"gas_tip_cap"`
+	State        int        `gorm:"column:state;default:2;not null" json:"state"`
+	ReplacesID   *uint64    `gorm:"column:replaces_id" json:"replaces_id"`
+	ReplacedByID *uint64    `gorm:"column:replaced_by_id" json:"replaced_by_id"`
+	CreatedAt    time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
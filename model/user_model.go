@@ -8,22 +8,54 @@ import (
 )
 
 type User struct {
-	ID         uuid.UUID      `gorm:"column:id;type:char(36);primaryKey" json:"id"`
-	Name       string         `gorm:"column:name;type:varchar(100);not null" json:"name"`
-	Username   string         `gorm:"column:username;type:varchar(100);unique;not null" json:"username"`
-	Email      string         `gorm:"column:email;type:varchar(100);unique;not null" json:"email"`
-	Password   string         `gorm:"column:password;type:varchar(255)" json:"-"`
-	IP         string         `gorm:"column:ip;type:varchar(20)" json:"ip"`
-	Location   string         `gorm:"column:location;type:varchar(255)" json:"location"`
-	OTPEnabled bool           `gorm:"column:otp_enabled;default:false;not null" json:"otp_enabled"`
-	OTPSecret  string         `gorm:"column:otp_secret" json:"otp_secret"`
-	OTPAuthURL string         `gorm:"column:otp_auth_url;default:false;not null" json:"otp_auth_url"`
-	CreatedAt  time.Time      `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt  time.Time      `gorm:"column:updated_at" json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `json:"-"`
+	ID               uuid.UUID  `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	Name             string     `gorm:"column:name;type:varchar(100);not null" json:"name"`
+	Username         string     `gorm:"column:username;type:varchar(100);unique;not null" json:"username"`
+	Email            string     `gorm:"column:email;type:varchar(100);unique;not null" json:"email"`
+	EmailVerifiedAt  *time.Time `gorm:"column:email_verified_at" json:"email_verified_at"`
+	Password         string     `gorm:"column:password;type:varchar(255)" json:"-"`
+	IP               string     `gorm:"column:ip;type:varchar(20)" json:"ip"`
+	Location         string     `gorm:"column:location;type:varchar(255)" json:"location"`
+	Tier             string     `gorm:"column:tier;type:varchar(20);default:unverified;not null" json:"tier"`
+	KYCLevel         int        `gorm:"column:kyc_level;default:0;not null" json:"kyc_level"`
+	OTPEnabled       bool       `gorm:"column:otp_enabled;default:false;not null" json:"otp_enabled"`
+	OTPSecret        string     `gorm:"column:otp_secret" json:"-"`
+	OTPAuthURL       string     `gorm:"column:otp_auth_url;default:false;not null" json:"-"`
+	OTPPendingSecret string     `gorm:"column:otp_pending_secret" json:"-"`
+	// withdrawals only go to whitelisted addresses, turning it off waits for
+	// the cool-down so it stays on until WhitelistOffAt
+	WhitelistEnabled  bool           `gorm:"column:whitelist_enabled;default:false;not null" json:"whitelist_enabled"`
+	WhitelistOffAt    *time.Time     `gorm:"column:whitelist_off_at" json:"whitelist_off_at"`
+	PasswordChangedAt *time.Time     `gorm:"column:password_changed_at" json:"password_changed_at"`
+	OTPChangedAt      *time.Time     `gorm:"column:otp_changed_at" json:"otp_changed_at"`
+	CreatedAt         time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt         time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"-"`
 }
 
 func (user *User) BeforeCreate(*gorm.DB) error {
 	user.ID = uuid.New()
 	return nil
 }
+
+// WhitelistActive reports whether withdrawals are limited to the whitelist
+func (user *User) WhitelistActive(now time.Time) bool {
+	return user.WhitelistEnabled && (user.WhitelistOffAt == nil || now.Before(*user.WhitelistOffAt))
+}
+
+// 2fa enrollment states, a setup is pending until its first code confirms it
+const (
+	TwoFactorDisabled = "disabled"
+	TwoFactorPending  = "pending"
+	TwoFactorEnabled  = "enabled"
+)
+
+func (user *User) TwoFactorState() string {
+	if user.OTPEnabled {
+		return TwoFactorEnabled
+	}
+	if user.OTPPendingSecret != "" {
+		return TwoFactorPending
+	}
+	return TwoFactorDisabled
+}
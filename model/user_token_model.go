@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// purposes of emailed tokens
+const (
+	UserTokenVerifyEmail   = "verify_email"
+	UserTokenResetPassword = "reset_password"
+)
+
+// UserToken is a token emailed to a user. Only its hash is kept and it
+// works once, issuing a new one retires the older ones of that purpose.
+type UserToken struct {
+	ID        uint       `gorm:"column:id;primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"column:user_id;type:char(36);index:idx_user_token_owner" json:"user_id"`
+	Purpose   string     `gorm:"column:purpose;type:varchar(20);index:idx_user_token_owner" json:"purpose"`
+	TokenHash string     `gorm:"column:token_hash;type:char(64);unique" json:"-"`
+	ExpiresAt time.Time  `gorm:"column:expires_at" json:"expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+}
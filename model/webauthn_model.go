@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// WebAuthnCredential is a passkey of an admin. SignCount only grows, a
+// lower one means the key was cloned.
+type WebAuthnCredential struct {
+	ID              uint       `gorm:"column:id;primaryKey" json:"id"`
+	AdminID         uint64     `gorm:"column:admin_id;index" json:"admin_id"`
+	Name            string     `gorm:"column:name;type:varchar(100)" json:"name"`
+	CredentialID    string     `gorm:"column:credential_id;type:varchar(255);unique" json:"credential_id"`
+	PublicKey       []byte     `gorm:"column:public_key;type:blob" json:"-"`
+	AttestationType string     `gorm:"column:attestation_type;type:varchar(50)" json:"attestation_type"`
+	Transports      []string   `gorm:"column:transports;serializer:json" json:"transports"`
+	AAGUID          []byte     `gorm:"column:aaguid;type:varbinary(16)" json:"-"`
+	SignCount       uint32     `gorm:"column:sign_count" json:"sign_count"`
+	LastUsedAt      *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	CreatedAt       time.Time  `gorm:"column:created_at" json:"created_at"`
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	EventDepositDetected  = "deposit.detected"
+	EventDepositConfirmed = "deposit.confirmed"
+	EventWithdrawalSent   = "withdrawal.sent"
+	EventWithdrawalFailed = "withdrawal.failed"
+	EventInvoicePaid      = "invoice.paid"
+	// a scheduled transfer run skipped for insufficient funds
+	EventScheduleSkipped = "scheduled_transfer.skipped"
+	// a scheduled transfer run held for manual risk review
+	EventScheduleHeld = "scheduled_transfer.held"
+)
+
+var WebhookEvents = []string{
+	EventDepositDetected,
+	EventDepositConfirmed,
+	EventWithdrawalSent,
+	EventWithdrawalFailed,
+	EventInvoicePaid,
+	EventScheduleSkipped,
+	EventScheduleHeld,
+}
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusDead      = "dead"
+)
+
+// WebhookEndpoint receives the events it subscribed to. Like api keys the
+// secret signs payloads, so it is stored AES encrypted.
+type WebhookEndpoint struct {
+	ID        uuid.UUID      `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID      `gorm:"column:user_id;type:char(36);index" json:"user_id"`
+	URL       string         `gorm:"column:url;type:varchar(500);not null" json:"url"`
+	Secret    string         `gorm:"column:secret;type:varchar(255);not null" json:"-"`
+	Events    string         `gorm:"column:events;type:varchar(255)" json:"events"`
+	Active    bool           `gorm:"column:active;default:true" json:"active"`
+	CreatedAt time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-"`
+}
+
+func (endpoint *WebhookEndpoint) BeforeCreate(*gorm.DB) error {
+	endpoint.ID = uuid.New()
+	return nil
+}
+
+func (endpoint *WebhookEndpoint) Subscribes(event string) bool {
+	for _, e := range strings.Split(endpoint.Events, ",") {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event for one endpoint. It stays pending until the
+// endpoint answers 2xx and is dead after the last failed attempt.
+type WebhookDelivery struct {
+	ID            uint64           `gorm:"column:id;primaryKey" json:"id"`
+	EndpointID    uuid.UUID        `gorm:"column:endpoint_id;type:char(36);index" json:"endpoint_id"`
+	EventID       uuid.UUID        `gorm:"column:event_id;type:char(36);index" json:"event_id"`
+	Event         string           `gorm:"column:event;type:varchar(50)" json:"event"`
+	Payload       string           `gorm:"column:payload;type:text" json:"payload"`
+	Status        string           `gorm:"column:status;type:enum('pending','delivered','dead');default:pending;index:idx_status_next" json:"status"`
+	Attempts      int              `gorm:"column:attempts;default:0" json:"attempts"`
+	NextAttemptAt time.Time        `gorm:"column:next_attempt_at;index:idx_status_next" json:"next_attempt_at"`
+	ResponseCode  int              `gorm:"column:response_code" json:"response_code"`
+	LastError     string           `gorm:"column:last_error;type:varchar(500)" json:"last_error"`
+	DeliveredAt   *time.Time       `gorm:"column:delivered_at" json:"delivered_at"`
+	CreatedAt     time.Time        `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     time.Time        `gorm:"column:updated_at" json:"updated_at"`
+	Endpoint      *WebhookEndpoint `gorm:"foreignKey:EndpointID;references:ID" json:"-"`
+}
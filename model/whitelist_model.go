@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WhitelistAddress is a withdrawal destination of the user's address book,
+// usable once ActiveAt has passed
+type WhitelistAddress struct {
+	ID        uuid.UUID      `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID      `gorm:"column:user_id;type:char(36);index:idx_user_address" json:"user_id"`
+	Network   string         `gorm:"column:network;type:enum('ERC20','TRC20');index:idx_user_address" json:"network"`
+	Address   string         `gorm:"column:address;type:varchar(255);index:idx_user_address" json:"address"`
+	Label     string         `gorm:"column:label;type:varchar(100)" json:"label"`
+	ActiveAt  time.Time      `gorm:"column:active_at" json:"active_at"`
+	CreatedAt time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-"`
+}
+
+func (address *WhitelistAddress) BeforeCreate(*gorm.DB) error {
+	address.ID = uuid.New()
+	return nil
+}
@@ -0,0 +1,310 @@
+// Package payment sends money out of user wallets. The front api and the
+// worker both go through it, so every withdrawal gets the same checks.
+package payment
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrQuoteMismatch     = errors.New("fee quote does not match the withdrawal")
+	ErrSelfTransfer      = errors.New("can't transfer to yourself")
+	ErrNotWhitelisted    = errors.New("address is not whitelisted or still in its cool-down")
+	ErrKYCRequired       = errors.New("identity verification is required to withdraw")
+	ErrScreenedAddress   = errors.New("address is on a sanctions or denylist")
+	ErrRiskBlocked       = errors.New("withdrawal was blocked by risk checks")
+)
+
+// TransferError is a withdrawal the chain service failed to send
+type TransferError struct {
+	Err error
+}
+
+func (e *TransferError) Error() string {
+	return fmt.Sprintf("transfer failed: %v", e.Err)
+}
+
+func (e *TransferError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	LimitPerTransaction = "limit_per_transaction"
+	LimitDaily          = "limit_daily"
+	LimitMonthly        = "limit_monthly"
+)
+
+// LimitError is a withdrawal over one of the user's caps, Code tells which
+type LimitError struct {
+	Code     string  `json:"code"`
+	Currency string  `json:"currency"`
+	Limit    float64 `json:"limit"`
+	Used     float64 `json:"used"`
+}
+
+func (e *LimitError) Error() string {
+	switch e.Code {
+	case LimitDaily:
+		return fmt.Sprintf("daily limit of %v %s reached, %v used", e.Limit, e.Currency, e.Used)
+	case LimitMonthly:
+		return fmt.Sprintf("monthly limit of %v %s reached, %v used", e.Limit, e.Currency, e.Used)
+	}
+	return fmt.Sprintf("amount is over the limit of %v %s per transaction", e.Limit, e.Currency)
+}
+
+type Payments struct {
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func New(repo *repository.Repository, svc *service.Service) *Payments {
+	return &Payments{
+		repo: repo,
+		svc:  svc,
+	}
+}
+
+// Withdrawal is a send from a user's wallet, IP and Area are empty when it
+// is not started by a request
+type Withdrawal struct {
+	User *model.User
+	Req  *dto.WithdrawReq
+	// Type is recorded on the transaction, model.TxTypeWithdrawal or model.TxTypeInternal
+	Type string
+	IP   string
+	Area string
+	// Device identifies the client, see utils.DeviceID
+	Device string
+	// Review is the approved assessment of a withdrawal that was held, it
+	// is not scored again
+	Review *model.RiskAssessment
+}
+
+// Withdraw sends the withdrawal from the user's wallet on its network and
+// records it. The transfer is broadcast once it returns without error.
+func (p *Payments) Withdraw(ctx context.Context, w *Withdrawal) (*model.Transaction, error) {
+	req := w.Req
+	now := time.Now()
+	if !service.IsNetworkCurrency(req.Network, req.Currency) {
+		return nil, service.ErrUnsupportedCurrency
+	}
+	if w.User.KYCLevel < conf.KYCWithdrawLevel {
+		return nil, ErrKYCRequired
+	}
+	if entry := p.svc.Screen.Check(req.ToAddress); entry != nil {
+		hit := &model.ScreeningHit{
+			UserID:    &w.User.ID,
+			Direction: model.ScreeningWithdrawal,
+			Network:   req.Network,
+			Currency:  req.Currency,
+			Address:   req.ToAddress,
+			Amount:    req.Amount,
+			List:      entry.List,
+			Reason:    entry.Reason,
+		}
+		if err := p.repo.Screening.RecordHit(ctx, hit); err != nil {
+			log.Println(err, "Error recording screening hit")
+		}
+		return nil, ErrScreenedAddress
+	}
+
+	if w.User.WhitelistActive(now) {
+		address := utils.NormalizeAddress(req.Network, req.ToAddress)
+		whitelisted, err := p.repo.Whitelist.IsActive(ctx, w.User.ID, req.Network, address, now)
+		if err != nil {
+			return nil, err
+		}
+		if !whitelisted {
+			return nil, ErrNotWhitelisted
+		}
+	}
+
+	wallet, err := p.repo.Wallet.FindByUserAndNetwork(ctx, w.User.ID, req.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	balances, err := p.svc.Balances(req.Network, wallet.Address)
+	if err != nil {
+		return nil, &TransferError{Err: err}
+	}
+	if balances[req.Currency] < req.Amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	if w.Review == nil {
+		assessment, err := p.assess(ctx, w, now)
+		if err != nil {
+			return nil, err
+		}
+		switch assessment.Decision {
+		case model.RiskBlock:
+			return nil, ErrRiskBlocked
+		case model.RiskReview:
+			return nil, &ReviewError{Assessment: assessment}
+		}
+	}
+
+	if err := p.reserveLimit(ctx, w.User, req.Currency, req.Amount, now); err != nil {
+		return nil, err
+	}
+	// the limit is given back unless the transfer goes out
+	sent := false
+	defer func() {
+		if sent {
+			return
+		}
+		if err := p.repo.Limit.Release(ctx, w.User.ID, req.Currency, req.Amount, now); err != nil {
+			log.Println(err, "Error releasing withdrawal limit")
+		}
+	}()
+
+	transferReq := &dto.TransferReq{
+		Amount:      req.Amount,
+		FromAddress: wallet.Address,
+		ToAddress:   req.ToAddress,
+		PrivateKey:  wallet.Privatekey,
+		IP:          w.IP,
+		Area:        w.Area,
+	}
+
+	if req.QuoteID != "" {
+		quote, err := p.repo.FeeQuote.Take(ctx, w.User.ID.String(), req.QuoteID)
+		if err != nil {
+			return nil, err
+		}
+		// the quote can be used again unless the transfer goes out
+		defer func() {
+			if sent {
+				return
+			}
+			if err := p.repo.FeeQuote.Restore(ctx, w.User.ID.String(), quote); err != nil {
+				log.Println(err, "Error restoring fee quote")
+			}
+		}()
+		if quote.Network != req.Network || quote.Currency != req.Currency ||
+			quote.Amount != req.Amount || quote.To != req.ToAddress {
+			return nil, ErrQuoteMismatch
+		}
+		tier := req.FeeTier
+		if tier == "" {
+			tier = service.FeeTierNormal
+		}
+		transferReq.GasPrice = quote.Tiers[tier].GasPrice
+	}
+
+	txHash, err := p.svc.Transfer(req.Network, req.Currency, transferReq)
+	if err != nil {
+		p.notify(ctx, w.User, model.EventWithdrawalFailed, map[string]any{
+			"network":    req.Network,
+			"currency":   req.Currency,
+			"to_address": req.ToAddress,
+			"amount":     req.Amount,
+			"error":      err.Error(),
+		})
+		if errors.Is(err, service.ErrStalePrice) {
+			return nil, err
+		}
+		return nil, &TransferError{Err: err}
+	}
+	sent = true
+
+	tx := &model.Transaction{
+		UserID:      &w.User.ID,
+		TxHash:      txHash,
+		Type:        w.Type,
+		Network:     req.Network,
+		Currency:    req.Currency,
+		FromAddress: wallet.Address,
+		ToAddress:   req.ToAddress,
+		Amount:      req.Amount,
+		GasPrice:    transferReq.GasPrice,
+		State:       model.StateTransfer,
+	}
+	// the transfer is already broadcast, so the hash is returned either way
+	if err := p.repo.Tx.Create(ctx, tx); err != nil {
+		log.Println(err, "Error saving withdrawal")
+	}
+	if w.Device != "" {
+		if err := p.repo.Risk.SeenDevice(ctx, w.User.ID, w.Device, w.IP, now); err != nil {
+			log.Println(err, "Error saving device")
+		}
+	}
+	p.notify(ctx, w.User, model.EventWithdrawalSent, tx)
+	return tx, nil
+}
+
+// reserveLimit checks amount against the user's caps for currency and counts
+// it towards the daily and monthly ones
+func (p *Payments) reserveLimit(ctx context.Context, user *model.User, currency string, amount float64, now time.Time) error {
+	limit, err := p.repo.Limit.Effective(ctx, user, currency, now)
+	if err != nil {
+		return err
+	}
+	if limit.PerTx != nil && amount > *limit.PerTx {
+		return &LimitError{Code: LimitPerTransaction, Currency: currency, Limit: *limit.PerTx}
+	}
+
+	exceeded, err := p.repo.Limit.Reserve(ctx, user.ID, currency, amount, limit.Daily, limit.Monthly, now)
+	if err != nil {
+		return err
+	}
+	switch exceeded {
+	case repository.LimitDaily:
+		return &LimitError{Code: LimitDaily, Currency: currency, Limit: *limit.Daily, Used: limit.DailyUsed}
+	case repository.LimitMonthly:
+		return &LimitError{Code: LimitMonthly, Currency: currency, Limit: *limit.Monthly, Used: limit.MonthlyUsed}
+	}
+	return nil
+}
+
+// InternalTransfer sends to the wallet of another user on the same network
+func (p *Payments) InternalTransfer(ctx context.Context, user *model.User, req *dto.InternalTransferReq, ip, area, device string) (*model.Transaction, error) {
+	recipient, err := p.repo.User.FindByField("username", req.Username)
+	if err != nil {
+		return nil, err
+	}
+	if recipient.ID == user.ID {
+		return nil, ErrSelfTransfer
+	}
+
+	wallet, err := p.repo.Wallet.FindByUserAndNetwork(ctx, recipient.ID, req.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Withdraw(ctx, &Withdrawal{
+		User: user,
+		Req: &dto.WithdrawReq{
+			Network:   req.Network,
+			Currency:  req.Currency,
+			Amount:    req.Amount,
+			ToAddress: wallet.Address,
+			QuoteID:   req.QuoteID,
+			FeeTier:   req.FeeTier,
+		},
+		Type:   model.TxTypeInternal,
+		IP:     ip,
+		Area:   area,
+		Device: device,
+	})
+}
+
+// notify queues a webhook event for the user, a failure only costs the
+// notification so it is logged
+func (p *Payments) notify(ctx context.Context, user *model.User, event string, data any) {
+	if err := p.repo.Webhook.Enqueue(ctx, user.ID, event, data); err != nil {
+		log.Println(err, "Error queueing webhook ", event)
+	}
+}
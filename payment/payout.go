@@ -0,0 +1,141 @@
+package payment
+
+import (
+	"context"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrScreenedPayout = errors.New("address is on a sanctions or denylist")
+	// a row that was being sent when its batch stopped may or may not be on
+	// chain, it is failed rather than sent twice
+	errInterruptedPayout = errors.New("interrupted while sending, check the chain before paying again")
+)
+
+// ExecutePayout sends the pending rows of an executing batch one by one, the
+// nonce manager keeps the transactions of the bank in order. A batch left
+// executing is resumed by calling it again.
+func (p *Payments) ExecutePayout(ctx context.Context, batch *model.PayoutBatch, bank *model.Bank, privateKey string) {
+	batch.SentCount, batch.FailedCount = 0, 0
+	for _, row := range batch.Rows {
+		if row.Status == model.PayoutRowSending {
+			row.Status = model.PayoutRowFailed
+			row.Error = errInterruptedPayout.Error()
+			if err := p.repo.Payout.UpdateRow(ctx, row); err != nil {
+				log.Println(err, "Error saving payout row ", row.ID)
+			}
+		}
+		switch row.Status {
+		case model.PayoutRowSent:
+			batch.SentCount++
+		case model.PayoutRowFailed:
+			batch.FailedCount++
+		}
+	}
+
+	for _, row := range batch.Rows {
+		if row.Status != model.PayoutRowPending {
+			continue
+		}
+		p.sendPayoutRow(ctx, batch, bank, privateKey, row)
+		if err := p.repo.Payout.Touch(ctx, batch); err != nil {
+			log.Println(err, "Error saving payout batch ", batch.ID)
+		}
+	}
+
+	if err := p.repo.Payout.Complete(ctx, batch); err != nil {
+		log.Println(err, "Error completing payout batch ", batch.ID)
+	}
+	log.Printf("payout batch %d done: %d sent, %d failed\n", batch.ID, batch.SentCount, batch.FailedCount)
+}
+
+// sendPayoutRow marks the row sending before it is broadcast, so a row is
+// never sent again after a crash
+func (p *Payments) sendPayoutRow(ctx context.Context, batch *model.PayoutBatch, bank *model.Bank, privateKey string, row *model.PayoutRow) {
+	// lists may have been refreshed since the preview
+	if p.ScreenPayout(ctx, row, fmt.Sprintf("batch %d", batch.ID)) {
+		row.Status = model.PayoutRowFailed
+		row.Error = ErrScreenedPayout.Error()
+		batch.FailedCount++
+		if err := p.repo.Payout.UpdateRow(ctx, row); err != nil {
+			log.Println(err, "Error saving payout row ", row.ID)
+		}
+		return
+	}
+
+	row.Status = model.PayoutRowSending
+	if err := p.repo.Payout.UpdateRow(ctx, row); err != nil {
+		// without the mark a resumed batch could send it again
+		log.Println(err, "Error saving payout row ", row.ID)
+		row.Status = model.PayoutRowPending
+		return
+	}
+
+	transferReq := &dto.TransferReq{
+		Amount:      row.Amount,
+		FromAddress: *bank.WalletAddress,
+		ToAddress:   row.Address,
+		PrivateKey:  privateKey,
+	}
+	txHash, err := p.svc.Payout(row.Network, row.Token, transferReq)
+	if err != nil {
+		row.Status = model.PayoutRowFailed
+		row.Error = err.Error()
+		if len(row.Error) > 500 {
+			row.Error = row.Error[:500]
+		}
+		batch.FailedCount++
+	} else {
+		now := time.Now()
+		row.Status = model.PayoutRowSent
+		row.TxHash = txHash
+		row.SentAt = &now
+		batch.SentCount++
+
+		tx := &model.Transaction{
+			BankID:      bank.ID,
+			TxHash:      txHash,
+			Type:        model.TxTypeTransfer,
+			Network:     row.Network,
+			Currency:    row.Token,
+			FromAddress: *bank.WalletAddress,
+			ToAddress:   row.Address,
+			Amount:      row.Amount,
+			State:       model.StateTransfer,
+		}
+		if err := p.repo.Tx.Create(ctx, tx); err != nil {
+			log.Println(err, "Error saving payout transaction")
+		}
+	}
+
+	if err := p.repo.Payout.UpdateRow(ctx, row); err != nil {
+		log.Println(err, "Error saving payout row ", row.ID)
+	}
+}
+
+// ScreenPayout reports whether the row pays a listed address and records the hit
+func (p *Payments) ScreenPayout(ctx context.Context, row *model.PayoutRow, reference string) bool {
+	entry := p.svc.Screen.Check(row.Address)
+	if entry == nil {
+		return false
+	}
+	hit := &model.ScreeningHit{
+		Direction: model.ScreeningPayout,
+		Network:   row.Network,
+		Currency:  row.Token,
+		Address:   row.Address,
+		Amount:    row.Amount,
+		List:      entry.List,
+		Reason:    entry.Reason,
+		Reference: reference,
+	}
+	if err := p.repo.Screening.RecordHit(ctx, hit); err != nil {
+		log.Println(err, "Error recording screening hit")
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package payment
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/model"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// riskWeights is what each signal adds to the score of a withdrawal
+var riskWeights = map[string]int{
+	model.RiskSignalCountry:     30,
+	model.RiskSignalDevice:      25,
+	model.RiskSignalPassword:    25,
+	model.RiskSignalOTP:         25,
+	model.RiskSignalAmount:      30,
+	model.RiskSignalDestination: 20,
+}
+
+// ReviewError is a withdrawal held for manual review, it is sent once an
+// admin approves the assessment
+type ReviewError struct {
+	Assessment *model.RiskAssessment
+}
+
+func (e *ReviewError) Error() string {
+	return fmt.Sprintf("withdrawal is waiting for manual review (%s)", e.Assessment.Signals)
+}
+
+// assess scores the withdrawal and saves the assessment. Signals that need
+// data the withdrawal lacks, like the IP of scheduled transfers, are skipped.
+func (p *Payments) assess(ctx context.Context, w *Withdrawal, now time.Time) (*model.RiskAssessment, error) {
+	req := w.Req
+	signals := make([]string, 0)
+
+	if w.Area != "" && w.User.Location != "" && areaCountry(w.Area) != areaCountry(w.User.Location) {
+		signals = append(signals, model.RiskSignalCountry)
+	}
+
+	if w.Device != "" {
+		firstSeen, err := p.repo.Risk.DeviceFirstSeen(ctx, w.User.ID, w.Device)
+		if err != nil {
+			return nil, err
+		}
+		if firstSeen == nil || now.Sub(*firstSeen) < conf.RiskNewDeviceAge {
+			signals = append(signals, model.RiskSignalDevice)
+		}
+	}
+
+	if changed := w.User.PasswordChangedAt; changed != nil && now.Sub(*changed) < conf.RiskRecentChange {
+		signals = append(signals, model.RiskSignalPassword)
+	}
+	if changed := w.User.OTPChangedAt; changed != nil && now.Sub(*changed) < conf.RiskRecentChange {
+		signals = append(signals, model.RiskSignalOTP)
+	}
+
+	count, average, err := p.repo.Risk.WithdrawalHistory(ctx, w.User.ID, req.Currency, now.Add(-conf.RiskHistoryPeriod))
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 && req.Amount > average*conf.RiskAmountFactor {
+		signals = append(signals, model.RiskSignalAmount)
+	}
+
+	sent, err := p.repo.Risk.HasSentTo(ctx, w.User.ID, req.Network, req.ToAddress)
+	if err != nil {
+		return nil, err
+	}
+	if !sent {
+		signals = append(signals, model.RiskSignalDestination)
+	}
+
+	score := 0
+	for _, signal := range signals {
+		score += riskWeights[signal]
+	}
+
+	assessment := &model.RiskAssessment{
+		UserID:    w.User.ID,
+		Type:      w.Type,
+		Network:   req.Network,
+		Currency:  req.Currency,
+		ToAddress: req.ToAddress,
+		Amount:    req.Amount,
+		IP:        w.IP,
+		Area:      w.Area,
+		Device:    w.Device,
+		Score:     score,
+		Signals:   strings.Join(signals, ","),
+		Decision:  model.RiskApprove,
+	}
+	switch {
+	case score >= conf.RiskBlockScore:
+		assessment.Decision = model.RiskBlock
+	case score >= conf.RiskReviewScore:
+		assessment.Decision = model.RiskReview
+		assessment.ReviewStatus = model.RiskReviewPending
+	}
+
+	if err := p.repo.Risk.Create(ctx, assessment); err != nil {
+		return nil, err
+	}
+	return assessment, nil
+}
+
+// areaCountry is the country of a "Country, City" area from utils.GetArea
+func areaCountry(area string) string {
+	country, _, _ := strings.Cut(area, ",")
+	return strings.TrimSpace(country)
+}
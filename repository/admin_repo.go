@@ -59,8 +59,14 @@ func (r *adminRepository) Update(ctx context.Context, admin *model.Admin) error
 	return r.DB.WithContext(ctx).Debug().Where("id", admin.ID).UpdateColumns(admin).Error
 }
 
+// DeleteMany fails when it would delete the last super-admin
 func (r *adminRepository) DeleteMany(ctx context.Context, ids string) error {
-	return r.DB.WithContext(ctx).Debug().Delete(&model.Admin{}, fmt.Sprintf("id in (%s)", ids)).Error
+	return r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&model.Admin{}, fmt.Sprintf("id in (%s)", ids)).Error; err != nil {
+			return err
+		}
+		return ensureSuperAdmin(tx)
+	})
 }
 
 func (r *adminRepository) RecoverAdmins(ctx context.Context, ids string) error {
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// the chain has a single head row
+const auditHeadID = 1
+
+// AuditBreak is where verification found the chain tampered with
+type AuditBreak struct {
+	Seq    uint64 `json:"seq"`
+	Reason string `json:"reason"`
+}
+
+type auditRepository struct {
+	DB *gorm.DB
+
+	// headReady is set once the head row exists, a failed attempt is
+	// retried by the next Record
+	headMu    sync.Mutex
+	headReady bool
+}
+
+func newAuditRepository(ds *ds.DataSource) *auditRepository {
+	return &auditRepository{
+		DB: ds.DB,
+	}
+}
+
+// Record appends entry to the chain. The head row is locked for the whole
+// insert, so entries of concurrent requests get consecutive seqs each
+// pointing at the hash of the one before.
+func (r *auditRepository) Record(ctx context.Context, entry *model.AuditLog) error {
+	if err := r.ensureHead(ctx); err != nil {
+		return err
+	}
+
+	return r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		head := model.AuditHead{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&head, "id = ?", auditHeadID).Error
+		if err != nil {
+			return err
+		}
+
+		entry.Seq = head.Seq + 1
+		entry.PrevHash = head.Hash
+		// the column keeps milliseconds, the hash must match what is read back
+		entry.CreatedAt = time.Now().Truncate(time.Millisecond)
+		entry.Hash = entry.ComputeHash()
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		return tx.Model(&head).Updates(map[string]any{"seq": entry.Seq, "hash": entry.Hash}).Error
+	})
+}
+
+// ensureHead creates the head row on first use. It is created outside the
+// appending transaction, an insert there would take a shared lock and
+// deadlock with the other writers.
+func (r *auditRepository) ensureHead(ctx context.Context) error {
+	r.headMu.Lock()
+	defer r.headMu.Unlock()
+	if r.headReady {
+		return nil
+	}
+	err := r.DB.WithContext(ctx).Debug().Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.AuditHead{ID: auditHeadID}).Error
+	if err != nil {
+		return err
+	}
+	r.headReady = true
+	return nil
+}
+
+func (r *auditRepository) List(ctx context.Context, req *dto.AuditListReq) ([]*model.AuditLog, int64, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.AuditLog{})
+	if req.ActorType != "" {
+		tb.Where("actor_type = ?", req.ActorType)
+	}
+	if req.ActorID != "" {
+		tb.Where("actor_id = ?", req.ActorID)
+	}
+	if req.Actor != "" {
+		tb.Where("actor = ?", req.Actor)
+	}
+	if req.Action != "" {
+		tb.Where("action LIKE ?", "%"+req.Action+"%")
+	}
+	if req.Target != "" {
+		tb.Where("target LIKE ?", req.Target+"%")
+	}
+	if req.StartAt != nil {
+		tb.Where("created_at >= ?", req.StartAt)
+	}
+	if req.EndAt != nil {
+		tb.Where("created_at < ?", req.EndAt)
+	}
+	var total int64
+	tb.Count(&total)
+	tb.Scopes(utils.Paginate(req.Page, req.PageSize))
+	list := make([]*model.AuditLog, 0)
+	return list, total, tb.Order("seq DESC").Find(&list).Error
+}
+
+// Verify walks the chain from the start and returns the first entry whose
+// hash, link or seq is off, nil when the chain is intact. It also checks the
+// head, so entries cut from the end are noticed as well.
+func (r *auditRepository) Verify(ctx context.Context, batch int) (uint64, *AuditBreak, error) {
+	var checked uint64
+	prevHash := ""
+	entries := make([]*model.AuditLog, 0, batch)
+	err := r.DB.WithContext(ctx).Order("seq").FindInBatches(&entries, batch, func(tx *gorm.DB, _ int) error {
+		for _, entry := range entries {
+			checked++
+			switch {
+			case entry.Seq != checked:
+				return &auditBreakError{AuditBreak{checked, fmt.Sprintf("expected seq %d, found %d", checked, entry.Seq)}}
+			case entry.PrevHash != prevHash:
+				return &auditBreakError{AuditBreak{entry.Seq, "does not link to the previous entry"}}
+			case entry.ComputeHash() != entry.Hash:
+				return &auditBreakError{AuditBreak{entry.Seq, "content does not match its hash"}}
+			}
+			prevHash = entry.Hash
+		}
+		return nil
+	}).Error
+	var breakErr *auditBreakError
+	if errors.As(err, &breakErr) {
+		return checked, &breakErr.AuditBreak, nil
+	}
+	if err != nil {
+		return checked, nil, err
+	}
+
+	head := model.AuditHead{}
+	err = r.DB.WithContext(ctx).First(&head, "id = ?", auditHeadID).Error
+	if utils.IsErrNotFound(err) {
+		if checked == 0 {
+			return 0, nil, nil
+		}
+		return checked, &AuditBreak{checked, "head is missing"}, nil
+	}
+	if err != nil {
+		return checked, nil, err
+	}
+	if head.Seq != checked {
+		return checked, &AuditBreak{head.Seq, fmt.Sprintf("head is at seq %d but the chain ends at %d", head.Seq, checked)}, nil
+	}
+	if head.Hash != prevHash {
+		return checked, &AuditBreak{head.Seq, "head does not match the last entry"}, nil
+	}
+	return checked, nil, nil
+}
+
+// auditBreakError stops FindInBatches at the first broken entry
+type auditBreakError struct {
+	AuditBreak
+}
+
+func (e *auditBreakError) Error() string {
+	return fmt.Sprintf("audit chain broken at seq %d: %s", e.Seq, e.Reason)
+}
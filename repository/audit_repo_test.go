@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/model"
+	"fmt"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newAuditTestRepository(t *testing.T) *auditRepository {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.AuditLog{}, &model.AuditHead{}); err != nil {
+		t.Fatal(err)
+	}
+	return &auditRepository{DB: db}
+}
+
+// every entry links to the one before, a changed, removed or cut entry is
+// found at its seq
+func TestAuditChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper string
+		seq    uint64
+	}{
+		{"intact", "", 0},
+		{"changed", "UPDATE audit_logs SET actor = 'mallory' WHERE seq = 2", 2},
+		{"removed", "DELETE FROM audit_logs WHERE seq = 2", 2},
+		{"cut from the end", "DELETE FROM audit_logs WHERE seq = 3", 3},
+		{"relinked", "UPDATE audit_logs SET prev_hash = '' WHERE seq = 3", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := newAuditTestRepository(t)
+			for i := 0; i < 3; i++ {
+				entry := &model.AuditLog{ActorType: model.AuditActorAdmin, Actor: "alice", Action: fmt.Sprint("POST /api/", i), Status: 200}
+				if err := r.Record(ctx, entry); err != nil {
+					t.Fatal(err)
+				}
+				if entry.Seq != uint64(i+1) {
+					t.Fatalf("entry %d recorded at seq %d", i, entry.Seq)
+				}
+			}
+			if tt.tamper != "" {
+				if err := r.DB.Exec(tt.tamper).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// a batch of 2 also walks across batches
+			checked, broken, err := r.Verify(ctx, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.seq == 0 {
+				if broken != nil || checked != 3 {
+					t.Fatalf("intact chain reported %+v after %d entries", broken, checked)
+				}
+				return
+			}
+			if broken == nil {
+				t.Fatal("tampering not found")
+			}
+			if broken.Seq != tt.seq {
+				t.Errorf("broken at seq %d, want %d: %s", broken.Seq, tt.seq, broken.Reason)
+			}
+		})
+	}
+}
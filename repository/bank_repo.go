@@ -77,3 +77,10 @@ func (r *bankRepository) GetAddressScanRecord(addressType string, address string
 	}
 	return fmt.Sprintf("https://etherscan.io/address/%s", address)
 }
+
+func (r *bankRepository) FindByAddress(address string) (*model.Bank, error) {
+	bank := model.Bank{}
+	db := r.DB.Model(&model.Bank{})
+	err := db.First(&bank, "wallet_address = ?", address).Error
+	return &bank, err
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type depositRepository struct {
+	DB  *gorm.DB
+	RDB *redis.Client
+}
+
+func newDepositRepository(ds *ds.DataSource) *depositRepository {
+	return &depositRepository{
+		DB:  ds.DB,
+		RDB: ds.RDB,
+	}
+}
+
+// Create stores a deposit once, it reports false when it was already seen
+func (r *depositRepository) Create(ctx context.Context, deposit *model.Deposit) (bool, error) {
+	db := r.DB.WithContext(ctx).Debug().Clauses(clause.OnConflict{DoNothing: true}).Create(deposit)
+	return db.RowsAffected > 0, db.Error
+}
+
+func (r *depositRepository) ListByStatus(ctx context.Context, network, status string) ([]*model.Deposit, error) {
+	deposits := make([]*model.Deposit, 0)
+	err := r.DB.WithContext(ctx).Debug().Where("network = ? AND status = ?", network, status).Find(&deposits).Error
+	return deposits, err
+}
+
+func (r *depositRepository) FindByID(ctx context.Context, id uint64) (*model.Deposit, error) {
+	deposit := model.Deposit{}
+	err := r.DB.WithContext(ctx).Debug().First(&deposit, "id = ?", id).Error
+	return &deposit, err
+}
+
+// ListHeld is the review queue of deposits stopped by screening
+func (r *depositRepository) ListHeld(ctx context.Context, req *dto.PageReq) ([]*model.Deposit, int64, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.Deposit{}).Where("status = ?", model.DepositStatusHeld)
+	var total int64
+	tb.Count(&total)
+	tb.Scopes(utils.Paginate(req.Page, req.PageSize))
+	list := make([]*model.Deposit, 0)
+	return list, total, tb.Order("id").Find(&list).Error
+}
+
+// Review moves a held deposit to status, detected lets the scanner confirm
+// and credit it. It reports false when the deposit is no longer held.
+func (r *depositRepository) Review(ctx context.Context, deposit *model.Deposit, status string, adminID uint64) (bool, error) {
+	db := r.DB.WithContext(ctx).Debug().Model(&model.Deposit{}).
+		Where("id = ? AND status = ?", deposit.ID, model.DepositStatusHeld).
+		Updates(map[string]any{
+			"status":      status,
+			"reviewed_by": adminID,
+		})
+	if db.Error != nil || db.RowsAffected == 0 {
+		return false, db.Error
+	}
+	deposit.Status = status
+	deposit.ReviewedBy = &adminID
+	return true, nil
+}
+
+// Hold moves a detected deposit to held, Drop to dropped. They report false
+// when the deposit is no longer detected.
+func (r *depositRepository) Hold(ctx context.Context, deposit *model.Deposit) (bool, error) {
+	return r.leaveDetected(ctx, deposit, model.DepositStatusHeld)
+}
+
+func (r *depositRepository) Drop(ctx context.Context, deposit *model.Deposit) (bool, error) {
+	return r.leaveDetected(ctx, deposit, model.DepositStatusDropped)
+}
+
+func (r *depositRepository) leaveDetected(ctx context.Context, deposit *model.Deposit, status string) (bool, error) {
+	db := r.DB.WithContext(ctx).Debug().Model(&model.Deposit{}).
+		Where("id = ? AND status = ?", deposit.ID, model.DepositStatusDetected).
+		Update("status", status)
+	if db.Error != nil || db.RowsAffected == 0 {
+		return false, db.Error
+	}
+	deposit.Status = status
+	return true, nil
+}
+
+// SetBlock moves a deposit reorged into another block, its confirmations
+// are counted from there
+func (r *depositRepository) SetBlock(ctx context.Context, deposit *model.Deposit, block uint64) error {
+	err := r.DB.WithContext(ctx).Debug().Model(&model.Deposit{}).
+		Where("id = ?", deposit.ID).
+		Update("block_number", block).Error
+	if err == nil {
+		deposit.BlockNumber = block
+	}
+	return err
+}
+
+// balanceColumns are the wallet columns credited for each currency
+var balanceColumns = map[string]string{
+	"USDT": "usdt_balance",
+	"ETH":  "eth_balance",
+	"TRX":  "trx_balance",
+}
+
+// Confirm credits a detected deposit to its wallet and applies it to the
+// oldest invoice on the same address that was payable when the transfer was
+// mined. The invoice is nil when none matched. It reports false without
+// crediting when the deposit is no longer detected.
+func (r *depositRepository) Confirm(ctx context.Context, deposit *model.Deposit, at time.Time) (*model.Invoice, bool, error) {
+	var matched *model.Invoice
+	confirmed := false
+	err := r.DB.WithContext(ctx).Debug().Transaction(func(db *gorm.DB) error {
+		res := db.Model(&model.Deposit{}).
+			Where("id = ? AND status = ?", deposit.ID, model.DepositStatusDetected).
+			Updates(map[string]any{
+				"status":       model.DepositStatusConfirmed,
+				"confirmed_at": at,
+			})
+		if res.Error != nil || res.RowsAffected != 1 {
+			return res.Error
+		}
+
+		// deposits seen before the block time was kept fall back to when
+		// they were seen
+		minedAt := deposit.CreatedAt
+		if deposit.BlockTime != nil {
+			minedAt = *deposit.BlockTime
+		}
+		invoice := model.Invoice{}
+		err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("network = ? AND currency = ? AND address = ?", deposit.Network, deposit.Currency, deposit.ToAddress).
+			Where("status IN ? AND created_at <= ? AND expires_at > ?", []string{model.InvoiceStatusOpen, model.InvoiceStatusUnderpaid}, minedAt, minedAt).
+			Order("created_at").
+			First(&invoice).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			invoice.Pay(deposit.Amount, deposit.TxHash, at)
+			if err := db.Save(&invoice).Error; err != nil {
+				return err
+			}
+			err := db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Update("invoice_id", invoice.ID).Error
+			if err != nil {
+				return err
+			}
+			matched = &invoice
+		}
+
+		column := balanceColumns[deposit.Currency]
+		err = db.Model(&model.Wallet{}).Where("id = ?", deposit.WalletID).
+			Update(column, gorm.Expr(column+" + ?", deposit.Amount)).Error
+		if err != nil {
+			return err
+		}
+		confirmed = true
+		return nil
+	})
+	if err != nil || !confirmed {
+		return nil, false, err
+	}
+
+	deposit.Status = model.DepositStatusConfirmed
+	deposit.ConfirmedAt = &at
+	if matched != nil {
+		deposit.InvoiceID = &matched.ID
+	}
+	return matched, true, nil
+}
+
+func scanCursorKey(name string) string {
+	return fmt.Sprintf("deposit_scan:%s", name)
+}
+
+// Cursor is the last block the scanner named name has processed
+func (r *depositRepository) Cursor(ctx context.Context, name string) (uint64, bool, error) {
+	value, err := r.RDB.Get(ctx, scanCursorKey(name)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	block, err := strconv.ParseUint(value, 10, 64)
+	return block, err == nil, err
+}
+
+func (r *depositRepository) SetCursor(ctx context.Context, name string, block uint64) error {
+	return r.RDB.Set(ctx, scanCursorKey(name), block, 0).Err()
+}
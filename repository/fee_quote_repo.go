@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+var ErrQuoteNotFound = errors.New("fee quote is expired or not found")
+
+type feeQuoteRepository struct {
+	RDB *redis.Client
+}
+
+func newFeeQuoteRepository(ds *ds.DataSource) *feeQuoteRepository {
+	return &feeQuoteRepository{
+		RDB: ds.RDB,
+	}
+}
+
+type lockedQuote struct {
+	UserID string            `json:"user_id"`
+	Quote  *dto.FeeQuoteResp `json:"quote"`
+}
+
+func feeQuoteKey(quoteID string) string {
+	return fmt.Sprintf("fee_quote:%s", quoteID)
+}
+
+// Lock keeps the quote for ttl so a withdrawal can reference it by id
+func (r *feeQuoteRepository) Lock(ctx context.Context, userID string, quote *dto.FeeQuoteResp, ttl time.Duration) error {
+	data, err := json.Marshal(&lockedQuote{UserID: userID, Quote: quote})
+	if err != nil {
+		return err
+	}
+	return r.RDB.Set(ctx, feeQuoteKey(quote.QuoteID), data, ttl).Err()
+}
+
+// Take returns the quote and removes it, so a quote is used once
+func (r *feeQuoteRepository) Take(ctx context.Context, userID, quoteID string) (*dto.FeeQuoteResp, error) {
+	data, err := r.RDB.GetDel(ctx, feeQuoteKey(quoteID)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrQuoteNotFound
+		}
+		return nil, err
+	}
+
+	locked := lockedQuote{}
+	if err := json.Unmarshal(data, &locked); err != nil {
+		return nil, err
+	}
+	if locked.UserID != userID {
+		return nil, ErrQuoteNotFound
+	}
+	return locked.Quote, nil
+}
+
+// Restore puts back a quote taken for a withdrawal that was not sent, for
+// what is left of its ttl
+func (r *feeQuoteRepository) Restore(ctx context.Context, userID string, quote *dto.FeeQuoteResp) error {
+	ttl := time.Until(quote.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return r.Lock(ctx, userID, quote, ttl)
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/dto"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v9"
+)
+
+// a quote is taken once, and can be taken again after it is restored
+func TestFeeQuoteRestore(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	r := &feeQuoteRepository{RDB: rdb}
+
+	quote := &dto.FeeQuoteResp{QuoteID: "q1", ExpiresAt: time.Now().Add(time.Minute)}
+	if err := r.Lock(ctx, "user", quote, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Take(ctx, "user", "q1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Take(ctx, "user", "q1"); !errors.Is(err, ErrQuoteNotFound) {
+		t.Fatalf("quote taken twice: %v", err)
+	}
+
+	if err := r.Restore(ctx, "user", quote); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL(feeQuoteKey("q1")); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("restored quote expires in %s", ttl)
+	}
+	if _, err := r.Take(ctx, "user", "q1"); err != nil {
+		t.Fatalf("restored quote not taken: %v", err)
+	}
+
+	// an expired quote stays gone
+	quote.ExpiresAt = time.Now().Add(-time.Second)
+	if err := r.Restore(ctx, "user", quote); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Take(ctx, "user", "q1"); !errors.Is(err, ErrQuoteNotFound) {
+		t.Fatalf("expired quote restored: %v", err)
+	}
+}
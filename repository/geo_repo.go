@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/ds"
+	"cryptoshare/model"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type geoRepository struct {
+	DB *gorm.DB
+
+	// the allowlist is checked on every request, so it is kept in memory
+	// and read again after conf.GeoAllowlistRefresh
+	mu       sync.RWMutex
+	networks []*net.IPNet
+	loadedAt time.Time
+}
+
+func newGeoRepository(ds *ds.DataSource) *geoRepository {
+	return &geoRepository{
+		DB: ds.DB,
+	}
+}
+
+func (r *geoRepository) ListAllowed(ctx context.Context) ([]*model.GeoAllowIP, error) {
+	list := make([]*model.GeoAllowIP, 0)
+	err := r.DB.WithContext(ctx).Debug().Order("id").Find(&list).Error
+	return list, err
+}
+
+func (r *geoRepository) Allow(ctx context.Context, entry *model.GeoAllowIP) error {
+	if err := r.DB.WithContext(ctx).Debug().Create(entry).Error; err != nil {
+		return err
+	}
+	r.expire()
+	return nil
+}
+
+func (r *geoRepository) Disallow(ctx context.Context, id uint64) error {
+	db := r.DB.WithContext(ctx).Debug().Delete(&model.GeoAllowIP{}, "id = ?", id)
+	if db.Error == nil && db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	r.expire()
+	return db.Error
+}
+
+// IsAllowed reports whether ip is on the allowlist
+func (r *geoRepository) IsAllowed(ctx context.Context, ip string) (bool, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false, nil
+	}
+
+	r.mu.RLock()
+	stale := time.Since(r.loadedAt) >= conf.GeoAllowlistRefresh
+	r.mu.RUnlock()
+	if stale {
+		if err := r.load(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, network := range r.networks {
+		if network.Contains(addr) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *geoRepository) load(ctx context.Context) error {
+	list, err := r.ListAllowed(ctx)
+	if err != nil {
+		return err
+	}
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, entry := range list {
+		if network := parseCIDR(entry.CIDR); network != nil {
+			networks = append(networks, network)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.networks = networks
+	r.loadedAt = time.Now()
+	return nil
+}
+
+func (r *geoRepository) expire() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadedAt = time.Time{}
+}
+
+// parseCIDR reads a range, a single address is a range of one
+func parseCIDR(value string) *net.IPNet {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil
+	}
+	return network
+}
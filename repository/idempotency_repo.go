@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/model"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type idempotencyRepository struct {
+	DB  *gorm.DB
+	RDB *redis.Client
+}
+
+func newIdempotencyRepository(ds *ds.DataSource) *idempotencyRepository {
+	return &idempotencyRepository{
+		DB:  ds.DB,
+		RDB: ds.RDB,
+	}
+}
+
+// Find returns the unexpired record of key in scope
+func (r *idempotencyRepository) Find(ctx context.Context, scope, key string) (*model.IdempotencyKey, error) {
+	record := model.IdempotencyKey{}
+	err := r.DB.WithContext(ctx).Debug().First(&record, "scope = ? AND `key` = ? AND expires_at > ?", scope, key, time.Now()).Error
+	return &record, err
+}
+
+// Save stores the record, replacing an expired one with the same key
+func (r *idempotencyRepository) Save(ctx context.Context, record *model.IdempotencyKey) error {
+	return r.DB.WithContext(ctx).Debug().Transaction(func(db *gorm.DB) error {
+		err := db.Where("scope = ? AND `key` = ? AND expires_at <= ?", record.Scope, record.Key, time.Now()).
+			Delete(&model.IdempotencyKey{}).Error
+		if err != nil {
+			return err
+		}
+		return db.Create(record).Error
+	})
+}
+
+// extendLockScript and releaseLockScript only touch the lock while it still
+// holds the token of the caller, so a lock that expired and was taken by
+// another request is left alone.
+// KEYS: lock. ARGV: token, ttl in milliseconds.
+var extendLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Lock is held while the first request with a key runs, it returns an empty
+// token when another request with the key is in progress
+func (r *idempotencyRepository) Lock(ctx context.Context, scope, key string, ttl time.Duration) (string, error) {
+	token := uuid.NewString()
+	locked, err := r.RDB.SetNX(ctx, idempotencyLockKey(scope, key), token, ttl).Result()
+	if err != nil || !locked {
+		return "", err
+	}
+	return token, nil
+}
+
+// Extend keeps the lock for another ttl, it reports false once the lock is
+// no longer held with token
+func (r *idempotencyRepository) Extend(ctx context.Context, scope, key, token string, ttl time.Duration) (bool, error) {
+	n, err := extendLockScript.Run(ctx, r.RDB, []string{idempotencyLockKey(scope, key)}, token, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (r *idempotencyRepository) Unlock(ctx context.Context, scope, key, token string) error {
+	return releaseLockScript.Run(ctx, r.RDB, []string{idempotencyLockKey(scope, key)}, token).Err()
+}
+
+func idempotencyLockKey(scope, key string) string {
+	return fmt.Sprintf("idempotency:%s:%s", scope, key)
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v9"
+)
+
+func TestIdempotencyLock(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	r := &idempotencyRepository{RDB: rdb}
+
+	token, err := r.Lock(ctx, "user:1", "key", time.Minute)
+	if err != nil || token == "" {
+		t.Fatalf("lock not taken: %v", err)
+	}
+	if other, _ := r.Lock(ctx, "user:1", "key", time.Minute); other != "" {
+		t.Fatal("a held lock was taken again")
+	}
+
+	// extending keeps the lock past its first ttl
+	mr.FastForward(50 * time.Second)
+	if held, err := r.Extend(ctx, "user:1", "key", token, time.Minute); err != nil || !held {
+		t.Fatalf("lock not extended: %v", err)
+	}
+	mr.FastForward(50 * time.Second)
+	if other, _ := r.Lock(ctx, "user:1", "key", time.Minute); other != "" {
+		t.Fatal("an extended lock expired")
+	}
+
+	// another token can neither extend nor release it
+	if held, _ := r.Extend(ctx, "user:1", "key", "other", time.Minute); held {
+		t.Fatal("lock extended with another token")
+	}
+	if err := r.Unlock(ctx, "user:1", "key", "other"); err != nil || !mr.Exists(idempotencyLockKey("user:1", "key")) {
+		t.Fatal("lock released with another token")
+	}
+	if err := r.Unlock(ctx, "user:1", "key", token); err != nil || mr.Exists(idempotencyLockKey("user:1", "key")) {
+		t.Fatal("lock not released")
+	}
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type invoiceRepository struct {
+	DB *gorm.DB
+}
+
+func newInvoiceRepository(ds *ds.DataSource) *invoiceRepository {
+	return &invoiceRepository{
+		DB: ds.DB,
+	}
+}
+
+func (r *invoiceRepository) Create(ctx context.Context, invoice *model.Invoice) error {
+	return r.DB.WithContext(ctx).Debug().Create(invoice).Error
+}
+
+func (r *invoiceRepository) FindByID(ctx context.Context, id string) (*model.Invoice, error) {
+	invoice := model.Invoice{}
+	err := r.DB.WithContext(ctx).Debug().First(&invoice, "id = ?", id).Error
+	return &invoice, err
+}
+
+func (r *invoiceRepository) ListByUser(ctx context.Context, userID uuid.UUID, req *dto.PageReq) ([]*model.Invoice, int64, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.Invoice{}).Where("user_id = ?", userID)
+	var total int64
+	tb.Count(&total)
+	tb.Scopes(utils.Paginate(req.Page, req.PageSize))
+	invoices := make([]*model.Invoice, 0)
+	return invoices, total, tb.Order("created_at DESC").Find(&invoices).Error
+}
+
+// ExpireOpen closes invoices that were not paid in time
+func (r *invoiceRepository) ExpireOpen(ctx context.Context, now time.Time) (int64, error) {
+	db := r.DB.WithContext(ctx).Debug().Model(&model.Invoice{}).
+		Where("status = ? AND expires_at <= ?", model.InvoiceStatusOpen, now).
+		Update("status", model.InvoiceStatusExpired)
+	return db.RowsAffected, db.Error
+}
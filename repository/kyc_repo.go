@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type kycRepository struct {
+	DB *gorm.DB
+}
+
+func newKYCRepository(ds *ds.DataSource) *kycRepository {
+	return &kycRepository{
+		DB: ds.DB,
+	}
+}
+
+// Create saves the submission with its documents
+func (r *kycRepository) Create(ctx context.Context, submission *model.KYCSubmission) error {
+	return r.DB.WithContext(ctx).Debug().Create(submission).Error
+}
+
+// Latest returns the last submission of the user
+func (r *kycRepository) Latest(ctx context.Context, userID uuid.UUID) (*model.KYCSubmission, error) {
+	submission := model.KYCSubmission{}
+	err := r.DB.WithContext(ctx).Debug().Preload("Documents").
+		Where("user_id = ?", userID).Order("id DESC").First(&submission).Error
+	return &submission, err
+}
+
+// HasPending reports whether the user has a submission waiting for review
+func (r *kycRepository) HasPending(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Debug().Model(&model.KYCSubmission{}).
+		Where("user_id = ? AND status = ?", userID, model.KYCPending).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *kycRepository) FindByID(ctx context.Context, id uint64) (*model.KYCSubmission, error) {
+	submission := model.KYCSubmission{}
+	err := r.DB.WithContext(ctx).Debug().Preload("Documents").Preload("User").
+		First(&submission, "id = ?", id).Error
+	return &submission, err
+}
+
+// List is the review queue, oldest first so submissions are reviewed in order
+func (r *kycRepository) List(ctx context.Context, req *dto.KYCListReq) ([]*model.KYCSubmission, int64, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.KYCSubmission{})
+	if req.Status != "" {
+		tb.Where("status = ?", req.Status)
+	}
+	var total int64
+	tb.Count(&total)
+	tb.Scopes(utils.Paginate(req.Page, req.PageSize))
+	list := make([]*model.KYCSubmission, 0)
+	return list, total, tb.Preload("User").Order("id").Find(&list).Error
+}
+
+// Approve accepts a pending submission and raises the user to its level and
+// tier. It reports false when the submission was already reviewed.
+func (r *kycRepository) Approve(ctx context.Context, submission *model.KYCSubmission, adminID uint64) (bool, error) {
+	approved := false
+	err := r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		ok, err := review(tx, submission, model.KYCApproved, adminID, "")
+		if err != nil || !ok {
+			return err
+		}
+
+		fields := map[string]any{"kyc_level": gorm.Expr("GREATEST(kyc_level, ?)", submission.Level)}
+		// an admin may have raised the tier by hand, approval never lowers it
+		if tier := model.KYCTier(submission.Level); tier != model.TierUnverified {
+			fields["tier"] = tier
+		}
+		if err := tx.Model(&model.User{}).Where("id = ?", submission.UserID).Updates(fields).Error; err != nil {
+			return err
+		}
+		approved = true
+		return nil
+	})
+	return approved, err
+}
+
+// Reject turns down a pending submission with reason, it reports false when
+// the submission was already reviewed
+func (r *kycRepository) Reject(ctx context.Context, submission *model.KYCSubmission, adminID uint64, reason string) (bool, error) {
+	return review(r.DB.WithContext(ctx).Debug(), submission, model.KYCRejected, adminID, reason)
+}
+
+func review(db *gorm.DB, submission *model.KYCSubmission, status string, adminID uint64, reason string) (bool, error) {
+	now := time.Now()
+	result := db.Model(&model.KYCSubmission{}).
+		Where("id = ? AND status = ?", submission.ID, model.KYCPending).
+		Updates(map[string]any{
+			"status":      status,
+			"reason":      reason,
+			"reviewed_by": adminID,
+			"reviewed_at": now,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	submission.Status = status
+	submission.Reason = reason
+	submission.ReviewedBy = &adminID
+	submission.ReviewedAt = &now
+	return true, nil
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	LimitOK      = 0
+	LimitDaily   = 1
+	LimitMonthly = 2
+)
+
+// reserveLimitScript adds the amount to the daily and monthly counters only
+// if neither goes over its cap, a negative cap is unlimited.
+// KEYS: daily, monthly. ARGV: amount, daily cap, monthly cap, daily ttl, monthly ttl.
+var reserveLimitScript = redis.NewScript(`
+local amount = tonumber(ARGV[1])
+local daily = tonumber(redis.call('GET', KEYS[1]) or '0')
+local monthly = tonumber(redis.call('GET', KEYS[2]) or '0')
+local dailyCap = tonumber(ARGV[2])
+local monthlyCap = tonumber(ARGV[3])
+if dailyCap >= 0 and daily + amount > dailyCap + 1e-9 then
+	return 1
+end
+if monthlyCap >= 0 and monthly + amount > monthlyCap + 1e-9 then
+	return 2
+end
+redis.call('INCRBYFLOAT', KEYS[1], ARGV[1])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+redis.call('INCRBYFLOAT', KEYS[2], ARGV[1])
+redis.call('EXPIRE', KEYS[2], ARGV[5])
+return 0
+`)
+
+type limitRepository struct {
+	DB  *gorm.DB
+	RDB *redis.Client
+}
+
+func newLimitRepository(ds *ds.DataSource) *limitRepository {
+	return &limitRepository{
+		DB:  ds.DB,
+		RDB: ds.RDB,
+	}
+}
+
+func (r *limitRepository) ListTierLimits(ctx context.Context) ([]*model.TierLimit, error) {
+	limits := make([]*model.TierLimit, 0)
+	err := r.DB.WithContext(ctx).Debug().Order("tier, currency").Find(&limits).Error
+	return limits, err
+}
+
+// SaveTierLimit creates or replaces the caps of a tier and currency
+func (r *limitRepository) SaveTierLimit(ctx context.Context, limit *model.TierLimit) error {
+	return r.DB.WithContext(ctx).Debug().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tier"}, {Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"per_tx", "daily", "monthly", "updated_at"}),
+	}).Create(limit).Error
+}
+
+func (r *limitRepository) ListUserLimits(ctx context.Context, userID uuid.UUID) ([]*model.UserLimit, error) {
+	limits := make([]*model.UserLimit, 0)
+	err := r.DB.WithContext(ctx).Debug().Where("user_id = ?", userID).Order("currency").Find(&limits).Error
+	return limits, err
+}
+
+// SaveUserLimit creates or replaces the override of a user and currency
+func (r *limitRepository) SaveUserLimit(ctx context.Context, limit *model.UserLimit) error {
+	return r.DB.WithContext(ctx).Debug().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"per_tx", "daily", "monthly", "updated_by", "updated_at"}),
+	}).Create(limit).Error
+}
+
+func (r *limitRepository) DeleteUserLimit(ctx context.Context, userID uuid.UUID, currency string) error {
+	db := r.DB.WithContext(ctx).Debug().Where("user_id = ? AND currency = ?", userID, currency).Delete(&model.UserLimit{})
+	if db.Error == nil && db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return db.Error
+}
+
+// Effective returns the caps of the user's tier for currency with the user's
+// override applied, and the usage of the current day and month
+func (r *limitRepository) Effective(ctx context.Context, user *model.User, currency string, now time.Time) (*dto.EffectiveLimit, error) {
+	limit := &dto.EffectiveLimit{Currency: currency}
+
+	tierLimit := model.TierLimit{}
+	err := r.DB.WithContext(ctx).Debug().First(&tierLimit, "tier = ? AND currency = ?", user.Tier, currency).Error
+	if err != nil && !utils.IsErrNotFound(err) {
+		return nil, err
+	}
+	limit.PerTx, limit.Daily, limit.Monthly = tierLimit.PerTx, tierLimit.Daily, tierLimit.Monthly
+
+	userLimit := model.UserLimit{}
+	err = r.DB.WithContext(ctx).Debug().First(&userLimit, "user_id = ? AND currency = ?", user.ID, currency).Error
+	if err != nil && !utils.IsErrNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		limit.Overridden = true
+		limit.PerTx = overrideCap(limit.PerTx, userLimit.PerTx)
+		limit.Daily = overrideCap(limit.Daily, userLimit.Daily)
+		limit.Monthly = overrideCap(limit.Monthly, userLimit.Monthly)
+	}
+
+	dailyKey, monthlyKey := limitKeys(user.ID, currency, now)
+	values, err := r.RDB.MGet(ctx, dailyKey, monthlyKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	limit.DailyUsed = parseRedisFloat(values[0])
+	limit.MonthlyUsed = parseRedisFloat(values[1])
+	return limit, nil
+}
+
+// overrideCap applies the cap of a user override to the tier's
+func overrideCap(tierCap, userCap *float64) *float64 {
+	switch {
+	case userCap == nil:
+		return tierCap
+	case *userCap == model.LimitUnlimited:
+		return nil
+	}
+	return userCap
+}
+
+// Reserve counts amount against the daily and monthly caps atomically, it
+// returns LimitDaily or LimitMonthly without counting when a cap would be
+// exceeded
+func (r *limitRepository) Reserve(ctx context.Context, userID uuid.UUID, currency string, amount float64, daily, monthly *float64, now time.Time) (int, error) {
+	dailyKey, monthlyKey := limitKeys(userID, currency, now)
+	return reserveLimitScript.Run(ctx, r.RDB, []string{dailyKey, monthlyKey},
+		amount, capArg(daily), capArg(monthly),
+		int((48 * time.Hour).Seconds()), int((32 * 24 * time.Hour).Seconds()),
+	).Int()
+}
+
+// Release gives back a reservation of a withdrawal that was not sent
+func (r *limitRepository) Release(ctx context.Context, userID uuid.UUID, currency string, amount float64, now time.Time) error {
+	dailyKey, monthlyKey := limitKeys(userID, currency, now)
+	pipe := r.RDB.TxPipeline()
+	pipe.IncrByFloat(ctx, dailyKey, -amount)
+	pipe.IncrByFloat(ctx, monthlyKey, -amount)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// limitKeys are the counters of the UTC day and month of now, both share a
+// hash tag so the script works on a cluster
+func limitKeys(userID uuid.UUID, currency string, now time.Time) (string, string) {
+	now = now.UTC()
+	tag := fmt.Sprintf("{%s:%s}", userID, currency)
+	return fmt.Sprintf("limit:%s:day:%s", tag, now.Format("20060102")),
+		fmt.Sprintf("limit:%s:month:%s", tag, now.Format("200601"))
+}
+
+func capArg(limit *float64) float64 {
+	if limit == nil {
+		return -1
+	}
+	return *limit
+}
+
+func parseRedisFloat(value any) float64 {
+	s, ok := value.(string)
+	if !ok {
+		return 0
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/ds"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// LoginStatus is the brute-force state of an account
+type LoginStatus struct {
+	Failures   int64 `json:"failures"`
+	Locked     bool  `json:"locked"`
+	RetryAfter int   `json:"retry_after"`
+}
+
+// lockoutRepository counts failed logins in redis, per account and per IP.
+// An account is the kind and id of an existing user or admin, or what was
+// typed in for unknown ones, so they are throttled alike.
+type lockoutRepository struct {
+	RDB *redis.Client
+}
+
+func newLockoutRepository(ds *ds.DataSource) *lockoutRepository {
+	return &lockoutRepository{
+		RDB: ds.RDB,
+	}
+}
+
+// LoginAccount is the key attempts on an account are counted under
+func LoginAccount(kind, id string) string {
+	return fmt.Sprintf("%s:%s", kind, strings.ToLower(strings.TrimSpace(id)))
+}
+
+// Locked returns how long the account or the IP stays locked, zero when
+// neither is
+func (r *lockoutRepository) Locked(ctx context.Context, account, ip string) (time.Duration, error) {
+	var accountTTL, ipTTL *redis.DurationCmd
+	_, err := r.RDB.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		accountTTL = pipe.TTL(ctx, loginLockKey(account))
+		ipTTL = pipe.TTL(ctx, loginIPLockKey(ip))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	// TTL is negative for keys that don't exist
+	wait := accountTTL.Val()
+	if ipTTL.Val() > wait {
+		wait = ipTTL.Val()
+	}
+	if wait < 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
+
+// Fail counts a failed login. delay is how long to hold the answer back,
+// locked is true only for the failure that locked the account, so the
+// owner is told once.
+func (r *lockoutRepository) Fail(ctx context.Context, account, ip string) (time.Duration, bool, error) {
+	accountFails, err := r.count(ctx, loginFailKey(account))
+	if err != nil {
+		return 0, false, err
+	}
+	ipFails, err := r.count(ctx, loginIPFailKey(ip))
+	if err != nil {
+		return 0, false, err
+	}
+
+	if ipFails >= int64(conf.LoginIPMaxAttempts) {
+		if err := r.lock(ctx, loginIPLockKey(ip), loginIPFailKey(ip)); err != nil {
+			return 0, false, err
+		}
+	}
+
+	delay := time.Duration(accountFails) * conf.LoginDelayStep
+	if accountFails < int64(conf.LoginMaxAttempts) {
+		return delay, false, nil
+	}
+	locked, err := r.RDB.SetNX(ctx, loginLockKey(account), 1, conf.LoginLockout).Result()
+	if err != nil {
+		return delay, false, err
+	}
+	// the lock is the penalty now, attempts after it start over
+	return delay, locked, r.RDB.Del(ctx, loginFailKey(account)).Err()
+}
+
+// Succeed clears the failures of the account after a good login
+func (r *lockoutRepository) Succeed(ctx context.Context, account string) error {
+	return r.RDB.Del(ctx, loginFailKey(account)).Err()
+}
+
+// Status shows the failures and lock of an account
+func (r *lockoutRepository) Status(ctx context.Context, account string) (*LoginStatus, error) {
+	var fails *redis.StringCmd
+	var ttl *redis.DurationCmd
+	_, err := r.RDB.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		fails = pipe.Get(ctx, loginFailKey(account))
+		ttl = pipe.TTL(ctx, loginLockKey(account))
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	status := &LoginStatus{}
+	status.Failures, _ = fails.Int64()
+	if ttl.Val() > 0 {
+		status.Locked = true
+		status.RetryAfter = int(ttl.Val().Seconds())
+	}
+	return status, nil
+}
+
+// Unlock lifts the lock of an account and clears its failures
+func (r *lockoutRepository) Unlock(ctx context.Context, account string) error {
+	return r.RDB.Del(ctx, loginLockKey(account), loginFailKey(account)).Err()
+}
+
+func (r *lockoutRepository) UnlockIP(ctx context.Context, ip string) error {
+	return r.RDB.Del(ctx, loginIPLockKey(ip), loginIPFailKey(ip)).Err()
+}
+
+// count adds a failure, the window starts with the first one
+func (r *lockoutRepository) count(ctx context.Context, key string) (int64, error) {
+	fails, err := r.RDB.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if fails == 1 {
+		err = r.RDB.Expire(ctx, key, conf.LoginAttemptWindow).Err()
+	}
+	return fails, err
+}
+
+func (r *lockoutRepository) lock(ctx context.Context, lockKey, failKey string) error {
+	if err := r.RDB.SetNX(ctx, lockKey, 1, conf.LoginLockout).Err(); err != nil {
+		return err
+	}
+	return r.RDB.Del(ctx, failKey).Err()
+}
+
+func loginFailKey(account string) string {
+	return fmt.Sprintf("login_fail:%s", account)
+}
+
+func loginLockKey(account string) string {
+	return fmt.Sprintf("login_lock:%s", account)
+}
+
+func loginIPFailKey(ip string) string {
+	return fmt.Sprintf("login_fail_ip:%s", ip)
+}
+
+func loginIPLockKey(ip string) string {
+	return fmt.Sprintf("login_lock_ip:%s", ip)
+}
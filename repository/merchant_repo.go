@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/model"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type merchantRepository struct {
+	DB  *gorm.DB
+	RDB *redis.Client
+}
+
+func newMerchantRepository(ds *ds.DataSource) *merchantRepository {
+	return &merchantRepository{
+		DB:  ds.DB,
+		RDB: ds.RDB,
+	}
+}
+
+func (r *merchantRepository) Create(ctx context.Context, merchant *model.Merchant) error {
+	return r.DB.WithContext(ctx).Debug().Create(merchant).Error
+}
+
+func (r *merchantRepository) FindByUser(ctx context.Context, userID uuid.UUID) (*model.Merchant, error) {
+	merchant := model.Merchant{}
+	err := r.DB.WithContext(ctx).Debug().First(&merchant, "user_id = ?", userID).Error
+	return &merchant, err
+}
+
+func (r *merchantRepository) CreateKey(ctx context.Context, key *model.APIKey) error {
+	return r.DB.WithContext(ctx).Debug().Create(key).Error
+}
+
+func (r *merchantRepository) ListKeys(ctx context.Context, merchantID uuid.UUID) ([]*model.APIKey, error) {
+	keys := make([]*model.APIKey, 0)
+	err := r.DB.WithContext(ctx).Debug().Where("merchant_id = ?", merchantID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// FindActiveKey returns the unrevoked key with its merchant and user
+func (r *merchantRepository) FindActiveKey(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	key := model.APIKey{}
+	err := r.DB.WithContext(ctx).Debug().
+		Preload("Merchant.User").
+		First(&key, "key_hash = ? AND revoked_at IS NULL", keyHash).Error
+	return &key, err
+}
+
+func (r *merchantRepository) RevokeKey(ctx context.Context, merchantID uuid.UUID, keyID string) error {
+	db := r.DB.WithContext(ctx).Debug().Model(&model.APIKey{}).
+		Where("id = ? AND merchant_id = ? AND revoked_at IS NULL", keyID, merchantID).
+		Update("revoked_at", time.Now())
+	if db.Error == nil && db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return db.Error
+}
+
+func (r *merchantRepository) TouchKey(ctx context.Context, keyID uuid.UUID) error {
+	return r.DB.WithContext(ctx).Debug().Model(&model.APIKey{}).Where("id = ?", keyID).Update("last_used_at", time.Now()).Error
+}
+
+// UseNonce reports false when the nonce was already used with this key
+// within ttl, which means the request is a replay
+func (r *merchantRepository) UseNonce(ctx context.Context, keyID uuid.UUID, nonce string, ttl time.Duration) (bool, error) {
+	return r.RDB.SetNX(ctx, fmt.Sprintf("merchant_nonce:%s:%s", keyID, nonce), 1, ttl).Result()
+}
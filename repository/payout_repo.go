@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type payoutRepository struct {
+	DB *gorm.DB
+}
+
+func newPayoutRepository(ds *ds.DataSource) *payoutRepository {
+	return &payoutRepository{
+		DB: ds.DB,
+	}
+}
+
+// Create saves the batch with its rows
+func (r *payoutRepository) Create(ctx context.Context, batch *model.PayoutBatch) error {
+	return r.DB.WithContext(ctx).Debug().Create(batch).Error
+}
+
+func (r *payoutRepository) FindByID(ctx context.Context, id uint64) (*model.PayoutBatch, error) {
+	batch := model.PayoutBatch{}
+	err := r.DB.WithContext(ctx).Debug().
+		Preload("Rows", func(db *gorm.DB) *gorm.DB { return db.Order("line") }).
+		First(&batch, "id = ?", id).Error
+	return &batch, err
+}
+
+func (r *payoutRepository) List(ctx context.Context, req *dto.PayoutBatchListReq) ([]*model.PayoutBatch, int64, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.PayoutBatch{})
+	if req.Status != "" {
+		tb.Where("status = ?", req.Status)
+	}
+	var total int64
+	tb.Count(&total)
+	tb.Scopes(utils.Paginate(req.Page, req.PageSize))
+	list := make([]*model.PayoutBatch, 0)
+	return list, total, tb.Order("id DESC").Find(&list).Error
+}
+
+// StartExecution moves a previewed batch to executing, it reports false when
+// the batch was already started so it never runs twice
+func (r *payoutRepository) StartExecution(ctx context.Context, batch *model.PayoutBatch, adminID uint64) (bool, error) {
+	now := time.Now()
+	db := r.DB.WithContext(ctx).Debug().Model(&model.PayoutBatch{}).
+		Where("id = ? AND status = ?", batch.ID, model.PayoutBatchPreviewed).
+		Updates(map[string]any{
+			"status":      model.PayoutBatchExecuting,
+			"executed_by": adminID,
+			"executed_at": now,
+		})
+	if db.Error != nil || db.RowsAffected == 0 {
+		return false, db.Error
+	}
+	batch.Status = model.PayoutBatchExecuting
+	batch.ExecutedBy = &adminID
+	batch.ExecutedAt = &now
+	return true, nil
+}
+
+func (r *payoutRepository) UpdateRow(ctx context.Context, row *model.PayoutRow) error {
+	return r.DB.WithContext(ctx).Debug().Model(row).Select("status", "tx_hash", "error", "sent_at").Updates(row).Error
+}
+
+// Touch marks the batch as still running, see ListStale
+func (r *payoutRepository) Touch(ctx context.Context, batch *model.PayoutBatch) error {
+	now := time.Now()
+	batch.UpdatedAt = now
+	return r.DB.WithContext(ctx).Debug().Model(&model.PayoutBatch{}).
+		Where("id = ?", batch.ID).UpdateColumn("updated_at", now).Error
+}
+
+// ListStale returns the executing batches not touched since before, their
+// execution stopped with the server that ran it
+func (r *payoutRepository) ListStale(ctx context.Context, before time.Time) ([]*model.PayoutBatch, error) {
+	list := make([]*model.PayoutBatch, 0)
+	err := r.DB.WithContext(ctx).Debug().
+		Preload("Rows", func(db *gorm.DB) *gorm.DB { return db.Order("line") }).
+		Where("status = ? AND updated_at < ?", model.PayoutBatchExecuting, before).
+		Find(&list).Error
+	return list, err
+}
+
+// Resume takes over a batch still stale at before, it reports false when
+// another worker took it or it moved on since it was listed
+func (r *payoutRepository) Resume(ctx context.Context, batch *model.PayoutBatch, before time.Time) (bool, error) {
+	now := time.Now()
+	db := r.DB.WithContext(ctx).Debug().Model(&model.PayoutBatch{}).
+		Where("id = ? AND status = ? AND updated_at < ?", batch.ID, model.PayoutBatchExecuting, before).
+		UpdateColumn("updated_at", now)
+	if db.Error != nil || db.RowsAffected == 0 {
+		return false, db.Error
+	}
+	batch.UpdatedAt = now
+	return true, nil
+}
+
+// Complete stores the final counts of the batch
+func (r *payoutRepository) Complete(ctx context.Context, batch *model.PayoutBatch) error {
+	now := time.Now()
+	batch.Status = model.PayoutBatchCompleted
+	batch.CompletedAt = &now
+	return r.DB.WithContext(ctx).Debug().Model(batch).
+		Select("status", "sent_count", "failed_count", "completed_at").Updates(batch).Error
+}
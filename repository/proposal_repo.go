@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrProposalNotPending = errors.New("proposal is no longer pending")
+	ErrProposalExpired    = errors.New("proposal has expired")
+	ErrProposalPending    = errors.New("an earlier proposal for the same target is still pending")
+	ErrOwnProposal        = errors.New("admins can not approve their own proposals")
+	ErrAlreadyApproved    = errors.New("proposal was already approved by this admin")
+)
+
+type proposalRepository struct {
+	DB *gorm.DB
+}
+
+func newProposalRepository(ds *ds.DataSource) *proposalRepository {
+	return &proposalRepository{
+		DB: ds.DB,
+	}
+}
+
+// Create stores a pending proposal. Only one may be pending per kind and
+// target, so the same bank or withdrawal isn't proposed twice.
+func (r *proposalRepository) Create(ctx context.Context, proposal *model.Proposal) error {
+	return r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		if proposal.Target != "" {
+			var count int64
+			err := tx.Model(&model.Proposal{}).
+				Where("kind = ? AND target = ? AND status = ? AND expires_at > ?",
+					proposal.Kind, proposal.Target, model.ProposalStatusPending, time.Now()).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrProposalPending
+			}
+		}
+
+		proposal.Status = model.ProposalStatusPending
+		if err := tx.Create(proposal).Error; err != nil {
+			return err
+		}
+		return addProposalEvent(tx, proposal.ID, &proposal.ProposedBy, model.ProposalEventProposed, "")
+	})
+}
+
+func (r *proposalRepository) FindByID(ctx context.Context, id uint64) (*model.Proposal, error) {
+	proposal := model.Proposal{}
+	err := r.DB.WithContext(ctx).Debug().
+		Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&proposal, "id = ?", id).Error
+	return &proposal, err
+}
+
+func (r *proposalRepository) List(ctx context.Context, req *dto.ProposalListReq) ([]*model.Proposal, int64, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.Proposal{})
+	if req.Status != "" {
+		tb.Where("status = ?", req.Status)
+	}
+	if req.Kind != "" {
+		tb.Where("kind = ?", req.Kind)
+	}
+	var total int64
+	tb.Count(&total)
+	tb.Scopes(utils.Paginate(req.Page, req.PageSize))
+	list := make([]*model.Proposal, 0)
+	return list, total, tb.Order("id DESC").Find(&list).Error
+}
+
+// Approve adds the approval of an admin. ready is true for the approval
+// that completes the proposal, it is then executing and the caller runs it
+// and reports back with Finish.
+func (r *proposalRepository) Approve(ctx context.Context, id, adminID uint64, note string) (*model.Proposal, bool, error) {
+	proposal := &model.Proposal{}
+	ready := false
+	expired := false
+	err := r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		var err error
+		proposal, expired, err = lockPendingProposal(tx, id)
+		if err != nil || expired {
+			return err
+		}
+		if proposal.ProposedBy == adminID {
+			return ErrOwnProposal
+		}
+
+		var count int64
+		err = tx.Model(&model.ProposalEvent{}).
+			Where("proposal_id = ? AND admin_id = ? AND event = ?", id, adminID, model.ProposalEventApproved).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyApproved
+		}
+		if err := addProposalEvent(tx, id, &adminID, model.ProposalEventApproved, note); err != nil {
+			return err
+		}
+
+		proposal.Approvals++
+		updates := map[string]any{"approvals": proposal.Approvals}
+		if proposal.Approvals >= proposal.Required {
+			proposal.Status = model.ProposalStatusExecuting
+			updates["status"] = proposal.Status
+			ready = true
+		}
+		return tx.Model(proposal).Updates(updates).Error
+	})
+	if err == nil && expired {
+		err = ErrProposalExpired
+	}
+	return proposal, ready, err
+}
+
+// Reject ends a pending proposal, it is cancelled when the proposer does it
+func (r *proposalRepository) Reject(ctx context.Context, id, adminID uint64, note string) (*model.Proposal, error) {
+	proposal := &model.Proposal{}
+	expired := false
+	err := r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		var err error
+		proposal, expired, err = lockPendingProposal(tx, id)
+		if err != nil || expired {
+			return err
+		}
+
+		status, event := model.ProposalStatusRejected, model.ProposalEventRejected
+		if proposal.ProposedBy == adminID {
+			status, event = model.ProposalStatusCancelled, model.ProposalEventCancelled
+		}
+		if err := addProposalEvent(tx, id, &adminID, event, note); err != nil {
+			return err
+		}
+		proposal.Status = status
+		return tx.Model(proposal).Update("status", status).Error
+	})
+	if err == nil && expired {
+		err = ErrProposalExpired
+	}
+	return proposal, err
+}
+
+// Finish stores how running an approved proposal went
+func (r *proposalRepository) Finish(ctx context.Context, proposal *model.Proposal, execErr error) error {
+	now := time.Now()
+	proposal.ExecutedAt = &now
+	proposal.Status = model.ProposalStatusExecuted
+	event, note := model.ProposalEventExecuted, ""
+	if execErr != nil {
+		proposal.Status = model.ProposalStatusFailed
+		proposal.Error = utils.Truncate(execErr.Error(), 500)
+		event, note = model.ProposalEventFailed, proposal.Error
+	}
+	return r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(proposal).Select("status", "executed_at", "error").Updates(proposal).Error
+		if err != nil {
+			return err
+		}
+		return addProposalEvent(tx, proposal.ID, nil, event, note)
+	})
+}
+
+// Expire marks pending proposals past their expiry, it returns how many
+func (r *proposalRepository) Expire(ctx context.Context, now time.Time) (int, error) {
+	ids := make([]uint64, 0)
+	err := r.DB.WithContext(ctx).Debug().Model(&model.Proposal{}).
+		Where("status = ? AND expires_at <= ?", model.ProposalStatusPending, now).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		err := r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+			_, isExpired, err := lockPendingProposal(tx, id)
+			if err == nil && isExpired {
+				expired++
+			}
+			if errors.Is(err, ErrProposalNotPending) {
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// lockPendingProposal locks a proposal for a decision. One found past its
+// expiry is marked expired on the way, which the caller must commit.
+func lockPendingProposal(tx *gorm.DB, id uint64) (*model.Proposal, bool, error) {
+	proposal := &model.Proposal{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(proposal, "id = ?", id).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if proposal.Status != model.ProposalStatusPending {
+		return nil, false, ErrProposalNotPending
+	}
+	if time.Now().Before(proposal.ExpiresAt) {
+		return proposal, false, nil
+	}
+
+	proposal.Status = model.ProposalStatusExpired
+	if err := tx.Model(proposal).Update("status", proposal.Status).Error; err != nil {
+		return nil, false, err
+	}
+	return proposal, true, addProposalEvent(tx, id, nil, model.ProposalEventExpired, "")
+}
+
+func addProposalEvent(tx *gorm.DB, proposalID uint64, adminID *uint64, event, note string) error {
+	return tx.Create(&model.ProposalEvent{
+		ProposalID: proposalID,
+		AdminID:    adminID,
+		Event:      event,
+		Note:       note,
+	}).Error
+}
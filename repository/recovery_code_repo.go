@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// recoveryCodeRepository keeps the 2fa recovery codes of users and admins,
+// owners are named by their audit actor type and ID
+type recoveryCodeRepository struct {
+	DB *gorm.DB
+}
+
+func newRecoveryCodeRepository(ds *ds.DataSource) *recoveryCodeRepository {
+	return &recoveryCodeRepository{
+		DB: ds.DB,
+	}
+}
+
+// Replace issues a new set of codes and drops the old ones. The codes are
+// only returned here.
+func (r *recoveryCodeRepository) Replace(ctx context.Context, ownerType, ownerID string) ([]string, error) {
+	codes := make([]string, 0, model.RecoveryCodeCount)
+	records := make([]*model.RecoveryCode, 0, model.RecoveryCodeCount)
+	for i := 0; i < model.RecoveryCodeCount; i++ {
+		raw, err := utils.RandomHex(8)
+		if err != nil {
+			return nil, err
+		}
+		code := strings.Join([]string{raw[0:4], raw[4:8], raw[8:12], raw[12:16]}, "-")
+		codes = append(codes, code)
+		records = append(records, &model.RecoveryCode{
+			OwnerType: ownerType,
+			OwnerID:   ownerID,
+			CodeHash:  utils.SHA256Hex(normalizeRecoveryCode(code)),
+		})
+	}
+
+	err := r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+			Delete(&model.RecoveryCode{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	return codes, err
+}
+
+// Use spends a code, it reports false for unknown or used ones
+func (r *recoveryCodeRepository) Use(ctx context.Context, ownerType, ownerID, code string) (bool, error) {
+	code = normalizeRecoveryCode(code)
+	if code == "" {
+		return false, nil
+	}
+	result := r.DB.WithContext(ctx).Debug().Model(&model.RecoveryCode{}).
+		Where("owner_type = ? AND owner_id = ? AND code_hash = ? AND used_at IS NULL", ownerType, ownerID, utils.SHA256Hex(code)).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// Check accepts a totp code for secret or else spends a recovery code
+func (r *recoveryCodeRepository) Check(ctx context.Context, ownerType, ownerID, secret, code string) (bool, error) {
+	if secret != "" && utils.Validate2fa(code, secret) {
+		return true, nil
+	}
+	return r.Use(ctx, ownerType, ownerID, code)
+}
+
+// Remaining counts the unused codes
+func (r *recoveryCodeRepository) Remaining(ctx context.Context, ownerType, ownerID string) (int64, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Debug().Model(&model.RecoveryCode{}).
+		Where("owner_type = ? AND owner_id = ? AND used_at IS NULL", ownerType, ownerID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *recoveryCodeRepository) Delete(ctx context.Context, ownerType, ownerID string) error {
+	return r.DB.WithContext(ctx).Debug().Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+		Delete(&model.RecoveryCode{}).Error
+}
+
+// normalizeRecoveryCode ignores case, dashes and spaces
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -10,11 +10,35 @@ var (
 )
 
 type Repository struct {
-	DS     *ds.DataSource
-	Bank   *bankRepository
-	Admin  *adminRepository
-	User   *userRepository
-	Wallet *walletRepository
+	DS          *ds.DataSource
+	Bank        *bankRepository
+	Admin       *adminRepository
+	User        *userRepository
+	Wallet      *walletRepository
+	Tx          *transactionRepository
+	FeeQuote    *feeQuoteRepository
+	Snapshot    *snapshotRepository
+	Invoice     *invoiceRepository
+	Deposit     *depositRepository
+	Merchant    *merchantRepository
+	Webhook     *webhookRepository
+	Idempotency *idempotencyRepository
+	Payout      *payoutRepository
+	Schedule    *scheduleRepository
+	Whitelist   *whitelistRepository
+	Limit       *limitRepository
+	KYC         *kycRepository
+	Screening   *screeningRepository
+	Risk        *riskRepository
+	Geo         *geoRepository
+	Token       *tokenRepository
+	Role        *roleRepository
+	Audit       *auditRepository
+	Proposal    *proposalRepository
+	Lockout     *lockoutRepository
+	UserToken   *userTokenRepository
+	Recovery    *recoveryCodeRepository
+	WebAuthn    *webAuthnRepository
 }
 
 func NewRepository(ds *ds.DataSource, svc *service.Service) *Repository {
@@ -22,11 +46,59 @@ func NewRepository(ds *ds.DataSource, svc *service.Service) *Repository {
 	adminRepo := newAdminRepository(ds)
 	userRepo := newUserRepository(ds)
 	walletRepo := newWalletRepository(ds, svc)
+	txRepo := newTransactionRepository(ds)
+	feeQuoteRepo := newFeeQuoteRepository(ds)
+	snapshotRepo := newSnapshotRepository(ds)
+	invoiceRepo := newInvoiceRepository(ds)
+	depositRepo := newDepositRepository(ds)
+	merchantRepo := newMerchantRepository(ds)
+	webhookRepo := newWebhookRepository(ds)
+	idempotencyRepo := newIdempotencyRepository(ds)
+	payoutRepo := newPayoutRepository(ds)
+	scheduleRepo := newScheduleRepository(ds)
+	whitelistRepo := newWhitelistRepository(ds)
+	limitRepo := newLimitRepository(ds)
+	kycRepo := newKYCRepository(ds)
+	screeningRepo := newScreeningRepository(ds)
+	riskRepo := newRiskRepository(ds)
+	geoRepo := newGeoRepository(ds)
+	tokenRepo := newTokenRepository(ds)
+	roleRepo := newRoleRepository(ds)
+	auditRepo := newAuditRepository(ds)
+	proposalRepo := newProposalRepository(ds)
+	lockoutRepo := newLockoutRepository(ds)
+	userTokenRepo := newUserTokenRepository(ds)
+	recoveryRepo := newRecoveryCodeRepository(ds)
+	webAuthnRepo := newWebAuthnRepository(ds)
 	return &Repository{
-		DS:     ds,
-		Bank:   bankRepo,
-		Admin:  adminRepo,
-		User:   userRepo,
-		Wallet: walletRepo,
+		DS:          ds,
+		Bank:        bankRepo,
+		Admin:       adminRepo,
+		User:        userRepo,
+		Wallet:      walletRepo,
+		Tx:          txRepo,
+		FeeQuote:    feeQuoteRepo,
+		Snapshot:    snapshotRepo,
+		Invoice:     invoiceRepo,
+		Deposit:     depositRepo,
+		Merchant:    merchantRepo,
+		Webhook:     webhookRepo,
+		Idempotency: idempotencyRepo,
+		Payout:      payoutRepo,
+		Schedule:    scheduleRepo,
+		Whitelist:   whitelistRepo,
+		Limit:       limitRepo,
+		KYC:         kycRepo,
+		Screening:   screeningRepo,
+		Risk:        riskRepo,
+		Geo:         geoRepo,
+		Token:       tokenRepo,
+		Role:        roleRepo,
+		Audit:       auditRepo,
+		Proposal:    proposalRepo,
+		Lockout:     lockoutRepo,
+		UserToken:   userTokenRepo,
+		Recovery:    recoveryRepo,
+		WebAuthn:    webAuthnRepo,
 	}
 }
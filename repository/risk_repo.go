@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type riskRepository struct {
+	DB *gorm.DB
+}
+
+func newRiskRepository(ds *ds.DataSource) *riskRepository {
+	return &riskRepository{
+		DB: ds.DB,
+	}
+}
+
+// SeenDevice records that the user used device, the first sighting is kept
+func (r *riskRepository) SeenDevice(ctx context.Context, userID uuid.UUID, device, ip string, now time.Time) error {
+	return r.DB.WithContext(ctx).Debug().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "device"}},
+		DoUpdates: clause.Assignments(map[string]any{"ip": ip, "last_seen": now}),
+	}).Create(&model.UserDevice{UserID: userID, Device: device, IP: ip, LastSeen: now}).Error
+}
+
+// DeviceFirstSeen is when the user first used device, nil when never
+func (r *riskRepository) DeviceFirstSeen(ctx context.Context, userID uuid.UUID, device string) (*time.Time, error) {
+	entry := model.UserDevice{}
+	err := r.DB.WithContext(ctx).Debug().First(&entry, "user_id = ? AND device = ?", userID, device).Error
+	if err != nil {
+		if utils.IsErrNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry.CreatedAt, nil
+}
+
+// WithdrawalHistory is the count and average amount of the user's
+// withdrawals of currency since
+func (r *riskRepository) WithdrawalHistory(ctx context.Context, userID uuid.UUID, currency string, since time.Time) (int64, float64, error) {
+	result := struct {
+		Count   int64
+		Average float64
+	}{}
+	err := r.DB.WithContext(ctx).Debug().Model(&model.Transaction{}).
+		Select("COUNT(*) AS count, COALESCE(AVG(amount), 0) AS average").
+		Where("user_id = ? AND currency = ? AND type IN ? AND created_at >= ?",
+			userID, currency, []string{model.TxTypeWithdrawal, model.TxTypeInternal}, since).
+		Scan(&result).Error
+	return result.Count, result.Average, err
+}
+
+// HasSentTo reports whether the user withdrew to address before
+func (r *riskRepository) HasSentTo(ctx context.Context, userID uuid.UUID, network, address string) (bool, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Debug().Model(&model.Transaction{}).
+		Where("user_id = ? AND network = ? AND to_address = ?", userID, network, address).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *riskRepository) Create(ctx context.Context, assessment *model.RiskAssessment) error {
+	return r.DB.WithContext(ctx).Debug().Create(assessment).Error
+}
+
+func (r *riskRepository) FindByID(ctx context.Context, id uint64) (*model.RiskAssessment, error) {
+	assessment := model.RiskAssessment{}
+	err := r.DB.WithContext(ctx).Debug().First(&assessment, "id = ?", id).Error
+	return &assessment, err
+}
+
+func (r *riskRepository) List(ctx context.Context, req *dto.RiskListReq) ([]*model.RiskAssessment, int64, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.RiskAssessment{})
+	if req.Decision != "" {
+		tb.Where("decision = ?", req.Decision)
+	}
+	if req.ReviewStatus != "" {
+		tb.Where("review_status = ?", req.ReviewStatus)
+	}
+	var total int64
+	tb.Count(&total)
+	tb.Scopes(utils.Paginate(req.Page, req.PageSize))
+	list := make([]*model.RiskAssessment, 0)
+	return list, total, tb.Order("id DESC").Find(&list).Error
+}
+
+// Decide moves a pending review to status, it reports false when an admin
+// already decided it so an approved withdrawal is only sent once
+func (r *riskRepository) Decide(ctx context.Context, assessment *model.RiskAssessment, status string, adminID uint64) (bool, error) {
+	now := time.Now()
+	db := r.DB.WithContext(ctx).Debug().Model(&model.RiskAssessment{}).
+		Where("id = ? AND review_status = ?", assessment.ID, model.RiskReviewPending).
+		Updates(map[string]any{
+			"review_status": status,
+			"reviewed_by":   adminID,
+			"reviewed_at":   now,
+		})
+	if db.Error != nil || db.RowsAffected == 0 {
+		return false, db.Error
+	}
+	assessment.ReviewStatus = status
+	assessment.ReviewedBy = &adminID
+	assessment.ReviewedAt = &now
+	return true, nil
+}
+
+// Expire ends a pending review nobody decided on, it reports false when it
+// was decided already
+func (r *riskRepository) Expire(ctx context.Context, assessment *model.RiskAssessment) (bool, error) {
+	now := time.Now()
+	db := r.DB.WithContext(ctx).Debug().Model(&model.RiskAssessment{}).
+		Where("id = ? AND review_status = ?", assessment.ID, model.RiskReviewPending).
+		Updates(map[string]any{
+			"review_status": model.RiskReviewExpired,
+			"reviewed_at":   now,
+		})
+	if db.Error != nil || db.RowsAffected == 0 {
+		return false, db.Error
+	}
+	assessment.ReviewStatus = model.RiskReviewExpired
+	assessment.ReviewedAt = &now
+	return true, nil
+}
+
+// Finish stores how the approved withdrawal went
+func (r *riskRepository) Finish(ctx context.Context, assessment *model.RiskAssessment) error {
+	return r.DB.WithContext(ctx).Debug().Model(assessment).Select("review_status", "tx_hash", "error").Updates(assessment).Error
+}
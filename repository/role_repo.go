@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/model"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBuiltinRole    = errors.New("built-in roles can not be changed")
+	ErrLastSuperAdmin = errors.New("at least one admin must keep the super-admin role")
+)
+
+type roleRepository struct {
+	DB *gorm.DB
+}
+
+func newRoleRepository(ds *ds.DataSource) *roleRepository {
+	return &roleRepository{
+		DB: ds.DB,
+	}
+}
+
+func (r *roleRepository) List(ctx context.Context) ([]*model.Role, error) {
+	list := make([]*model.Role, 0)
+	err := r.DB.WithContext(ctx).Debug().Order("id").Find(&list).Error
+	return list, err
+}
+
+func (r *roleRepository) FindByID(ctx context.Context, id uint64) (*model.Role, error) {
+	role := model.Role{}
+	err := r.DB.WithContext(ctx).Debug().First(&role, "id = ?", id).Error
+	return &role, err
+}
+
+func (r *roleRepository) Create(ctx context.Context, role *model.Role) error {
+	return r.DB.WithContext(ctx).Debug().Create(role).Error
+}
+
+// Update saves the name, description and permissions of a custom role
+func (r *roleRepository) Update(ctx context.Context, role *model.Role) error {
+	if role.Builtin {
+		return ErrBuiltinRole
+	}
+	return r.DB.WithContext(ctx).Debug().Model(role).
+		Select("name", "description", "permissions").Updates(role).Error
+}
+
+// Delete removes a custom role and takes it from every admin that has it
+func (r *roleRepository) Delete(ctx context.Context, role *model.Role) error {
+	if role.Builtin {
+		return ErrBuiltinRole
+	}
+	return r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&model.AdminRole{}, "role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+}
+
+// AdminRoles returns the roles assigned to an admin
+func (r *roleRepository) AdminRoles(ctx context.Context, adminID uint64) ([]*model.AdminRole, error) {
+	list := make([]*model.AdminRole, 0)
+	err := r.DB.WithContext(ctx).Debug().Preload("Role").
+		Where("admin_id = ?", adminID).Order("role_id").Find(&list).Error
+	return list, err
+}
+
+// SetAdminRoles replaces the roles of an admin. It fails when no admin
+// would be left with super-admin, so the back office can't lock itself out.
+func (r *roleRepository) SetAdminRoles(ctx context.Context, adminID uint64, roleIDs []uint64, by uint64) error {
+	return r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&model.AdminRole{}, "admin_id = ?", adminID).Error; err != nil {
+			return err
+		}
+		if len(roleIDs) > 0 {
+			var count int64
+			if err := tx.Model(&model.Role{}).Where("id IN ?", roleIDs).Count(&count).Error; err != nil {
+				return err
+			}
+			if int(count) != len(roleIDs) {
+				return gorm.ErrRecordNotFound
+			}
+
+			assignments := make([]*model.AdminRole, 0, len(roleIDs))
+			for _, roleID := range roleIDs {
+				assignments = append(assignments, &model.AdminRole{AdminID: adminID, RoleID: roleID, CreatedBy: by})
+			}
+			if err := tx.Create(&assignments).Error; err != nil {
+				return err
+			}
+		}
+
+		return ensureSuperAdmin(tx)
+	})
+}
+
+// ensureSuperAdmin fails with ErrLastSuperAdmin when no admin is left with
+// super-admin, it runs last in the transaction that changed them
+func ensureSuperAdmin(tx *gorm.DB) error {
+	var supers int64
+	err := tx.Model(&model.AdminRole{}).
+		Joins("JOIN roles ON roles.id = admin_roles.role_id").
+		Joins("JOIN admins ON admins.id = admin_roles.admin_id AND admins.deleted_at IS NULL").
+		Where("roles.name = ?", model.RoleSuperAdmin).
+		Count(&supers).Error
+	if err != nil {
+		return err
+	}
+	if supers == 0 {
+		return ErrLastSuperAdmin
+	}
+	return nil
+}
+
+// Permissions returns every permission granted to an admin by their roles
+func (r *roleRepository) Permissions(ctx context.Context, adminID uint64) ([]string, error) {
+	roles := make([]*model.Role, 0)
+	err := r.DB.WithContext(ctx).Debug().
+		Joins("JOIN admin_roles ON admin_roles.role_id = roles.id").
+		Where("admin_roles.admin_id = ?", adminID).
+		Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]string, 0)
+	for _, role := range roles {
+		permissions = append(permissions, role.Permissions...)
+	}
+	return permissions, nil
+}
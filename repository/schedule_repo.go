@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type scheduleRepository struct {
+	DB *gorm.DB
+}
+
+func newScheduleRepository(ds *ds.DataSource) *scheduleRepository {
+	return &scheduleRepository{
+		DB: ds.DB,
+	}
+}
+
+func (r *scheduleRepository) Create(ctx context.Context, schedule *model.ScheduledTransfer) error {
+	return r.DB.WithContext(ctx).Debug().Create(schedule).Error
+}
+
+func (r *scheduleRepository) FindByUser(ctx context.Context, userID uuid.UUID, id string) (*model.ScheduledTransfer, error) {
+	schedule := model.ScheduledTransfer{}
+	err := r.DB.WithContext(ctx).Debug().First(&schedule, "id = ? AND user_id = ?", id, userID).Error
+	return &schedule, err
+}
+
+func (r *scheduleRepository) ListByUser(ctx context.Context, userID uuid.UUID, req *dto.ScheduledTransferListReq) ([]*model.ScheduledTransfer, int64, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.ScheduledTransfer{}).Where("user_id = ?", userID)
+	if req.Status != "" {
+		tb.Where("status = ?", req.Status)
+	}
+	var total int64
+	tb.Count(&total)
+	tb.Scopes(utils.Paginate(req.Page, req.PageSize))
+	list := make([]*model.ScheduledTransfer, 0)
+	return list, total, tb.Order("created_at DESC").Find(&list).Error
+}
+
+// ListRuns returns the latest runs of a schedule
+func (r *scheduleRepository) ListRuns(ctx context.Context, scheduleID uuid.UUID, limit int) ([]*model.ScheduledTransferRun, error) {
+	runs := make([]*model.ScheduledTransferRun, 0)
+	err := r.DB.WithContext(ctx).Debug().Where("schedule_id = ?", scheduleID).Order("id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// ListDue returns active schedules whose next run is due, with their user
+func (r *scheduleRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*model.ScheduledTransfer, error) {
+	schedules := make([]*model.ScheduledTransfer, 0)
+	err := r.DB.WithContext(ctx).Debug().Preload("User").
+		Where("status = ? AND next_run_at <= ?", model.ScheduleStatusActive, now).
+		Order("next_run_at").Limit(limit).Find(&schedules).Error
+	return schedules, err
+}
+
+// Claim moves the next run of a due schedule to next, so the due run is taken
+// once even with several workers. It reports false if it was taken already.
+func (r *scheduleRepository) Claim(ctx context.Context, schedule *model.ScheduledTransfer, next *time.Time, now time.Time) (bool, error) {
+	db := r.DB.WithContext(ctx).Debug().Model(&model.ScheduledTransfer{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", schedule.ID, model.ScheduleStatusActive, schedule.NextRunAt).
+		Updates(map[string]any{
+			"next_run_at": next,
+			"last_run_at": now,
+		})
+	if db.Error != nil || db.RowsAffected == 0 {
+		return false, db.Error
+	}
+	schedule.NextRunAt = next
+	schedule.LastRunAt = &now
+	return true, nil
+}
+
+// RecordRun saves the outcome of a run and completes the schedule when it
+// has no run left. A run that was held is updated instead, once.
+func (r *scheduleRepository) RecordRun(ctx context.Context, schedule *model.ScheduledTransfer, run *model.ScheduledTransferRun) error {
+	return r.DB.WithContext(ctx).Debug().Transaction(func(db *gorm.DB) error {
+		if run.ID == 0 {
+			if err := db.Create(run).Error; err != nil {
+				return err
+			}
+		} else {
+			res := db.Model(&model.ScheduledTransferRun{}).
+				Where("id = ? AND status = ?", run.ID, model.ScheduleRunHeld).
+				Updates(map[string]any{
+					"status":  run.Status,
+					"tx_hash": run.TxHash,
+					"error":   run.Error,
+				})
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+		}
+
+		updates := map[string]any{}
+		if run.Status == model.ScheduleRunSent {
+			schedule.RunCount++
+			updates["run_count"] = gorm.Expr("run_count + 1")
+		}
+		next := time.Time{}
+		if schedule.NextRunAt != nil {
+			next = *schedule.NextRunAt
+		}
+		if schedule.Finished(next) {
+			schedule.Status = model.ScheduleStatusCompleted
+			schedule.NextRunAt = nil
+			updates["status"] = model.ScheduleStatusCompleted
+			updates["next_run_at"] = nil
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return db.Model(&model.ScheduledTransfer{}).Where("id = ?", schedule.ID).Updates(updates).Error
+	})
+}
+
+// ListHeldRuns returns the runs of the schedule still waiting for review
+func (r *scheduleRepository) ListHeldRuns(ctx context.Context, scheduleID uuid.UUID) ([]*model.ScheduledTransferRun, error) {
+	runs := make([]*model.ScheduledTransferRun, 0)
+	err := r.DB.WithContext(ctx).Debug().Where("schedule_id = ? AND status = ?", scheduleID, model.ScheduleRunHeld).Find(&runs).Error
+	return runs, err
+}
+
+// heldRunStatus is the run status of each final review status
+var heldRunStatus = map[string]string{
+	model.RiskReviewSent:     model.ScheduleRunSent,
+	model.RiskReviewFailed:   model.ScheduleRunFailed,
+	model.RiskReviewRejected: model.ScheduleRunRejected,
+	model.RiskReviewExpired:  model.ScheduleRunExpired,
+}
+
+// FinishHeldRun records the review of assessment on the scheduled run it
+// held, if any, so a sent run counts towards MaxRuns
+func (r *scheduleRepository) FinishHeldRun(ctx context.Context, assessment *model.RiskAssessment) error {
+	status, ok := heldRunStatus[assessment.ReviewStatus]
+	if !ok {
+		return nil
+	}
+	run := model.ScheduledTransferRun{}
+	err := r.DB.WithContext(ctx).Debug().First(&run, "assessment_id = ? AND status = ?", assessment.ID, model.ScheduleRunHeld).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	schedule := model.ScheduledTransfer{}
+	if err := r.DB.WithContext(ctx).Debug().First(&schedule, "id = ?", run.ScheduleID).Error; err != nil {
+		return err
+	}
+
+	run.Status = status
+	run.TxHash = assessment.TxHash
+	run.Error = assessment.Error
+	return r.RecordRun(ctx, &schedule, &run)
+}
+
+// SetStatus moves the schedule to status if it is in one of from, next is
+// its next run after the change
+func (r *scheduleRepository) SetStatus(ctx context.Context, schedule *model.ScheduledTransfer, from []string, status string, next *time.Time) error {
+	db := r.DB.WithContext(ctx).Debug().Model(&model.ScheduledTransfer{}).
+		Where("id = ? AND status IN ?", schedule.ID, from).
+		Updates(map[string]any{
+			"status":      status,
+			"next_run_at": next,
+		})
+	if db.Error == nil && db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if db.Error == nil {
+		schedule.Status = status
+		schedule.NextRunAt = next
+	}
+	return db.Error
+}
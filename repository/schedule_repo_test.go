@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/model"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// a held run counts towards MaxRuns once its review sends it, a rejected one
+// never does
+func TestFinishHeldRun(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sqlite has no enum, so the tables are made by hand
+	err = db.Exec(`CREATE TABLE scheduled_transfers (id char(36) PRIMARY KEY, user_id char(36), kind text, network text,
+		currency text, amount real, to_address text, username text, rule text, start_at datetime, end_at datetime,
+		max_runs integer, run_count integer, next_run_at datetime, last_run_at datetime, status text,
+		created_at datetime, updated_at datetime)`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`CREATE TABLE scheduled_transfer_runs (id integer PRIMARY KEY AUTOINCREMENT, schedule_id char(36),
+		due_at datetime, status text, tx_hash text, assessment_id integer, error text, created_at datetime)`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &scheduleRepository{DB: db}
+
+	next := time.Now().Add(time.Hour)
+	schedule := &model.ScheduledTransfer{Rule: "@daily", StartAt: time.Now(), MaxRuns: 1, NextRunAt: &next, Status: model.ScheduleStatusActive}
+	if err := r.Create(ctx, schedule); err != nil {
+		t.Fatal(err)
+	}
+
+	hold := func(assessmentID uint64) {
+		t.Helper()
+		run := &model.ScheduledTransferRun{ScheduleID: schedule.ID, DueAt: time.Now(), Status: model.ScheduleRunHeld, AssessmentID: &assessmentID}
+		if err := r.RecordRun(ctx, schedule, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	load := func() *model.ScheduledTransfer {
+		t.Helper()
+		loaded := model.ScheduledTransfer{}
+		if err := db.First(&loaded, "id = ?", schedule.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return &loaded
+	}
+
+	hold(1)
+	rejected := &model.RiskAssessment{ID: 1, ReviewStatus: model.RiskReviewRejected}
+	if err := r.FinishHeldRun(ctx, rejected); err != nil {
+		t.Fatal(err)
+	}
+	if got := load(); got.RunCount != 0 || got.Status != model.ScheduleStatusActive {
+		t.Fatalf("rejected run counted: %d runs, %s", got.RunCount, got.Status)
+	}
+
+	hold(2)
+	sent := &model.RiskAssessment{ID: 2, ReviewStatus: model.RiskReviewSent, TxHash: "0xabc"}
+	if err := r.FinishHeldRun(ctx, sent); err != nil {
+		t.Fatal(err)
+	}
+	// a second review of the same run is ignored
+	if err := r.FinishHeldRun(ctx, sent); err != nil {
+		t.Fatal(err)
+	}
+	if got := load(); got.RunCount != 1 || got.Status != model.ScheduleStatusCompleted {
+		t.Fatalf("sent run: %d runs, %s, want 1 and completed", got.RunCount, got.Status)
+	}
+
+	runs, err := r.ListRuns(ctx, schedule.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[uint64]string{}
+	for _, run := range runs {
+		statuses[*run.AssessmentID] = run.Status
+	}
+	if statuses[1] != model.ScheduleRunRejected || statuses[2] != model.ScheduleRunSent {
+		t.Fatalf("run statuses %v", statuses)
+	}
+}
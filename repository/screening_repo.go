@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+
+	"gorm.io/gorm"
+)
+
+type screeningRepository struct {
+	DB *gorm.DB
+}
+
+func newScreeningRepository(ds *ds.DataSource) *screeningRepository {
+	return &screeningRepository{
+		DB: ds.DB,
+	}
+}
+
+func (r *screeningRepository) RecordHit(ctx context.Context, hit *model.ScreeningHit) error {
+	return r.DB.WithContext(ctx).Debug().Create(hit).Error
+}
+
+func (r *screeningRepository) ListHits(ctx context.Context, req *dto.ScreeningHitListReq) ([]*model.ScreeningHit, int64, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.ScreeningHit{})
+	if req.Direction != "" {
+		tb.Where("direction = ?", req.Direction)
+	}
+	if req.Address != "" {
+		tb.Where("address = ?", req.Address)
+	}
+	var total int64
+	tb.Count(&total)
+	tb.Scopes(utils.Paginate(req.Page, req.PageSize))
+	list := make([]*model.ScreeningHit, 0)
+	return list, total, tb.Order("id DESC").Find(&list).Error
+}
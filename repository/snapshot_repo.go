@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type snapshotRepository struct {
+	DB *gorm.DB
+}
+
+func newSnapshotRepository(ds *ds.DataSource) *snapshotRepository {
+	return &snapshotRepository{
+		DB: ds.DB,
+	}
+}
+
+func (r *snapshotRepository) CreateMany(ctx context.Context, snapshots []*model.BalanceSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	return r.DB.WithContext(ctx).Debug().CreateInBatches(snapshots, 500).Error
+}
+
+// DailySeries sums the last snapshot of every day since the given time
+func (r *snapshotRepository) DailySeries(ctx context.Context, userID uuid.UUID, since time.Time) ([]*dto.PortfolioPoint, error) {
+	lastOfDay := r.DB.Model(&model.BalanceSnapshot{}).
+		Select("MAX(taken_at)").
+		Where("user_id = ? AND taken_at >= ?", userID, since).
+		Group("DATE(taken_at)")
+
+	points := make([]*dto.PortfolioPoint, 0)
+	err := r.DB.WithContext(ctx).Debug().Model(&model.BalanceSnapshot{}).
+		Select("DATE(taken_at) AS date, SUM(value) AS value").
+		Where("user_id = ? AND taken_at IN (?)", userID, lastOfDay).
+		Group("DATE(taken_at)").
+		Order("date").
+		Scan(&points).Error
+	return points, err
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, the session is revoked")
+)
+
+// sessions record last seen at most this often
+const sessionSeenInterval = time.Minute
+
+// tokenRepository keeps the sessions and the refresh tokens rotated within
+// them. Revoked sessions are listed in redis until their access tokens have
+// expired, the auth middleware checks that list on every request.
+type tokenRepository struct {
+	DB  *gorm.DB
+	RDB *redis.Client
+}
+
+func newTokenRepository(ds *ds.DataSource) *tokenRepository {
+	return &tokenRepository{
+		DB:  ds.DB,
+		RDB: ds.RDB,
+	}
+}
+
+// Issue records the session of a login and returns its first token pair
+func (r *tokenRepository) Issue(ctx context.Context, session *model.Session) (*dto.TokenResp, error) {
+	now := time.Now()
+	session.ID = uuid.NewString()
+	session.ExpiresAt = now.Add(conf.RefreshTokenTTL)
+	session.LastSeen = now
+
+	var tokens *dto.TokenResp
+	err := r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		var err error
+		tokens, err = r.issue(tx, session.ID, session.Username, session.IsAdmin)
+		return err
+	})
+	return tokens, err
+}
+
+// Refresh trades a refresh token for a new pair and retires it. A token that
+// was already traded means it leaked, so its whole session is revoked.
+func (r *tokenRepository) Refresh(ctx context.Context, raw string) (*dto.TokenResp, error) {
+	token, err := r.find(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	if token.RotatedAt != nil {
+		r.revokeReused(ctx, token)
+		return nil, ErrRefreshTokenReused
+	}
+
+	var tokens *dto.TokenResp
+	err = r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// only one refresh may rotate a token, the loser is treated as reuse
+		result := tx.Model(&model.RefreshToken{}).
+			Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", token.ID).
+			Update("rotated_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		err := tx.Model(&model.Session{}).Where("id = ?", token.FamilyID).
+			Updates(map[string]any{"expires_at": now.Add(conf.RefreshTokenTTL), "last_seen": now}).Error
+		if err != nil {
+			return err
+		}
+		tokens, err = r.issue(tx, token.FamilyID, token.Username, token.IsAdmin)
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		r.revokeReused(ctx, token)
+	}
+	return tokens, err
+}
+
+// Revoke ends the session of raw, used on logout. Unknown tokens are ignored.
+func (r *tokenRepository) Revoke(ctx context.Context, raw string) error {
+	token := model.RefreshToken{}
+	err := r.DB.WithContext(ctx).Debug().First(&token, "token_hash = ?", utils.SHA256Hex(raw)).Error
+	if err != nil {
+		if utils.IsErrNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return r.RevokeSession(ctx, token.FamilyID)
+}
+
+// RevokeSession ends a session, its refresh tokens stop working and its
+// access tokens are refused until they expire
+func (r *tokenRepository) RevokeSession(ctx context.Context, id string) error {
+	now := time.Now()
+	err := r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.Session{}).Where("id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+	// an access token never outlives AccessTokenTTL, so neither does the entry
+	return r.RDB.Set(ctx, revokedSessionKey(id), 1, conf.AccessTokenTTL+time.Minute).Err()
+}
+
+// RevokeOtherSessions ends every session of the account but keepID, which
+// may be empty to end them all
+func (r *tokenRepository) RevokeOtherSessions(ctx context.Context, username string, isAdmin bool, keepID string) error {
+	sessions, err := r.ListSessions(ctx, username, isAdmin)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID == keepID {
+			continue
+		}
+		if err := r.RevokeSession(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListSessions returns the active sessions of the account, latest first
+func (r *tokenRepository) ListSessions(ctx context.Context, username string, isAdmin bool) ([]*model.Session, error) {
+	sessions := make([]*model.Session, 0)
+	err := r.DB.WithContext(ctx).Debug().
+		Where("username = ? AND is_admin = ? AND revoked_at IS NULL AND expires_at > ?", username, isAdmin, time.Now()).
+		Order("last_seen DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// FindSession returns a session of the account
+func (r *tokenRepository) FindSession(ctx context.Context, id, username string, isAdmin bool) (*model.Session, error) {
+	session := model.Session{}
+	err := r.DB.WithContext(ctx).Debug().
+		First(&session, "id = ? AND username = ? AND is_admin = ?", id, username, isAdmin).Error
+	return &session, err
+}
+
+// IsSessionRevoked checks the revocation list
+func (r *tokenRepository) IsSessionRevoked(ctx context.Context, id string) (bool, error) {
+	count, err := r.RDB.Exists(ctx, revokedSessionKey(id)).Result()
+	return count > 0, err
+}
+
+// TouchSession records that the session was used, at most once a minute
+func (r *tokenRepository) TouchSession(ctx context.Context, id string) error {
+	first, err := r.RDB.SetNX(ctx, fmt.Sprintf("session_seen:%s", id), 1, sessionSeenInterval).Result()
+	if err != nil || !first {
+		return err
+	}
+	return r.DB.WithContext(ctx).Debug().Model(&model.Session{}).Where("id = ?", id).
+		Update("last_seen", time.Now()).Error
+}
+
+func revokedSessionKey(id string) string {
+	return fmt.Sprintf("session_revoked:%s", id)
+}
+
+// find returns the token of raw when it is neither revoked nor expired
+func (r *tokenRepository) find(ctx context.Context, raw string) (*model.RefreshToken, error) {
+	if raw == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	token := model.RefreshToken{}
+	err := r.DB.WithContext(ctx).Debug().First(&token, "token_hash = ?", utils.SHA256Hex(raw)).Error
+	if err != nil {
+		if utils.IsErrNotFound(err) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	return &token, nil
+}
+
+func (r *tokenRepository) revokeReused(ctx context.Context, token *model.RefreshToken) {
+	log.Printf("refresh token reuse for %s, revoking session %s\n", token.Username, token.FamilyID)
+	if err := r.RevokeSession(ctx, token.FamilyID); err != nil {
+		log.Println(err, "Error revoking session ", token.FamilyID)
+	}
+}
+
+func (r *tokenRepository) issue(db *gorm.DB, sessionID, username string, isAdmin bool) (*dto.TokenResp, error) {
+	accessToken, err := utils.GenerateAccessToken(username, isAdmin, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := utils.RandomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &model.RefreshToken{
+		FamilyID:  sessionID,
+		TokenHash: utils.SHA256Hex(raw),
+		Username:  username,
+		IsAdmin:   isAdmin,
+		ExpiresAt: time.Now().Add(conf.RefreshTokenTTL),
+	}
+	if err := db.Create(token).Error; err != nil {
+		return nil, err
+	}
+
+	return &dto.TokenResp{
+		AccessToken:  accessToken,
+		RefreshToken: raw,
+		ExpiresIn:    int(conf.AccessTokenTTL.Seconds()),
+	}, nil
+}
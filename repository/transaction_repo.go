@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+
+	"gorm.io/gorm"
+)
+
+type transactionRepository struct {
+	DB *gorm.DB
+}
+
+func newTransactionRepository(ds *ds.DataSource) *transactionRepository {
+	return &transactionRepository{
+		DB: ds.DB,
+	}
+}
+
+func (r *transactionRepository) Create(ctx context.Context, tx *model.Transaction) error {
+	return r.DB.WithContext(ctx).Debug().Create(tx).Error
+}
+
+func (r *transactionRepository) FindByID(ctx context.Context, id uint64) (*model.Transaction, error) {
+	tx := model.Transaction{}
+	err := r.DB.WithContext(ctx).Debug().First(&tx, "id = ?", id).Error
+	return &tx, err
+}
+
+func (r *transactionRepository) FindByHash(ctx context.Context, txHash string) (*model.Transaction, error) {
+	tx := model.Transaction{}
+	err := r.DB.WithContext(ctx).Debug().First(&tx, "tx_hash = ?", txHash).Error
+	return &tx, err
+}
+
+func (r *transactionRepository) List(ctx context.Context, req *dto.TransactionListReq) ([]*model.Transaction, int64, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.Transaction{})
+	if req.Address != "" {
+		tb.Where("from_address = ? OR to_address = ?", req.Address, req.Address)
+	}
+	if req.State != 0 {
+		tb.Where("state = ?", req.State)
+	}
+	var total int64
+	tb.Count(&total)
+	tb.Scopes(utils.Paginate(req.Page, req.PageSize))
+	list := make([]*model.Transaction, 0)
+	return list, total, tb.Order("id DESC").Find(&list).Error
+}
+
+// Replace stores the replacement transaction and marks the original as replaced
+// by it, so the history keeps the link in both directions.
+func (r *transactionRepository) Replace(ctx context.Context, original, replacement *model.Transaction) error {
+	return r.DB.WithContext(ctx).Debug().Transaction(func(db *gorm.DB) error {
+		replacement.ReplacesID = &original.ID
+		if err := db.Create(replacement).Error; err != nil {
+			return err
+		}
+		return db.Model(&model.Transaction{}).Where("id = ?", original.ID).Updates(map[string]any{
+			"state":          model.StateReplaced,
+			"replaced_by_id": replacement.ID,
+		}).Error
+	})
+}
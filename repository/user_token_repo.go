@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/ds"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidUserToken = errors.New("token is invalid or expired")
+
+// userTokenRepository keeps the tokens emailed to users for verifying their
+// address and resetting their password
+type userTokenRepository struct {
+	DB  *gorm.DB
+	RDB *redis.Client
+}
+
+func newUserTokenRepository(ds *ds.DataSource) *userTokenRepository {
+	return &userTokenRepository{
+		DB:  ds.DB,
+		RDB: ds.RDB,
+	}
+}
+
+// Issue returns a new signed token for the user and retires the unused ones
+// of the same purpose
+func (r *userTokenRepository) Issue(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	raw, err := utils.SignToken(conf.EmailTokenSecret, purpose, now.Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	err = r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&model.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: utils.SHA256Hex(raw),
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	return raw, err
+}
+
+// Consume spends a token, it works only once and only before it expires
+func (r *userTokenRepository) Consume(ctx context.Context, raw, purpose string) (*model.UserToken, error) {
+	now := time.Now()
+	if !utils.VerifyToken(conf.EmailTokenSecret, purpose, raw, now) {
+		return nil, ErrInvalidUserToken
+	}
+
+	token := model.UserToken{}
+	err := r.DB.WithContext(ctx).Debug().First(&token, "token_hash = ? AND purpose = ?", utils.SHA256Hex(raw), purpose).Error
+	if err != nil {
+		if utils.IsErrNotFound(err) {
+			return nil, ErrInvalidUserToken
+		}
+		return nil, err
+	}
+
+	// only one request may use a token
+	result := r.DB.WithContext(ctx).Debug().Model(&model.UserToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidUserToken
+	}
+	token.UsedAt = &now
+	return &token, nil
+}
+
+// Throttle reports whether an email of purpose may be sent to the user now,
+// at most one goes out per EmailResendInterval
+func (r *userTokenRepository) Throttle(ctx context.Context, userID uuid.UUID, purpose string) (bool, error) {
+	key := fmt.Sprintf("user_token_sent:%s:%s", purpose, userID)
+	return r.RDB.SetNX(ctx, key, 1, conf.EmailResendInterval).Result()
+}
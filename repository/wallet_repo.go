@@ -1,10 +1,13 @@
 package repository
 
 import (
+	"context"
 	"cryptoshare/ds"
+	"cryptoshare/dto"
 	"cryptoshare/model"
 	"cryptoshare/service"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -25,3 +28,71 @@ func (r *walletRepository) Create(wallet *model.Wallet) (*model.Wallet, error) {
 	err := db.Create(&wallet).Error
 	return nil, err
 }
+
+func (r *walletRepository) FindByUserAndNetwork(ctx context.Context, userID uuid.UUID, network string) (*model.Wallet, error) {
+	wallet := model.Wallet{}
+	err := r.DB.WithContext(ctx).Debug().First(&wallet, "user_id = ? AND network = ?", userID, network).Error
+	return &wallet, err
+}
+
+// Holdings returns the balance of every asset in the wallets of userID, or of
+// all users when userID is nil. Wallets without asset rows fall back to their
+// own balance columns.
+func (r *walletRepository) Holdings(ctx context.Context, userID *uuid.UUID) ([]*dto.PortfolioAsset, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.Wallet{})
+	if userID != nil {
+		tb.Where("user_id = ?", *userID)
+	}
+	wallets := make([]*model.Wallet, 0)
+	if err := tb.Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+	holdings := make([]*dto.PortfolioAsset, 0)
+	if len(wallets) == 0 {
+		return holdings, nil
+	}
+
+	walletIDs := make([]uuid.UUID, 0, len(wallets))
+	for _, wallet := range wallets {
+		walletIDs = append(walletIDs, wallet.ID)
+	}
+	assets := make([]*model.Asset, 0)
+	if err := r.DB.WithContext(ctx).Debug().Where("wallet_id IN ?", walletIDs).Find(&assets).Error; err != nil {
+		return nil, err
+	}
+	walletAssets := map[uuid.UUID][]*model.Asset{}
+	for _, asset := range assets {
+		walletAssets[asset.WalletID] = append(walletAssets[asset.WalletID], asset)
+	}
+
+	for _, wallet := range wallets {
+		if assets, ok := walletAssets[wallet.ID]; ok {
+			for _, asset := range assets {
+				holdings = append(holdings, &dto.PortfolioAsset{
+					UserID:   wallet.UserID,
+					WalletID: wallet.ID,
+					Network:  asset.Network,
+					Currency: asset.Currency,
+					Balance:  asset.Balance,
+				})
+			}
+			continue
+		}
+
+		native, nativeBalance := "ETH", wallet.ETHBalance
+		if wallet.Network == "TRC20" {
+			native, nativeBalance = "TRX", wallet.TRXBalance
+		}
+		holdings = append(holdings,
+			&dto.PortfolioAsset{UserID: wallet.UserID, WalletID: wallet.ID, Network: wallet.Network, Currency: native, Balance: nativeBalance},
+			&dto.PortfolioAsset{UserID: wallet.UserID, WalletID: wallet.ID, Network: wallet.Network, Currency: "USDT", Balance: wallet.USDTBalance},
+		)
+	}
+	return holdings, nil
+}
+
+func (r *walletRepository) ListByNetwork(ctx context.Context, network string) ([]*model.Wallet, error) {
+	wallets := make([]*model.Wallet, 0)
+	err := r.DB.WithContext(ctx).Debug().Where("network = ?", network).Find(&wallets).Error
+	return wallets, err
+}
@@ -0,0 +1,349 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/ds"
+	"cryptoshare/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWebAuthnDisabled    = errors.New("passkeys are not configured")
+	ErrNoPasskey           = errors.New("no passkey is registered")
+	ErrInvalidChallenge    = errors.New("passkey challenge is invalid or expired")
+	ErrInvalidPasskey      = errors.New("passkey response is invalid")
+	ErrPasskeyClone        = errors.New("passkey sign count went backwards, it may be cloned")
+	ErrLastRequiredPasskey = errors.New("the last passkey can't be removed while passkeys are required")
+)
+
+const (
+	webAuthnRegistration = "registration"
+	webAuthnAssertion    = "assertion"
+)
+
+// webAuthnRepository keeps the passkeys of admins and runs their
+// ceremonies. Challenges wait in redis for WebAuthnChallengeTTL and are
+// taken once.
+type webAuthnRepository struct {
+	DB       *gorm.DB
+	RDB      *redis.Client
+	WebAuthn *webauthn.WebAuthn
+}
+
+func newWebAuthnRepository(ds *ds.DataSource) *webAuthnRepository {
+	repo := &webAuthnRepository{
+		DB:  ds.DB,
+		RDB: ds.RDB,
+	}
+	if conf.WebAuthnRPID == "" {
+		return repo
+	}
+	w, err := webauthn.New(&webauthn.Config{
+		RPDisplayName: conf.WebAuthnRPName,
+		RPID:          conf.WebAuthnRPID,
+		RPOrigin:      conf.WebAuthnRPOrigin,
+		Timeout:       int(conf.WebAuthnChallengeTTL.Milliseconds()),
+	})
+	if err != nil {
+		log.Println(err, "Error configuring passkeys")
+		return repo
+	}
+	repo.WebAuthn = w
+	return repo
+}
+
+// webAuthnChallenge is a started ceremony
+type webAuthnChallenge struct {
+	AdminID  uint64               `json:"admin_id"`
+	Ceremony string               `json:"ceremony"`
+	Session  webauthn.SessionData `json:"session"`
+}
+
+// webAuthnAdmin is an admin as the webauthn library sees it
+type webAuthnAdmin struct {
+	admin       *model.Admin
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnAdmin) WebAuthnID() []byte {
+	return []byte(fmt.Sprintf("admin:%d", *u.admin.ID))
+}
+
+func (u *webAuthnAdmin) WebAuthnName() string {
+	return *u.admin.Username
+}
+
+func (u *webAuthnAdmin) WebAuthnDisplayName() string {
+	return *u.admin.Name
+}
+
+func (u *webAuthnAdmin) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webAuthnAdmin) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// Credentials lists the passkeys of an admin, latest first
+func (r *webAuthnRepository) Credentials(ctx context.Context, adminID uint64) ([]*model.WebAuthnCredential, error) {
+	list := make([]*model.WebAuthnCredential, 0)
+	err := r.DB.WithContext(ctx).Debug().Where("admin_id = ?", adminID).Order("id DESC").Find(&list).Error
+	return list, err
+}
+
+// BeginRegistration starts adding a passkey, the admin's other passkeys are
+// excluded so one authenticator isn't registered twice
+func (r *webAuthnRepository) BeginRegistration(ctx context.Context, admin *model.Admin) (string, *protocol.CredentialCreation, error) {
+	if r.WebAuthn == nil {
+		return "", nil, ErrWebAuthnDisabled
+	}
+	user, err := r.user(ctx, admin)
+	if err != nil {
+		return "", nil, err
+	}
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	options, session, err := r.WebAuthn.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := r.saveChallenge(ctx, *admin.ID, webAuthnRegistration, session)
+	return id, options, err
+}
+
+// FinishRegistration checks the attestation of a started registration and
+// keeps the new passkey
+func (r *webAuthnRepository) FinishRegistration(ctx context.Context, admin *model.Admin, challengeID, name string, response []byte) (*model.WebAuthnCredential, error) {
+	if r.WebAuthn == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+	session, err := r.takeChallenge(ctx, *admin.ID, webAuthnRegistration, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	user, err := r.user(ctx, admin)
+	if err != nil {
+		return nil, err
+	}
+	created, err := r.WebAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		log.Println(err, "Error verifying passkey registration of admin ", *admin.ID)
+		return nil, ErrInvalidPasskey
+	}
+
+	transports := make([]string, 0, len(created.Transport))
+	for _, transport := range created.Transport {
+		transports = append(transports, string(transport))
+	}
+	credential := &model.WebAuthnCredential{
+		AdminID:         *admin.ID,
+		Name:            name,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(created.ID),
+		PublicKey:       created.PublicKey,
+		AttestationType: created.AttestationType,
+		Transports:      transports,
+		AAGUID:          created.Authenticator.AAGUID,
+		SignCount:       created.Authenticator.SignCount,
+	}
+	err = r.DB.WithContext(ctx).Debug().Create(credential).Error
+	return credential, err
+}
+
+// BeginAssertion asks for any passkey of the admin
+func (r *webAuthnRepository) BeginAssertion(ctx context.Context, admin *model.Admin) (string, *protocol.CredentialAssertion, error) {
+	if r.WebAuthn == nil {
+		return "", nil, ErrWebAuthnDisabled
+	}
+	user, err := r.user(ctx, admin)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(user.credentials) == 0 {
+		return "", nil, ErrNoPasskey
+	}
+
+	options, session, err := r.WebAuthn.BeginLogin(user)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := r.saveChallenge(ctx, *admin.ID, webAuthnAssertion, session)
+	return id, options, err
+}
+
+// FinishAssertion checks the signature of a started assertion. The sign
+// count of the passkey must grow, otherwise it is refused as a clone.
+func (r *webAuthnRepository) FinishAssertion(ctx context.Context, admin *model.Admin, challengeID string, response []byte) (*model.WebAuthnCredential, error) {
+	if r.WebAuthn == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+	session, err := r.takeChallenge(ctx, *admin.ID, webAuthnAssertion, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	user, err := r.user(ctx, admin)
+	if err != nil {
+		return nil, err
+	}
+	used, err := r.WebAuthn.ValidateLogin(user, *session, parsed)
+	if err != nil {
+		log.Println(err, "Error verifying passkey of admin ", *admin.ID)
+		return nil, ErrInvalidPasskey
+	}
+
+	credential := model.WebAuthnCredential{}
+	err = r.DB.WithContext(ctx).Debug().
+		First(&credential, "admin_id = ? AND credential_id = ?", *admin.ID, base64.RawURLEncoding.EncodeToString(used.ID)).Error
+	if err != nil {
+		return nil, err
+	}
+	if used.Authenticator.CloneWarning {
+		log.Printf("passkey %d of admin %d sent sign count %d after %d\n", credential.ID, *admin.ID, parsed.Response.AuthenticatorData.Counter, credential.SignCount)
+		return nil, ErrPasskeyClone
+	}
+
+	// authenticators without a counter always send 0, others must move on
+	// from what was stored, also when two assertions race
+	now := time.Now()
+	result := r.DB.WithContext(ctx).Debug().Model(&model.WebAuthnCredential{}).
+		Where("id = ? AND (sign_count < ? OR sign_count = 0)", credential.ID, used.Authenticator.SignCount).
+		Updates(map[string]any{"sign_count": used.Authenticator.SignCount, "last_used_at": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrPasskeyClone
+	}
+	credential.SignCount = used.Authenticator.SignCount
+	credential.LastUsedAt = &now
+	return &credential, nil
+}
+
+// DeleteCredential removes a passkey of the admin, not the last one while
+// the admin requires passkeys
+func (r *webAuthnRepository) DeleteCredential(ctx context.Context, admin *model.Admin, id uint) error {
+	return r.DB.WithContext(ctx).Debug().Transaction(func(tx *gorm.DB) error {
+		credential := model.WebAuthnCredential{}
+		if err := tx.First(&credential, "id = ? AND admin_id = ?", id, *admin.ID).Error; err != nil {
+			return err
+		}
+		if admin.RequiresPasskey() {
+			var count int64
+			if err := tx.Model(&model.WebAuthnCredential{}).Where("admin_id = ?", *admin.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count <= 1 {
+				return ErrLastRequiredPasskey
+			}
+		}
+		return tx.Delete(&credential).Error
+	})
+}
+
+// DeleteAll removes every passkey of the admin, for assisted resets
+func (r *webAuthnRepository) DeleteAll(ctx context.Context, adminID uint64) error {
+	return r.DB.WithContext(ctx).Debug().Where("admin_id = ?", adminID).Delete(&model.WebAuthnCredential{}).Error
+}
+
+func (r *webAuthnRepository) user(ctx context.Context, admin *model.Admin) (*webAuthnAdmin, error) {
+	list, err := r.Credentials(ctx, *admin.ID)
+	if err != nil {
+		return nil, err
+	}
+	user := &webAuthnAdmin{admin: admin}
+	for _, credential := range list {
+		id, err := base64.RawURLEncoding.DecodeString(credential.CredentialID)
+		if err != nil {
+			return nil, err
+		}
+		transports := make([]protocol.AuthenticatorTransport, 0, len(credential.Transports))
+		for _, transport := range credential.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+		user.credentials = append(user.credentials, webauthn.Credential{
+			ID:              id,
+			PublicKey:       credential.PublicKey,
+			AttestationType: credential.AttestationType,
+			Transport:       transports,
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    credential.AAGUID,
+				SignCount: credential.SignCount,
+			},
+		})
+	}
+	return user, nil
+}
+
+func (r *webAuthnRepository) saveChallenge(ctx context.Context, adminID uint64, ceremony string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(&webAuthnChallenge{
+		AdminID:  adminID,
+		Ceremony: ceremony,
+		Session:  *session,
+	})
+	if err != nil {
+		return "", err
+	}
+	id := uuid.NewString()
+	err = r.RDB.Set(ctx, webAuthnChallengeKey(id), data, conf.WebAuthnChallengeTTL).Err()
+	return id, err
+}
+
+// takeChallenge returns a started ceremony once, it must belong to the admin
+func (r *webAuthnRepository) takeChallenge(ctx context.Context, adminID uint64, ceremony, id string) (*webauthn.SessionData, error) {
+	if id == "" {
+		return nil, ErrInvalidChallenge
+	}
+	var get *redis.StringCmd
+	_, err := r.RDB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, webAuthnChallengeKey(id))
+		pipe.Del(ctx, webAuthnChallengeKey(id))
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	data, err := get.Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, err
+	}
+
+	challenge := webAuthnChallenge{}
+	if err := json.Unmarshal(data, &challenge); err != nil {
+		return nil, err
+	}
+	if challenge.AdminID != adminID || challenge.Ceremony != ceremony {
+		return nil, ErrInvalidChallenge
+	}
+	return &challenge.Session, nil
+}
+
+func webAuthnChallengeKey(id string) string {
+	return fmt.Sprintf("webauthn_challenge:%s", id)
+}
@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"cryptoshare/model"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/go-redis/redis/v9"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testRPID     = "example.com"
+	testRPOrigin = "https://example.com"
+)
+
+// softAuthenticator is a passkey in memory, Counter is the sign count it
+// sends next
+type softAuthenticator struct {
+	key     *ecdsa.PrivateKey
+	id      []byte
+	Counter uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softAuthenticator{key: key, id: id, Counter: 1}
+}
+
+func (a *softAuthenticator) authData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.Counter)
+	return append(data, attested...)
+}
+
+func clientData(t *testing.T, ceremony string, challenge []byte) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    testRPOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// Create answers a registration with a "none" attestation
+func (a *softAuthenticator) Create(t *testing.T, options *protocol.CredentialCreation) []byte {
+	t.Helper()
+	publicKey, err := cbor.Marshal(map[int]any{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attested := make([]byte, 16) // aaguid
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.id)))
+	attested = append(attested, a.id...)
+	attested = append(attested, publicKey...)
+
+	attestation, err := cbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(0x45, attested), // user present, verified, attested data
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.response(t, map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData(t, "webauthn.create", options.Response.Challenge)),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+	})
+}
+
+// Get signs an assertion with the current counter
+func (a *softAuthenticator) Get(t *testing.T, options *protocol.CredentialAssertion) []byte {
+	t.Helper()
+	authData := a.authData(0x05, nil) // user present, verified
+	client := clientData(t, "webauthn.get", options.Response.Challenge)
+	clientHash := sha256.Sum256(client)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.response(t, map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(client),
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+	})
+}
+
+func (a *softAuthenticator) response(t *testing.T, response map[string]string) []byte {
+	t.Helper()
+	id := base64.RawURLEncoding.EncodeToString(a.id)
+	body, err := json.Marshal(map[string]any{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func newTestWebAuthnRepository(t *testing.T) *webAuthnRepository {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.WebAuthnCredential{}); err != nil {
+		t.Fatal(err)
+	}
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	w, err := webauthn.New(&webauthn.Config{RPDisplayName: "test", RPID: testRPID, RPOrigin: testRPOrigin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &webAuthnRepository{DB: db, RDB: rdb, WebAuthn: w}
+}
+
+func newTestAdmin(id uint64) *model.Admin {
+	name := fmt.Sprintf("admin%d", id)
+	return &model.Admin{ID: &id, Name: &name, Username: &name}
+}
+
+// register adds a passkey of authenticator to admin
+func register(t *testing.T, r *webAuthnRepository, admin *model.Admin, authenticator *softAuthenticator) *model.WebAuthnCredential {
+	t.Helper()
+	ctx := context.Background()
+	id, options, err := r.BeginRegistration(ctx, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := r.FinishRegistration(ctx, admin, id, "laptop", authenticator.Create(t, options))
+	if err != nil {
+		t.Fatalf("registration refused: %v", err)
+	}
+	return credential
+}
+
+// assert runs an assertion of authenticator for admin
+func assert(t *testing.T, r *webAuthnRepository, admin *model.Admin, authenticator *softAuthenticator) (*model.WebAuthnCredential, error) {
+	t.Helper()
+	ctx := context.Background()
+	id, options, err := r.BeginAssertion(ctx, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.FinishAssertion(ctx, admin, id, authenticator.Get(t, options))
+}
+
+func TestWebAuthnRegisterAndAssert(t *testing.T) {
+	r := newTestWebAuthnRepository(t)
+	admin := newTestAdmin(1)
+	authenticator := newSoftAuthenticator(t)
+
+	if _, _, err := r.BeginAssertion(context.Background(), admin); !errors.Is(err, ErrNoPasskey) {
+		t.Fatalf("got %v, want ErrNoPasskey before registering", err)
+	}
+
+	registered := register(t, r, admin, authenticator)
+	if registered.AdminID != 1 || registered.SignCount != 1 || registered.CredentialID != base64.RawURLEncoding.EncodeToString(authenticator.id) {
+		t.Fatalf("unexpected credential %+v", registered)
+	}
+
+	authenticator.Counter = 2
+	used, err := assert(t, r, admin, authenticator)
+	if err != nil {
+		t.Fatalf("assertion refused: %v", err)
+	}
+	if used.ID != registered.ID || used.SignCount != 2 || used.LastUsedAt == nil {
+		t.Fatalf("sign count not saved: %+v", used)
+	}
+
+	// a signature by another key is refused
+	forged := newSoftAuthenticator(t)
+	forged.id = authenticator.id
+	forged.Counter = 3
+	if _, err := assert(t, r, admin, forged); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("got %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestWebAuthnSignCountBackwards(t *testing.T) {
+	r := newTestWebAuthnRepository(t)
+	admin := newTestAdmin(1)
+	authenticator := newSoftAuthenticator(t)
+	register(t, r, admin, authenticator)
+
+	authenticator.Counter = 10
+	if _, err := assert(t, r, admin, authenticator); err != nil {
+		t.Fatalf("assertion refused: %v", err)
+	}
+
+	for _, counter := range []uint32{9, 10} {
+		authenticator.Counter = counter
+		if _, err := assert(t, r, admin, authenticator); !errors.Is(err, ErrPasskeyClone) {
+			t.Fatalf("counter %d after 10: got %v, want ErrPasskeyClone", counter, err)
+		}
+	}
+
+	credentials, err := r.Credentials(context.Background(), 1)
+	if err != nil || len(credentials) != 1 || credentials[0].SignCount != 10 {
+		t.Fatalf("stored sign count changed: %+v, %v", credentials, err)
+	}
+}
+
+func TestWebAuthnChallenge(t *testing.T) {
+	ctx := context.Background()
+	r := newTestWebAuthnRepository(t)
+	admin := newTestAdmin(1)
+	other := newTestAdmin(2)
+	authenticator := newSoftAuthenticator(t)
+	register(t, r, admin, authenticator)
+	register(t, r, other, newSoftAuthenticator(t))
+
+	// a challenge is taken once
+	id, options, err := r.BeginAssertion(ctx, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.Counter = 2
+	response := authenticator.Get(t, options)
+	if _, err := r.FinishAssertion(ctx, admin, id, response); err != nil {
+		t.Fatalf("assertion refused: %v", err)
+	}
+	if _, err := r.FinishAssertion(ctx, admin, id, response); !errors.Is(err, ErrInvalidChallenge) {
+		t.Fatalf("reused challenge: got %v, want ErrInvalidChallenge", err)
+	}
+
+	// a challenge of another admin is refused, and can't be used after
+	id, options, err = r.BeginAssertion(ctx, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.Counter = 3
+	if _, err := r.FinishAssertion(ctx, admin, id, authenticator.Get(t, options)); !errors.Is(err, ErrInvalidChallenge) {
+		t.Fatalf("challenge of another admin: got %v, want ErrInvalidChallenge", err)
+	}
+
+	// a registration challenge doesn't do for an assertion
+	id, _, err = r.BeginRegistration(ctx, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.FinishAssertion(ctx, admin, id, response); !errors.Is(err, ErrInvalidChallenge) {
+		t.Fatalf("registration challenge: got %v, want ErrInvalidChallenge", err)
+	}
+
+	if _, err := r.FinishAssertion(ctx, admin, "", response); !errors.Is(err, ErrInvalidChallenge) {
+		t.Fatalf("no challenge: got %v, want ErrInvalidChallenge", err)
+	}
+}
+
+// the response must answer its own challenge
+func TestWebAuthnWrongChallenge(t *testing.T) {
+	ctx := context.Background()
+	r := newTestWebAuthnRepository(t)
+	admin := newTestAdmin(1)
+	authenticator := newSoftAuthenticator(t)
+	register(t, r, admin, authenticator)
+
+	_, first, err := r.BeginAssertion(ctx, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _, err := r.BeginAssertion(ctx, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.Counter = 2
+	response := authenticator.Get(t, first)
+	if _, err := r.FinishAssertion(ctx, admin, id, response); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("got %v, want ErrInvalidPasskey", err)
+	}
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/utils"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type webhookRepository struct {
+	DB *gorm.DB
+}
+
+func newWebhookRepository(ds *ds.DataSource) *webhookRepository {
+	return &webhookRepository{
+		DB: ds.DB,
+	}
+}
+
+func (r *webhookRepository) CreateEndpoint(ctx context.Context, endpoint *model.WebhookEndpoint) error {
+	return r.DB.WithContext(ctx).Debug().Create(endpoint).Error
+}
+
+func (r *webhookRepository) ListEndpoints(ctx context.Context, userID uuid.UUID) ([]*model.WebhookEndpoint, error) {
+	endpoints := make([]*model.WebhookEndpoint, 0)
+	err := r.DB.WithContext(ctx).Debug().Where("user_id = ?", userID).Order("created_at DESC").Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (r *webhookRepository) DeleteEndpoint(ctx context.Context, userID uuid.UUID, id string) error {
+	db := r.DB.WithContext(ctx).Debug().Where("id = ? AND user_id = ?", id, userID).Delete(&model.WebhookEndpoint{})
+	if db.Error == nil && db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return db.Error
+}
+
+// Enqueue queues event for every active endpoint of the user subscribed to it.
+// The worker delivers them, so callers don't wait on the endpoints.
+func (r *webhookRepository) Enqueue(ctx context.Context, userID uuid.UUID, event string, data any) error {
+	endpoints := make([]*model.WebhookEndpoint, 0)
+	err := r.DB.WithContext(ctx).Debug().Where("user_id = ? AND active = ?", userID, true).Find(&endpoints).Error
+	if err != nil {
+		return err
+	}
+
+	eventID := uuid.New()
+	now := time.Now()
+	payload, err := json.Marshal(&dto.WebhookPayload{
+		ID:        eventID.String(),
+		Event:     event,
+		CreatedAt: now.Unix(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]*model.WebhookDelivery, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event) {
+			continue
+		}
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       eventID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        model.DeliveryStatusPending,
+			NextAttemptAt: now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.DB.WithContext(ctx).Debug().Create(&deliveries).Error
+}
+
+// ListDue returns pending deliveries whose next attempt is due, with their
+// endpoint even if it was deleted since
+func (r *webhookRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	deliveries := make([]*model.WebhookDelivery, 0)
+	err := r.DB.WithContext(ctx).Debug().
+		Preload("Endpoint", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("status = ? AND next_attempt_at <= ?", model.DeliveryStatusPending, now).
+		Order("next_attempt_at").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Claim pushes the next attempt to until, so another worker doesn't send the
+// same delivery meanwhile. It reports false if someone else claimed it first.
+func (r *webhookRepository) Claim(ctx context.Context, delivery *model.WebhookDelivery, until time.Time) (bool, error) {
+	db := r.DB.WithContext(ctx).Debug().Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, model.DeliveryStatusPending, delivery.NextAttemptAt).
+		Update("next_attempt_at", until)
+	return db.RowsAffected == 1, db.Error
+}
+
+func (r *webhookRepository) MarkDelivered(ctx context.Context, delivery *model.WebhookDelivery, code int, at time.Time) error {
+	return r.DB.WithContext(ctx).Debug().Model(&model.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(map[string]any{
+		"status":        model.DeliveryStatusDelivered,
+		"attempts":      delivery.Attempts + 1,
+		"response_code": code,
+		"last_error":    "",
+		"delivered_at":  at,
+	}).Error
+}
+
+// MarkFailed records a failed attempt, the delivery is retried at next or
+// moved to the dead letters when next is nil
+func (r *webhookRepository) MarkFailed(ctx context.Context, delivery *model.WebhookDelivery, code int, reason string, next *time.Time) error {
+	if len(reason) > 500 {
+		reason = reason[:500]
+	}
+	updates := map[string]any{
+		"attempts":      delivery.Attempts + 1,
+		"response_code": code,
+		"last_error":    reason,
+	}
+	if next != nil {
+		updates["next_attempt_at"] = *next
+	} else {
+		updates["status"] = model.DeliveryStatusDead
+	}
+	return r.DB.WithContext(ctx).Debug().Model(&model.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, userID uuid.UUID, req *dto.WebhookDeliveryListReq) ([]*model.WebhookDelivery, int64, error) {
+	tb := r.DB.WithContext(ctx).Debug().Model(&model.WebhookDelivery{}).
+		Where("endpoint_id IN (?)", r.DB.Unscoped().Model(&model.WebhookEndpoint{}).Select("id").Where("user_id = ?", userID))
+	if req.Status != "" {
+		tb.Where("status = ?", req.Status)
+	}
+	var total int64
+	tb.Count(&total)
+	tb.Scopes(utils.Paginate(req.Page, req.PageSize))
+	list := make([]*model.WebhookDelivery, 0)
+	return list, total, tb.Order("id DESC").Find(&list).Error
+}
+
+// Replay queues a delivery of the user again with a fresh attempt count
+func (r *webhookRepository) Replay(ctx context.Context, userID uuid.UUID, id uint64) error {
+	db := r.DB.WithContext(ctx).Debug().Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status <> ?", id, model.DeliveryStatusPending).
+		Where("endpoint_id IN (?)", r.DB.Model(&model.WebhookEndpoint{}).Select("id").Where("user_id = ?", userID)).
+		Updates(map[string]any{
+			"status":          model.DeliveryStatusPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+			"last_error":      "",
+		})
+	if db.Error == nil && db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return db.Error
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"cryptoshare/ds"
+	"cryptoshare/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type whitelistRepository struct {
+	DB *gorm.DB
+}
+
+func newWhitelistRepository(ds *ds.DataSource) *whitelistRepository {
+	return &whitelistRepository{
+		DB: ds.DB,
+	}
+}
+
+func (r *whitelistRepository) Create(ctx context.Context, address *model.WhitelistAddress) error {
+	return r.DB.WithContext(ctx).Debug().Create(address).Error
+}
+
+// Find returns the entry of address in the user's whitelist, active or not
+func (r *whitelistRepository) Find(ctx context.Context, userID uuid.UUID, network, address string) (*model.WhitelistAddress, error) {
+	entry := model.WhitelistAddress{}
+	err := r.DB.WithContext(ctx).Debug().
+		First(&entry, "user_id = ? AND network = ? AND address = ?", userID, network, address).Error
+	return &entry, err
+}
+
+func (r *whitelistRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*model.WhitelistAddress, error) {
+	addresses := make([]*model.WhitelistAddress, 0)
+	err := r.DB.WithContext(ctx).Debug().Where("user_id = ?", userID).Order("created_at DESC").Find(&addresses).Error
+	return addresses, err
+}
+
+func (r *whitelistRepository) Delete(ctx context.Context, userID uuid.UUID, id string) error {
+	db := r.DB.WithContext(ctx).Debug().Where("id = ? AND user_id = ?", id, userID).Delete(&model.WhitelistAddress{})
+	if db.Error == nil && db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return db.Error
+}
+
+// IsActive reports whether address is whitelisted and past its cool-down
+func (r *whitelistRepository) IsActive(ctx context.Context, userID uuid.UUID, network, address string, now time.Time) (bool, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Debug().Model(&model.WhitelistAddress{}).
+		Where("user_id = ? AND network = ? AND address = ? AND active_at <= ?", userID, network, address, now).
+		Count(&count).Error
+	return count > 0, err
+}
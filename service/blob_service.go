@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInvalidBlobKey = errors.New("invalid blob key")
+
+// BlobStore keeps uploaded files by key. Keys are slash separated paths like
+// "kyc/<user>/<name>", other stores can map them to buckets or prefixes.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewLocalBlobStore keeps blobs as files under dir
+func NewLocalBlobStore(dir string) BlobStore {
+	return &localBlobStore{Dir: dir}
+}
+
+func newBlobStore() BlobStore {
+	return NewLocalBlobStore(conf.BlobDir)
+}
+
+type localBlobStore struct {
+	Dir string
+}
+
+func (s *localBlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	// written to a temp file first so a failed upload never leaves half a blob
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *localBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *localBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// path maps key under Dir, keys that would leave it are refused
+func (s *localBlobStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if key == "" || strings.Contains(key, "..") || clean == "/" {
+		return "", ErrInvalidBlobKey
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
@@ -6,6 +6,7 @@ import (
 	"cryptoshare/conf"
 	"cryptoshare/dto"
 	"cryptoshare/model"
+	"cryptoshare/utils"
 	"cryptoshare/utils/token"
 	"errors"
 	"fmt"
@@ -21,21 +22,36 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"golang.org/x/crypto/sha3"
 )
 
+// ERC20 USDT (Tether) contract
+var usdtContractAddress = common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+
+// default gas limit of an USDT transfer when it can't be estimated
+const usdtTransferGasLimit = uint64(65000)
+
 type erc20Service struct {
 	EtherClient *ethclient.Client
+	// RPCClient is for calls ethclient can't decode, like blocks with newer tx types
+	RPCClient *rpc.Client
+	Price     PriceProvider
+	// Nonces keeps transactions sent back to back from the same address in order
+	Nonces *nonceManager
 }
 
-func newERC20Service() *erc20Service {
-	client, err := ethclient.Dial(fmt.Sprintf("%v/v3/%v", conf.INFURA_BASE_URL, conf.INFURA_API_KEY))
+func newERC20Service(price PriceProvider) *erc20Service {
+	rpcClient, err := rpc.Dial(fmt.Sprintf("%v/v3/%v", conf.INFURA_BASE_URL, conf.INFURA_API_KEY))
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	return &erc20Service{
-		EtherClient: client,
+		EtherClient: ethclient.NewClient(rpcClient),
+		RPCClient:   rpcClient,
+		Price:       price,
+		Nonces:      newNonceManager(),
 	}
 }
 
@@ -98,42 +114,33 @@ func (s *erc20Service) TransferERC20USDT(transferReq *dto.TransferReq) (string,
 	}
 
 	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
-	nonce, err := s.EtherClient.PendingNonceAt(context.Background(), fromAddress)
+	nonce, err := s.Nonces.Next(context.Background(), s.EtherClient, fromAddress)
 	if err != nil {
 		log.Println(err, "Fail Checking Transaction Pending state")
 		return "", err
 	}
+	// hand the nonce back unless the transaction goes out
+	sent := false
+	defer func() {
+		if !sent {
+			s.Nonces.Reset(fromAddress)
+		}
+	}()
 
 	value := big.NewInt(0) // in wei (0 eth)
-	gasPrice, err := s.EtherClient.SuggestGasPrice(context.Background())
+	gasPrice, err := s.gasPrice(transferReq)
 	if err != nil {
 		log.Println(err, "Error gettig suggestion gas price")
 		return "", err
 	}
 
 	toAddress := common.HexToAddress(transferReq.ToAddress)
-	contractAddress := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
-
-	transferFnSignature := []byte("transfer(address,uint256)")
-	hash := sha3.New256()
-	// hash := sha3.NewLegacyKeccak256()
-	hash.Write(transferFnSignature)
-	methodID := hash.Sum(nil)[:4]
-
-	paddedAddress := common.LeftPadBytes(toAddress.Bytes(), 32)
-
-	amount := new(big.Int)
-	amount.SetString(fmt.Sprintf("%v000000", transferReq.Amount), 10) // 1000 tokens
-	paddedAmount := common.LeftPadBytes(amount.Bytes(), 32)
-
-	var data []byte
-	data = append(data, methodID...)
-	data = append(data, paddedAddress...)
-	data = append(data, paddedAmount...)
+	contractAddress := usdtContractAddress
+	data := usdtTransferData(toAddress, utils.ToBaseUnits(transferReq.Amount, 6))
 
 	gasLimit, err := s.EtherClient.EstimateGas(context.Background(), ethereum.CallMsg{
 		From:     fromAddress,
-		To:       &toAddress,
+		To:       &contractAddress,
 		Data:     data,
 		GasPrice: gasPrice,
 		Value:    value,
@@ -163,6 +170,7 @@ func (s *erc20Service) TransferERC20USDT(transferReq *dto.TransferReq) (string,
 		log.Println(err, "Error while sending transaction")
 		return "", err
 	}
+	sent = true
 
 	fmt.Printf("tx sent: %s\n", signedTx.Hash().Hex()) // tx sent: 0xa56316b637a94c4cc0331c73ef26389d6c097506d581073f927275e7a6ece0bc
 	return signedTx.Hash().Hex(), nil
@@ -170,6 +178,22 @@ func (s *erc20Service) TransferERC20USDT(transferReq *dto.TransferReq) (string,
 }
 
 func (s *erc20Service) TransferERC20ETH(transferReq *dto.TransferReq) (string, error) {
+	// leave GasReserveUSDT worth of ETH For gas price
+	ethPrice, err := FreshRate(context.Background(), s.Price, "ETH", "USDT", conf.PriceMaxAge)
+	if err != nil {
+		log.Println(err, "Error getting ETH price")
+		return "", err
+	}
+	transferReq.Amount = transferReq.Amount - conf.GasReserveUSDT/ethPrice
+	if transferReq.Amount <= 0 {
+		log.Printf("Not have %v usdt equal balance of eth\n", conf.GasReserveUSDT)
+		return "", fmt.Errorf("we set the limit at lest eth balance equal to %v USDT for gas price to avoid risky transaction", conf.GasReserveUSDT)
+	}
+	return s.SendETH(transferReq)
+}
+
+// SendETH sends exactly transferReq.Amount ETH, without keeping a gas reserve
+func (s *erc20Service) SendETH(transferReq *dto.TransferReq) (string, error) {
 	privateKey, err := crypto.HexToECDSA(transferReq.PrivateKey)
 	if err != nil {
 		log.Println(err, "Error parsing HexToECDSA")
@@ -186,21 +210,9 @@ func (s *erc20Service) TransferERC20ETH(transferReq *dto.TransferReq) (string, e
 	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
 	log.Println("Address B ", fromAddress)
 
-	nonce, err := s.EtherClient.PendingNonceAt(context.Background(), fromAddress)
-	if err != nil {
-		log.Println(err, "Fail Checking Transaction Pending state")
-		return "", err
-	}
-
-	// leave 5 USDT Amount of ETH For gas price
-	transferReq.Amount = transferReq.Amount - 0.0042
-	if transferReq.Amount <= 0 {
-		log.Println("Not have 5 usdt equal balance of eth")
-		return "", errors.New("we set the limit at lest eth balance equal to 5 USDT for gas price to avoid risky transaction")
-	}
-	value := big.NewInt(int64(transferReq.Amount * 1000000000000000000)) // in wei (1 eth) 1000000000000000000 = 1 eth
+	value := utils.ToBaseUnits(transferReq.Amount, 18) // in wei (1 eth) 1000000000000000000 = 1 eth
 	gasLimit := uint64(21000)
-	gasPrice, err := s.EtherClient.SuggestGasPrice(context.Background())
+	gasPrice, err := s.gasPrice(transferReq)
 	if err != nil {
 		log.Println(err, "Error gettig suggestion gas price")
 		return "", err
@@ -217,14 +229,27 @@ func (s *erc20Service) TransferERC20ETH(transferReq *dto.TransferReq) (string, e
 		return "", errors.New("not enough ETH Balance")
 	}
 
-	tx := types.NewTransaction(nonce, toAddress, value, gasLimit, gasPrice, data)
-
 	chainID, err := s.EtherClient.NetworkID(context.Background())
 	if err != nil {
 		log.Println(err, "Error getting network ID")
-		return "", nil
+		return "", err
 	}
 
+	nonce, err := s.Nonces.Next(context.Background(), s.EtherClient, fromAddress)
+	if err != nil {
+		log.Println(err, "Fail Checking Transaction Pending state")
+		return "", err
+	}
+	// hand the nonce back unless the transaction goes out
+	sent := false
+	defer func() {
+		if !sent {
+			s.Nonces.Reset(fromAddress)
+		}
+	}()
+
+	tx := types.NewTransaction(nonce, toAddress, value, gasLimit, gasPrice, data)
+
 	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
 	if err != nil {
 		log.Println(err, "Error While signing transaction")
@@ -236,6 +261,7 @@ func (s *erc20Service) TransferERC20ETH(transferReq *dto.TransferReq) (string, e
 		log.Println(err, "Error while sending transaction")
 		return "", err
 	}
+	sent = true
 
 	fmt.Printf("tx sent: %s\n", signedTx.Hash().Hex())
 	return signedTx.Hash().Hex(), nil
@@ -379,3 +405,334 @@ func (s *erc20Service) ERC20CheckTransactionStatus(txID string) (*dto.ERC20Trans
 	return res, nil
 
 }
+
+// EstimateFee returns the gas needed to send amount of currency from one
+// address to another, priced for each fee tier.
+func (s *erc20Service) EstimateFee(currency, from, to string, amount float64) (*FeeEstimate, error) {
+	gasPrice, err := s.EtherClient.SuggestGasPrice(context.Background())
+	if err != nil {
+		log.Println(err, "Error gettig suggestion gas price")
+		return nil, err
+	}
+
+	gasLimit := uint64(21000)
+	if currency == "USDT" {
+		gasLimit, err = s.EtherClient.EstimateGas(context.Background(), ethereum.CallMsg{
+			From: common.HexToAddress(from),
+			To:   &usdtContractAddress,
+			Data: usdtTransferData(common.HexToAddress(to), utils.ToBaseUnits(amount, 6)),
+		})
+		// estimation reverts when the sender can't cover the amount yet
+		if err != nil {
+			log.Println(err, "Error estimating gas, using default limit")
+			gasLimit = usdtTransferGasLimit
+		}
+	}
+
+	return &FeeEstimate{
+		NativeCurrency: "ETH",
+		Decimals:       18,
+		GasLimit:       gasLimit,
+		GasPrices:      tieredPrices(gasPrice),
+	}, nil
+}
+
+// gasPrice uses the price locked by a fee quote when there is one
+func (s *erc20Service) gasPrice(transferReq *dto.TransferReq) (*big.Int, error) {
+	if transferReq.GasPrice != "" {
+		gasPrice, ok := new(big.Int).SetString(transferReq.GasPrice, 10)
+		if !ok {
+			return nil, errors.New("invalid gas price")
+		}
+		return gasPrice, nil
+	}
+	return s.EtherClient.SuggestGasPrice(context.Background())
+}
+
+func usdtTransferData(to common.Address, amount *big.Int) []byte {
+	transferFnSignature := []byte("transfer(address,uint256)")
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(transferFnSignature)
+	methodID := hash.Sum(nil)[:4]
+
+	var data []byte
+	data = append(data, methodID...)
+	data = append(data, common.LeftPadBytes(to.Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(amount.Bytes(), 32)...)
+	return data
+}
+
+// GetTransaction returns a transaction by hash along with its sender.
+func (s *erc20Service) GetTransaction(txHash string) (*types.Transaction, string, error) {
+	ctx := context.Background()
+	tx, _, err := s.EtherClient.TransactionByHash(ctx, common.HexToHash(txHash))
+	if err != nil {
+		log.Println(err, "Error getting transaction")
+		return nil, "", err
+	}
+
+	chainID, err := s.EtherClient.NetworkID(ctx)
+	if err != nil {
+		log.Println(err, "Error getting network ID")
+		return nil, "", err
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+	if err != nil {
+		return nil, "", err
+	}
+	return tx, sender.Hex(), nil
+}
+
+// MinReplacementBump is the minimum fee increase, in percent, that nodes accept
+// for a transaction that replaces a pending one with the same nonce.
+const MinReplacementBump = 10
+
+// ReplaceTransaction re-signs a pending transaction with the same nonce and a
+// bumped fee. When cancel is true the replacement is a zero value transfer to
+// the sender itself, which drops the original once it is mined.
+func (s *erc20Service) ReplaceTransaction(hexPrivateKey, txHash string, bumpPercent uint64, cancel bool) (*types.Transaction, error) {
+	if bumpPercent < MinReplacementBump {
+		bumpPercent = MinReplacementBump
+	}
+
+	privateKey, err := crypto.HexToECDSA(hexPrivateKey)
+	if err != nil {
+		log.Println(err, "Error parsing HexToECDSA")
+		return nil, err
+	}
+	fromAddress := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	ctx := context.Background()
+	original, isPending, err := s.EtherClient.TransactionByHash(ctx, common.HexToHash(txHash))
+	if err != nil {
+		log.Println(err, "Error getting transaction")
+		return nil, err
+	}
+	if !isPending {
+		return nil, errors.New("transaction is already mined")
+	}
+
+	chainID, err := s.EtherClient.NetworkID(ctx)
+	if err != nil {
+		log.Println(err, "Error getting network ID")
+		return nil, err
+	}
+	signer := types.LatestSignerForChainID(chainID)
+
+	sender, err := types.Sender(signer, original)
+	if err != nil {
+		return nil, err
+	}
+	if sender != fromAddress {
+		return nil, errors.New("private key does not belong to the transaction sender")
+	}
+
+	to := original.To()
+	value := original.Value()
+	data := original.Data()
+	gasLimit := original.Gas()
+	if cancel {
+		to = &fromAddress
+		value = big.NewInt(0)
+		data = nil
+		gasLimit = 21000
+	}
+
+	var replacement *types.Transaction
+	if original.Type() == types.DynamicFeeTxType {
+		suggestedTip, err := s.EtherClient.SuggestGasTipCap(ctx)
+		if err != nil {
+			log.Println(err, "Error getting suggestion gas tip")
+			return nil, err
+		}
+		tipCap := maxBig(bumpFee(original.GasTipCap(), bumpPercent), suggestedTip)
+		feeCap := bumpFee(original.GasFeeCap(), bumpPercent)
+		if feeCap.Cmp(tipCap) < 0 {
+			feeCap = tipCap
+		}
+		replacement = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     original.Nonce(),
+			GasTipCap: tipCap,
+			GasFeeCap: feeCap,
+			Gas:       gasLimit,
+			To:        to,
+			Value:     value,
+			Data:      data,
+		})
+	} else {
+		suggestedPrice, err := s.EtherClient.SuggestGasPrice(ctx)
+		if err != nil {
+			log.Println(err, "Error gettig suggestion gas price")
+			return nil, err
+		}
+		gasPrice := maxBig(bumpFee(original.GasPrice(), bumpPercent), suggestedPrice)
+		replacement = types.NewTransaction(original.Nonce(), *to, value, gasLimit, gasPrice, data)
+	}
+
+	signedTx, err := types.SignTx(replacement, signer, privateKey)
+	if err != nil {
+		log.Println(err, "Error While signing transaction")
+		return nil, err
+	}
+
+	if err := s.EtherClient.SendTransaction(ctx, signedTx); err != nil {
+		log.Println(err, "Error while sending transaction")
+		return nil, err
+	}
+
+	log.Println("replacement tx sent: ", signedTx.Hash().Hex())
+	return signedTx, nil
+}
+
+// bumpFee raises fee by percent, rounding up so the result never falls short
+// of the node's replacement threshold.
+func bumpFee(fee *big.Int, percent uint64) *big.Int {
+	bumped := new(big.Int).Mul(fee, new(big.Int).SetUint64(100+percent))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// transferTopic is the keccak256 of the ERC20 Transfer(address,address,uint256) event
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// ChainTransfer is an incoming transfer found on chain, LogIndex is
+// model.DepositNativeLogIndex for native coin transfers
+type ChainTransfer struct {
+	TxHash      string
+	LogIndex    int
+	Currency    string
+	FromAddress string
+	ToAddress   string
+	Amount      float64
+	BlockNumber uint64
+	BlockTime   time.Time
+}
+
+func (s *erc20Service) BlockNumber() (uint64, error) {
+	return s.EtherClient.BlockNumber(context.Background())
+}
+
+type rpcBlock struct {
+	Timestamp    hexutil.Uint64 `json:"timestamp"`
+	Transactions []struct {
+		Hash  common.Hash     `json:"hash"`
+		From  common.Address  `json:"from"`
+		To    *common.Address `json:"to"`
+		Value *hexutil.Big    `json:"value"`
+	} `json:"transactions"`
+}
+
+// ScanTransfers returns the ETH and USDT transfers into addresses in the
+// blocks from fromBlock to toBlock, both included.
+func (s *erc20Service) ScanTransfers(fromBlock, toBlock uint64, addresses []string) ([]*ChainTransfer, error) {
+	transfers := make([]*ChainTransfer, 0)
+	// an empty topic list would match transfers to anyone
+	if len(addresses) == 0 {
+		return transfers, nil
+	}
+
+	ctx := context.Background()
+	watched := map[common.Address]bool{}
+	toTopics := make([]common.Hash, 0, len(addresses))
+	for _, address := range addresses {
+		account := common.HexToAddress(address)
+		watched[account] = true
+		toTopics = append(toTopics, common.BytesToHash(account.Bytes()))
+	}
+
+	// ETH transfers from the block transactions, the blocks also give the
+	// time of the logs
+	blockTimes := map[uint64]time.Time{}
+	for number := fromBlock; number <= toBlock; number++ {
+		block := rpcBlock{}
+		err := s.RPCClient.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeUint64(number), true)
+		if err != nil {
+			log.Println(err, "Error getting block ", number)
+			return nil, err
+		}
+		blockTimes[number] = time.Unix(int64(block.Timestamp), 0)
+		for _, tx := range block.Transactions {
+			if tx.To == nil || !watched[*tx.To] || tx.Value == nil || tx.Value.ToInt().Sign() == 0 {
+				continue
+			}
+			transfers = append(transfers, &ChainTransfer{
+				TxHash:      tx.Hash.Hex(),
+				LogIndex:    model.DepositNativeLogIndex,
+				Currency:    "ETH",
+				FromAddress: tx.From.Hex(),
+				ToAddress:   tx.To.Hex(),
+				Amount:      utils.FromBaseUnits(tx.Value.ToInt(), 18),
+				BlockNumber: number,
+				BlockTime:   blockTimes[number],
+			})
+		}
+	}
+
+	// USDT transfers from the token's Transfer events
+	logs, err := s.EtherClient.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		ToBlock:   new(big.Int).SetUint64(toBlock),
+		Addresses: []common.Address{usdtContractAddress},
+		Topics:    [][]common.Hash{{transferTopic}, nil, toTopics},
+	})
+	if err != nil {
+		log.Println(err, "Error filtering transfer logs")
+		return nil, err
+	}
+	for _, vLog := range logs {
+		if vLog.Removed || len(vLog.Topics) != 3 {
+			continue
+		}
+		transfers = append(transfers, &ChainTransfer{
+			TxHash:      vLog.TxHash.Hex(),
+			LogIndex:    int(vLog.Index),
+			Currency:    "USDT",
+			FromAddress: common.BytesToAddress(vLog.Topics[1].Bytes()).Hex(),
+			ToAddress:   common.BytesToAddress(vLog.Topics[2].Bytes()).Hex(),
+			Amount:      utils.FromBaseUnits(new(big.Int).SetBytes(vLog.Data), 6),
+			BlockNumber: vLog.BlockNumber,
+			BlockTime:   blockTimes[vLog.BlockNumber],
+		})
+	}
+
+	return transfers, nil
+}
+
+// TransferInChain looks a scanned transfer up again in the canonical chain.
+// It returns the block the transaction is in now, or false when it is gone,
+// reverted or no longer has the transfer log.
+func (s *erc20Service) TransferInChain(txHash string, logIndex int) (uint64, bool, error) {
+	receipt, err := s.EtherClient.TransactionReceipt(context.Background(), common.HexToHash(txHash))
+	if errors.Is(err, ethereum.NotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return 0, false, nil
+	}
+	if logIndex != model.DepositNativeLogIndex {
+		found := false
+		for _, vLog := range receipt.Logs {
+			if int(vLog.Index) == logIndex && !vLog.Removed && vLog.Address == usdtContractAddress &&
+				len(vLog.Topics) == 3 && vLog.Topics[0] == transferTopic {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false, nil
+		}
+	}
+	return receipt.BlockNumber.Uint64(), true, nil
+}
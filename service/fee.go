@@ -0,0 +1,47 @@
+package service
+
+import (
+	"math/big"
+)
+
+const (
+	FeeTierSlow   = "slow"
+	FeeTierNormal = "normal"
+	FeeTierFast   = "fast"
+)
+
+// feeTierPercents scales the suggested gas price for each tier
+var feeTierPercents = map[string]int64{
+	FeeTierSlow:   90,
+	FeeTierNormal: 100,
+	FeeTierFast:   125,
+}
+
+type FeeEstimate struct {
+	// NativeCurrency is the coin the fee is paid in (ETH or TRX)
+	NativeCurrency string
+	// Decimals of NativeCurrency, 18 for wei and 6 for sun
+	Decimals int
+	// GasLimit is gas for ERC20 and energy or bandwidth for TRC20
+	GasLimit uint64
+	// GasPrices holds the price per GasLimit unit for every tier
+	GasPrices map[string]*big.Int
+}
+
+// Fee returns the total fee of a tier in the smallest native unit
+func (e *FeeEstimate) Fee(tier string) *big.Int {
+	price, ok := e.GasPrices[tier]
+	if !ok {
+		return nil
+	}
+	return new(big.Int).Mul(price, new(big.Int).SetUint64(e.GasLimit))
+}
+
+func tieredPrices(suggested *big.Int) map[string]*big.Int {
+	prices := make(map[string]*big.Int, len(feeTierPercents))
+	for tier, percent := range feeTierPercents {
+		price := new(big.Int).Mul(suggested, big.NewInt(percent))
+		prices[tier] = price.Div(price, big.NewInt(100))
+	}
+	return prices
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"cryptoshare/conf"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mailer sends plain text emails to users
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// newMailer picks the mailer of conf.MailDriver. Emails carry reset links,
+// so the file and log mailers are only used when asked for and a missing
+// setting stops the app instead of falling back to them.
+func newMailer() Mailer {
+	switch conf.MailDriver {
+	case conf.MailDriverSMTP:
+		if conf.SMTPHost == "" {
+			log.Fatal("SMTP_HOST is required with MAIL_DRIVER=smtp")
+		}
+		return &smtpMailer{
+			Addr: fmt.Sprintf("%s:%d", conf.SMTPHost, conf.SMTPPort),
+			Auth: smtp.PlainAuth("", conf.SMTPUsername, conf.SMTPPassword, conf.SMTPHost),
+			From: conf.MailFrom,
+		}
+	case conf.MailDriverFile:
+		if conf.MailDir == "" {
+			log.Fatal("MAIL_DIR is required with MAIL_DRIVER=file")
+		}
+		return &fileMailer{Dir: conf.MailDir}
+	case conf.MailDriverLog:
+		log.Println("MAIL_DRIVER=log, emails are written to the log and not sent")
+		return &logMailer{}
+	}
+	log.Fatalf("MAIL_DRIVER must be %s, %s or %s, got %q", conf.MailDriverSMTP, conf.MailDriverFile, conf.MailDriverLog, conf.MailDriver)
+	return nil
+}
+
+type smtpMailer struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{to}, mailMessage(m.From, to, subject, body))
+}
+
+// fileMailer writes every email to its own .eml file under Dir, so local
+// tests can pick the links out of them
+type fileMailer struct {
+	Dir string
+}
+
+func (m *fileMailer) Send(to, subject, body string) error {
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.NewReplacer("/", "_", "\\", "_").Replace(to))
+	return os.WriteFile(filepath.Join(m.Dir, name), mailMessage(conf.MailFrom, to, subject, body), 0o600)
+}
+
+type logMailer struct{}
+
+func (m *logMailer) Send(to, subject, body string) error {
+	log.Printf("mail to %s: %s\n%s\n", to, subject, body)
+	return nil
+}
+
+func mailMessage(from, to, subject, body string) []byte {
+	return []byte(strings.Join([]string{
+		"From: " + from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n"))
+}
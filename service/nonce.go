@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// nonceManager hands out consecutive nonces per sender, so transactions sent
+// back to back don't get the same pending nonce before the node has seen the
+// previous one
+type nonceManager struct {
+	mu   sync.Mutex
+	next map[common.Address]uint64
+}
+
+func newNonceManager() *nonceManager {
+	return &nonceManager{
+		next: map[common.Address]uint64{},
+	}
+}
+
+// Next returns the nonce for the next transaction of address, the higher of
+// the node's pending nonce and the one after the last handed out
+func (m *nonceManager) Next(ctx context.Context, client *ethclient.Client, address common.Address) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending, err := client.PendingNonceAt(ctx, address)
+	if err != nil {
+		return 0, err
+	}
+	nonce := pending
+	if next, ok := m.next[address]; ok && next > nonce {
+		nonce = next
+	}
+	m.next[address] = nonce + 1
+	return nonce, nil
+}
+
+// Reset forgets address, used when a transaction was not sent so its nonce
+// is taken from the node again
+func (m *nonceManager) Reset(address common.Address) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.next, address)
+}
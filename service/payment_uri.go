@@ -0,0 +1,26 @@
+package service
+
+import (
+	"cryptoshare/utils"
+	"fmt"
+	"strconv"
+)
+
+// PaymentURI builds the uri wallets open from a QR code. ERC20 follows
+// EIP-681, Tron has no standard so the common tron:<address>?amount= form is
+// used with the token contract for USDT.
+func PaymentURI(network, currency, address string, amount float64) string {
+	if network == "TRC20" {
+		uri := fmt.Sprintf("tron:%s?amount=%s", address, strconv.FormatFloat(amount, 'f', -1, 64))
+		if currency == "USDT" {
+			uri += "&token=" + usdtTRC20ContractAddress
+		}
+		return uri
+	}
+
+	if currency == "USDT" {
+		return fmt.Sprintf("ethereum:%s@1/transfer?address=%s&uint256=%s",
+			usdtContractAddress.Hex(), address, utils.ToBaseUnits(amount, 6).String())
+	}
+	return fmt.Sprintf("ethereum:%s@1?value=%s", address, utils.ToBaseUnits(amount, 18).String())
+}
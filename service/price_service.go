@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"cryptoshare/dto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+var (
+	ErrPriceNotFound = errors.New("price is not available")
+	ErrStalePrice    = errors.New("price is too old, try again later")
+)
+
+type Price struct {
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Rate      float64   `json:"rate"`
+	Source    string    `json:"source"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// PriceProvider converts between currencies, Rate of a Price is how much one
+// base is worth in quote.
+type PriceProvider interface {
+	Price(ctx context.Context, base, quote string) (*Price, error)
+}
+
+// FreshRate is for fee-sensitive operations, it fails with ErrStalePrice
+// instead of using a price older than maxAge.
+func FreshRate(ctx context.Context, p PriceProvider, base, quote string, maxAge time.Duration) (float64, error) {
+	price, err := p.Price(ctx, base, quote)
+	if err != nil {
+		return 0, err
+	}
+	if time.Since(price.FetchedAt) > maxAge {
+		return 0, ErrStalePrice
+	}
+	return price.Rate, nil
+}
+
+// ValueHoldings sets the price and value of every holding in quote
+func ValueHoldings(ctx context.Context, p PriceProvider, holdings []*dto.PortfolioAsset, quote string) error {
+	rates := map[string]float64{}
+	for _, holding := range holdings {
+		rate, ok := rates[holding.Currency]
+		if !ok {
+			price, err := p.Price(ctx, holding.Currency, quote)
+			if err != nil {
+				return err
+			}
+			rate = price.Rate
+			rates[holding.Currency] = rate
+		}
+		holding.Price = rate
+		holding.Value = holding.Balance * rate
+	}
+	return nil
+}
+
+// StaticPriceProvider serves fixed rates. It is used for manual overrides and
+// lets tests inject rates without a network.
+type StaticPriceProvider struct {
+	mu    sync.RWMutex
+	rates map[string]float64
+	// fetchedAt dates the prices, they are always fresh while it is zero
+	fetchedAt time.Time
+}
+
+func NewStaticPriceProvider(rates map[string]float64) *StaticPriceProvider {
+	p := &StaticPriceProvider{rates: map[string]float64{}}
+	for pair, rate := range rates {
+		p.rates[strings.ToUpper(pair)] = rate
+	}
+	return p
+}
+
+// Set overrides the rate of base/quote
+func (p *StaticPriceProvider) Set(base, quote string, rate float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rates[pairKey(base, quote)] = rate
+}
+
+// SetFetchedAt dates every price at, so tests can serve old prices
+func (p *StaticPriceProvider) SetFetchedAt(at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetchedAt = at
+}
+
+func (p *StaticPriceProvider) Price(ctx context.Context, base, quote string) (*Price, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	rate, ok := p.rates[pairKey(base, quote)]
+	if !ok {
+		// fall back to the inverse pair, ETH/USDT answers USDT/ETH too
+		inverse, ok := p.rates[pairKey(quote, base)]
+		if !ok || inverse == 0 {
+			if base != quote {
+				return nil, ErrPriceNotFound
+			}
+			inverse = 1
+		}
+		rate = 1 / inverse
+	}
+	fetchedAt := p.fetchedAt
+	if fetchedAt.IsZero() {
+		fetchedAt = time.Now()
+	}
+
+	return &Price{
+		Base:      base,
+		Quote:     quote,
+		Rate:      rate,
+		Source:    "static",
+		FetchedAt: fetchedAt,
+	}, nil
+}
+
+// coinGeckoIDs maps our currencies to coingecko coin ids
+var coinGeckoIDs = map[string]string{
+	"ETH":  "ethereum",
+	"TRX":  "tron",
+	"USDT": "tether",
+}
+
+// httpPriceProvider reads prices from a coingecko compatible api
+type httpPriceProvider struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func newHTTPPriceProvider(baseURL string) *httpPriceProvider {
+	return &httpPriceProvider{
+		BaseURL: baseURL,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *httpPriceProvider) Price(ctx context.Context, base, quote string) (*Price, error) {
+	baseID, ok := coinGeckoIDs[base]
+	if !ok {
+		return nil, ErrPriceNotFound
+	}
+
+	var rate float64
+	if quoteID, ok := coinGeckoIDs[quote]; ok {
+		// crypto quotes are crossed through usd
+		prices, err := p.fetch(ctx, []string{baseID, quoteID}, "usd")
+		if err != nil {
+			return nil, err
+		}
+		if prices[quoteID]["usd"] == 0 {
+			return nil, ErrPriceNotFound
+		}
+		rate = prices[baseID]["usd"] / prices[quoteID]["usd"]
+	} else {
+		vs := strings.ToLower(quote)
+		prices, err := p.fetch(ctx, []string{baseID}, vs)
+		if err != nil {
+			return nil, err
+		}
+		rate = prices[baseID][vs]
+	}
+	if rate == 0 {
+		return nil, ErrPriceNotFound
+	}
+
+	return &Price{
+		Base:      base,
+		Quote:     quote,
+		Rate:      rate,
+		Source:    "http",
+		FetchedAt: time.Now(),
+	}, nil
+}
+
+func (p *httpPriceProvider) fetch(ctx context.Context, ids []string, vs string) (map[string]map[string]float64, error) {
+	query := url.Values{}
+	query.Set("ids", strings.Join(ids, ","))
+	query.Set("vs_currencies", vs)
+	reqUrl := fmt.Sprintf("%s/api/v3/simple/price?%s", p.BaseURL, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price source responded with %s", res.Status)
+	}
+
+	prices := map[string]map[string]float64{}
+	return prices, json.NewDecoder(res.Body).Decode(&prices)
+}
+
+// cachedPriceProvider keeps prices in redis. A price is refreshed once it is
+// older than TTL, and the last known price is served while the source is down
+// so callers can decide with FreshRate whether it is still usable.
+type cachedPriceProvider struct {
+	RDB    *redis.Client
+	Source PriceProvider
+	TTL    time.Duration
+}
+
+// keep the last known price long enough to outlive a source outage
+const priceCacheRetention = 7 * 24 * time.Hour
+
+func (p *cachedPriceProvider) Price(ctx context.Context, base, quote string) (*Price, error) {
+	key := "price:" + pairKey(base, quote)
+
+	var cached *Price
+	if data, err := p.RDB.Get(ctx, key).Bytes(); err == nil {
+		cached = &Price{}
+		if err := json.Unmarshal(data, cached); err != nil {
+			cached = nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		log.Println(err, "Error reading cached price")
+	}
+	if cached != nil && time.Since(cached.FetchedAt) < p.TTL {
+		return cached, nil
+	}
+
+	price, err := p.Source.Price(ctx, base, quote)
+	if err != nil {
+		log.Println(err, "Error fetching price for ", key)
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	if data, err := json.Marshal(price); err == nil {
+		if err := p.RDB.Set(ctx, key, data, priceCacheRetention).Err(); err != nil {
+			log.Println(err, "Error caching price")
+		}
+	}
+	return price, nil
+}
+
+// overridePriceProvider answers from manual overrides first
+type overridePriceProvider struct {
+	Overrides *StaticPriceProvider
+	Source    PriceProvider
+}
+
+func (p *overridePriceProvider) Price(ctx context.Context, base, quote string) (*Price, error) {
+	price, err := p.Overrides.Price(ctx, base, quote)
+	if err == nil {
+		return price, nil
+	}
+	return p.Source.Price(ctx, base, quote)
+}
+
+func pairKey(base, quote string) string {
+	return strings.ToUpper(base) + "/" + strings.ToUpper(quote)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v9"
+)
+
+// countingPriceProvider counts the calls reaching the source, Err makes the
+// source look down
+type countingPriceProvider struct {
+	Source PriceProvider
+	Calls  int
+	Err    error
+}
+
+func (p *countingPriceProvider) Price(ctx context.Context, base, quote string) (*Price, error) {
+	p.Calls++
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	return p.Source.Price(ctx, base, quote)
+}
+
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+// ageCachedPrice backdates the cached price of base/quote by age
+func ageCachedPrice(t *testing.T, p *cachedPriceProvider, base, quote string, age time.Duration) {
+	t.Helper()
+	ctx := context.Background()
+	key := "price:" + pairKey(base, quote)
+	price := Price{}
+	data, err := p.RDB.Get(ctx, key).Bytes()
+	if err == nil {
+		err = json.Unmarshal(data, &price)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	price.FetchedAt = time.Now().Add(-age)
+	data, _ = json.Marshal(&price)
+	if err := p.RDB.Set(ctx, key, data, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFreshRate(t *testing.T) {
+	ctx := context.Background()
+	static := NewStaticPriceProvider(map[string]float64{"ETH/USDT": 2000})
+
+	rate, err := FreshRate(ctx, static, "ETH", "USDT", time.Minute)
+	if err != nil || rate != 2000 {
+		t.Fatalf("fresh price: got %v, %v", rate, err)
+	}
+	rate, err = FreshRate(ctx, static, "USDT", "ETH", time.Minute)
+	if err != nil || rate != 1.0/2000 {
+		t.Fatalf("inverse price: got %v, %v", rate, err)
+	}
+	if _, err := FreshRate(ctx, static, "TRX", "USDT", time.Minute); !errors.Is(err, ErrPriceNotFound) {
+		t.Fatalf("missing price: got %v, want ErrPriceNotFound", err)
+	}
+
+	static.SetFetchedAt(time.Now().Add(-2 * time.Minute))
+	if _, err := FreshRate(ctx, static, "ETH", "USDT", time.Minute); !errors.Is(err, ErrStalePrice) {
+		t.Fatalf("stale price: got %v, want ErrStalePrice", err)
+	}
+}
+
+func TestCachedPriceProvider(t *testing.T) {
+	ctx := context.Background()
+	static := NewStaticPriceProvider(map[string]float64{"ETH/USDT": 2000})
+	source := &countingPriceProvider{Source: static}
+	cached := &cachedPriceProvider{RDB: newTestRedis(t), Source: source, TTL: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		price, err := cached.Price(ctx, "ETH", "USDT")
+		if err != nil || price.Rate != 2000 {
+			t.Fatalf("call %d: got %v, %v", i, price, err)
+		}
+	}
+	if source.Calls != 1 {
+		t.Fatalf("a fresh cached price should be served, source called %d times", source.Calls)
+	}
+
+	// a cached price older than TTL is refreshed
+	ageCachedPrice(t, cached, "ETH", "USDT", 2*time.Minute)
+	static.Set("ETH", "USDT", 2100)
+	price, err := cached.Price(ctx, "ETH", "USDT")
+	if err != nil || price.Rate != 2100 || source.Calls != 2 {
+		t.Fatalf("old price not refreshed: got %v, %v after %d calls", price, err, source.Calls)
+	}
+
+	// with the source down the last price is served, but FreshRate refuses it
+	ageCachedPrice(t, cached, "ETH", "USDT", 2*time.Minute)
+	source.Err = errors.New("source is down")
+	price, err = cached.Price(ctx, "ETH", "USDT")
+	if err != nil || price.Rate != 2100 {
+		t.Fatalf("last known price not served: got %v, %v", price, err)
+	}
+	if _, err := FreshRate(ctx, cached, "ETH", "USDT", time.Minute); !errors.Is(err, ErrStalePrice) {
+		t.Fatalf("got %v, want ErrStalePrice", err)
+	}
+
+	// without a cached price the source error is returned
+	if _, err := cached.Price(ctx, "TRX", "USDT"); err == nil {
+		t.Fatal("expected the source error for an uncached pair")
+	}
+}
+
+func TestOverridePriceProvider(t *testing.T) {
+	ctx := context.Background()
+	overrides := NewStaticPriceProvider(map[string]float64{"eth/usdt": 1500})
+	source := &countingPriceProvider{Source: NewStaticPriceProvider(map[string]float64{"ETH/USDT": 2000, "TRX/USDT": 0.06})}
+	p := &overridePriceProvider{Overrides: overrides, Source: source}
+
+	price, err := p.Price(ctx, "ETH", "USDT")
+	if err != nil || price.Rate != 1500 || source.Calls != 0 {
+		t.Fatalf("override not used: got %v, %v", price, err)
+	}
+	price, err = p.Price(ctx, "TRX", "USDT")
+	if err != nil || price.Rate != 0.06 || source.Calls != 1 {
+		t.Fatalf("source not used: got %v, %v", price, err)
+	}
+}
+
+// the eth gas reserve is a fee check, it must not use a stale price
+func TestTransferERC20ETHRejectsStalePrice(t *testing.T) {
+	static := NewStaticPriceProvider(map[string]float64{"ETH/USDT": 2000})
+	static.SetFetchedAt(time.Now().Add(-conf.PriceMaxAge - time.Minute))
+	s := &erc20Service{Price: static}
+
+	_, err := s.TransferERC20ETH(&dto.TransferReq{Amount: 1})
+	if !errors.Is(err, ErrStalePrice) {
+		t.Fatalf("got %v, want ErrStalePrice", err)
+	}
+}
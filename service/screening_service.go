@@ -0,0 +1,204 @@
+package service
+
+import (
+	"cryptoshare/conf"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScreeningEntry is a listed address, List is the file it came from
+type ScreeningEntry struct {
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+	List    string `json:"list"`
+}
+
+// ScreeningStatus describes the loaded denylists
+type ScreeningStatus struct {
+	Files    []string   `json:"files"`
+	Entries  int        `json:"entries"`
+	LoadedAt *time.Time `json:"loaded_at"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Screener checks addresses against denylists read from local CSV or JSON
+// files. Every process keeps its own copy and reads the files again once it
+// is older than the refresh interval. A list that fails to load keeps the
+// previous one, so a broken file never empties the denylist.
+type Screener struct {
+	Files   []string
+	Refresh time.Duration
+
+	// reloading lets one caller refresh the stale lists, the others keep
+	// checking against the current ones
+	reloading sync.Mutex
+
+	mu       sync.RWMutex
+	entries  map[string]*ScreeningEntry
+	loadedAt time.Time
+	loadErr  error
+}
+
+// NewScreener loads the lists once, it fails when one of them cannot be read
+// so nothing is ever checked against an empty denylist by mistake
+func NewScreener(files []string, refresh time.Duration) (*Screener, error) {
+	s := &Screener{
+		Files:   files,
+		Refresh: refresh,
+		entries: map[string]*ScreeningEntry{},
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newScreener stops the app when the lists cannot be loaded, screening fails
+// closed
+func newScreener() *Screener {
+	s, err := NewScreener(conf.ScreeningFiles, conf.ScreeningRefresh)
+	if err != nil {
+		log.Fatal(err, " Error loading screening lists")
+	}
+	return s
+}
+
+// Check returns the entry of address when it is listed. Hex addresses are
+// compared without case, others exactly.
+func (s *Screener) Check(address string) *ScreeningEntry {
+	s.reloadIfStale()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.entries[screeningKey(address)]
+}
+
+// Reload reads every file again and swaps the lists in one go
+func (s *Screener) Reload() error {
+	entries := map[string]*ScreeningEntry{}
+	var err error
+	for _, file := range s.Files {
+		if err = loadScreeningFile(file, entries); err != nil {
+			err = fmt.Errorf("%s: %w", file, err)
+			break
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = time.Now()
+	s.loadErr = err
+	if err != nil {
+		return err
+	}
+	s.entries = entries
+	return nil
+}
+
+func (s *Screener) Status() *ScreeningStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := &ScreeningStatus{
+		Files:   s.Files,
+		Entries: len(s.entries),
+	}
+	if !s.loadedAt.IsZero() {
+		loadedAt := s.loadedAt
+		status.LoadedAt = &loadedAt
+	}
+	if s.loadErr != nil {
+		status.Error = s.loadErr.Error()
+	}
+	return status
+}
+
+func (s *Screener) reloadIfStale() {
+	s.mu.RLock()
+	stale := time.Since(s.loadedAt) >= s.Refresh
+	s.mu.RUnlock()
+	if !stale || !s.reloading.TryLock() {
+		return
+	}
+	defer s.reloading.Unlock()
+	if err := s.Reload(); err != nil {
+		log.Println(err, "Error reloading screening lists")
+	}
+}
+
+func screeningKey(address string) string {
+	address = strings.TrimSpace(address)
+	if strings.HasPrefix(address, "0x") || strings.HasPrefix(address, "0X") {
+		return strings.ToLower(address)
+	}
+	return address
+}
+
+// loadScreeningFile adds the addresses of a .json or .csv file to entries.
+// JSON is an array of addresses or of {"address", "reason"} objects, CSV has
+// the address in the first column and an optional reason in the second.
+func loadScreeningFile(file string, entries map[string]*ScreeningEntry) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	list := filepath.Base(file)
+	add := func(address, reason string) {
+		key := screeningKey(address)
+		if key == "" {
+			return
+		}
+		entries[key] = &ScreeningEntry{Address: strings.TrimSpace(address), Reason: reason, List: list}
+	}
+
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		raw := []json.RawMessage{}
+		if err := json.NewDecoder(f).Decode(&raw); err != nil {
+			return err
+		}
+		for _, item := range raw {
+			var address string
+			if json.Unmarshal(item, &address) == nil {
+				add(address, "")
+				continue
+			}
+			entry := ScreeningEntry{}
+			if err := json.Unmarshal(item, &entry); err != nil {
+				return err
+			}
+			add(entry.Address, entry.Reason)
+		}
+		return nil
+	}
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(strings.TrimSpace(record[0]), "address") {
+			continue
+		}
+		reason := ""
+		if len(record) > 1 {
+			reason = strings.TrimSpace(record[1])
+		}
+		add(record[0], reason)
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScreenerFailsClosed(t *testing.T) {
+	if _, err := NewScreener([]string{filepath.Join(t.TempDir(), "missing.csv")}, time.Hour); err == nil {
+		t.Fatal("screener started without its list")
+	}
+}
+
+// a list that breaks after loading keeps the addresses it had
+func TestScreenerKeepsListOnError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "list.csv")
+	if err := os.WriteFile(file, []byte("address,reason\n0xABC,theft\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScreener([]string{file}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := s.Check("0xabc"); entry == nil || entry.Reason != "theft" {
+		t.Fatalf("got %+v, want the listed entry", entry)
+	}
+
+	if err := os.WriteFile(file, []byte(`"unterminated`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// refresh is 0, so Check reloads and fails
+	if entry := s.Check("0xABC"); entry == nil {
+		t.Fatal("list emptied by a broken reload")
+	}
+	if s.Status().Error == "" {
+		t.Error("reload error not reported")
+	}
+}
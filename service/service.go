@@ -2,6 +2,10 @@ package service
 
 import (
 	"cryptoshare/conf"
+	"cryptoshare/dto"
+	"errors"
+
+	"github.com/go-redis/redis/v9"
 )
 
 var (
@@ -9,16 +13,96 @@ var (
 	tatumApiKey  = conf.TATUM_API_KEY
 )
 
+var ErrUnsupportedCurrency = errors.New("currency is not supported on this network")
+
 type Service struct {
-	TRC20 *trc20Service
-	ERC20 *erc20Service
+	TRC20   *trc20Service
+	ERC20   *erc20Service
+	Price   PriceProvider
+	Webhook *WebhookSender
+	Mail    Mailer
+	Blob    BlobStore
+	Screen  *Screener
 }
 
-func NewService() *Service {
+func NewService(rdb *redis.Client) *Service {
+	priceProvider := &overridePriceProvider{
+		Overrides: NewStaticPriceProvider(conf.PriceOverrides),
+		Source: &cachedPriceProvider{
+			RDB:    rdb,
+			Source: newHTTPPriceProvider(conf.PriceSourceURL),
+			TTL:    conf.PriceCacheTTL,
+		},
+	}
 	trc20Service := newTRC20Service()
-	erc20Service := newERC20Service()
+	erc20Service := newERC20Service(priceProvider)
 	return &Service{
-		TRC20: trc20Service,
-		ERC20: erc20Service,
+		TRC20:   trc20Service,
+		ERC20:   erc20Service,
+		Price:   priceProvider,
+		Webhook: NewWebhookSender(nil),
+		Mail:    newMailer(),
+		Blob:    newBlobStore(),
+		Screen:  newScreener(),
+	}
+}
+
+// IsNetworkCurrency reports whether currency can be sent on network
+func IsNetworkCurrency(network, currency string) bool {
+	switch network {
+	case "ERC20":
+		return currency == "ETH" || currency == "USDT"
+	case "TRC20":
+		return currency == "TRX" || currency == "USDT"
+	}
+	return false
+}
+
+// Transfer sends currency on network and returns the transaction hash
+func (s *Service) Transfer(network, currency string, transferReq *dto.TransferReq) (string, error) {
+	if !IsNetworkCurrency(network, currency) {
+		return "", ErrUnsupportedCurrency
+	}
+	if network == "TRC20" {
+		return s.TRC20.Transfer(currency, transferReq.PrivateKey, transferReq.ToAddress, transferReq.Amount)
+	}
+	if currency == "ETH" {
+		return s.ERC20.TransferERC20ETH(transferReq)
+	}
+	return s.ERC20.TransferERC20USDT(transferReq)
+}
+
+// Payout sends exactly the amount, unlike Transfer it keeps no ETH reserve.
+// It is meant for bank addresses that pay many recipients.
+func (s *Service) Payout(network, currency string, transferReq *dto.TransferReq) (string, error) {
+	if network == "ERC20" && currency == "ETH" {
+		return s.ERC20.SendETH(transferReq)
+	}
+	return s.Transfer(network, currency, transferReq)
+}
+
+// Balances returns the balance of every currency of network held by address
+func (s *Service) Balances(network, address string) (map[string]float64, error) {
+	if network == "TRC20" {
+		balance, err := s.TRC20.GetBalance(address)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]float64{"TRX": balance.TRXBalance, "USDT": balance.USDTBalance}, nil
+	}
+	balance, err := s.ERC20.GetBalance(address)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]float64{"ETH": balance.ETHBalance, "USDT": balance.USDTBalance}, nil
+}
+
+func (s *Service) EstimateFee(network, currency, from, to string, amount float64) (*FeeEstimate, error) {
+	if !IsNetworkCurrency(network, currency) {
+		return nil, ErrUnsupportedCurrency
+	}
+	if network == "TRC20" {
+		return s.TRC20.EstimateFee(currency), nil
 	}
+	return s.ERC20.EstimateFee(currency, from, to, amount)
 }
@@ -1,10 +1,33 @@
 package service
 
 import (
+	"bytes"
+	"cryptoshare/dto"
+	"cryptoshare/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"net/http"
+	"strconv"
+	"time"
+)
+
+// TRC20 USDT (Tether) contract
+const usdtTRC20ContractAddress = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+
+const (
+	// energy burned by an USDT transfer to an address that never held USDT
+	usdtTransferEnergy = uint64(65000)
+	// sun paid per energy unit when it is not staked for
+	energyPriceSun = int64(420)
+	// bandwidth used by a plain TRX transfer
+	trxTransferBandwidth = uint64(270)
+	// sun paid per bandwidth unit above the free daily allowance
+	bandwidthPriceSun = int64(1000)
+	// max TRX burned for a TRC20 transfer before it is aborted
+	trc20FeeLimitTRX = 40
 )
 
 type trc20Service struct {
@@ -43,3 +66,176 @@ func (s *trc20Service) GetAccountInfo(address string) {
 	}
 	fmt.Printf("%+v\n", accountInfo)
 }
+
+// GetBalance returns the TRX and USDT balance of address. An account that
+// never received anything is not found and has no balance.
+func (s *trc20Service) GetBalance(address string) (*dto.TRC20BalanceResp, error) {
+	reqUrl := fmt.Sprintf("%s/v3/tron/account/%s", tatumBaseURL, address)
+	req, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("x-api-key", tatumApiKey)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return &dto.TRC20BalanceResp{}, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tatum responded %s", res.Status)
+	}
+
+	accountInfo := &TRC20AccountInfo{}
+	if err := json.NewDecoder(res.Body).Decode(accountInfo); err != nil {
+		return nil, err
+	}
+
+	balance := &dto.TRC20BalanceResp{
+		TRXBalance: utils.FromBaseUnits(new(big.Int).SetUint64(accountInfo.Balance), 6),
+	}
+	for _, token := range accountInfo.TRC20 {
+		if value, ok := token[usdtTRC20ContractAddress]; ok {
+			amount, ok := new(big.Int).SetString(value, 10)
+			if ok {
+				balance.USDTBalance = utils.FromBaseUnits(amount, 6)
+			}
+		}
+	}
+	return balance, nil
+}
+
+// EstimateFee returns the energy or bandwidth a transfer burns. Tron fees are
+// fixed per resource unit, so every tier has the same price.
+func (s *trc20Service) EstimateFee(currency string) *FeeEstimate {
+	gasLimit, price := trxTransferBandwidth, bandwidthPriceSun
+	if currency == "USDT" {
+		gasLimit, price = usdtTransferEnergy, energyPriceSun
+	}
+
+	prices := make(map[string]*big.Int, len(feeTierPercents))
+	for tier := range feeTierPercents {
+		prices[tier] = big.NewInt(price)
+	}
+
+	return &FeeEstimate{
+		NativeCurrency: "TRX",
+		Decimals:       6,
+		GasLimit:       gasLimit,
+		GasPrices:      prices,
+	}
+}
+
+type tatumTxResp struct {
+	TxID    string `json:"txId"`
+	Message string `json:"message"`
+}
+
+// Transfer sends TRX or USDT through tatum and returns the transaction id
+func (s *trc20Service) Transfer(currency, privateKey, to string, amount float64) (string, error) {
+	path := "/v3/tron/transaction"
+	body := map[string]any{
+		"fromPrivateKey": privateKey,
+		"to":             to,
+		"amount":         strconv.FormatFloat(amount, 'f', -1, 64),
+	}
+	if currency == "USDT" {
+		path = "/v3/tron/trc20/transaction"
+		body["tokenAddress"] = usdtTRC20ContractAddress
+		body["feeLimit"] = trc20FeeLimitTRX
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", tatumBaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("x-api-key", tatumApiKey)
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Println(err, "Error while sending transaction")
+		return "", err
+	}
+	defer res.Body.Close()
+
+	txResp := &tatumTxResp{}
+	if err := json.NewDecoder(res.Body).Decode(txResp); err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK || txResp.TxID == "" {
+		log.Println("tatum error: ", txResp.Message)
+		return "", errors.New("failed to send transaction: " + txResp.Message)
+	}
+
+	log.Println("tx sent: ", txResp.TxID)
+	return txResp.TxID, nil
+}
+
+// tatumTRC20TxResp lists trc20 transfers, BlockTimestamp is in milliseconds
+type tatumTRC20TxResp struct {
+	Transactions []struct {
+		TxID           string `json:"txID"`
+		BlockTimestamp int64  `json:"blockTimestamp"`
+		From           string `json:"from"`
+		To             string `json:"to"`
+		Value          string `json:"value"`
+		TokenInfo      struct {
+			Address  string `json:"address"`
+			Decimals int    `json:"decimals"`
+		} `json:"tokenInfo"`
+	} `json:"transactions"`
+}
+
+// ListIncomingUSDT returns the confirmed USDT transfers into address. TRX
+// transfers are not listed.
+func (s *trc20Service) ListIncomingUSDT(address string) ([]*ChainTransfer, error) {
+	reqUrl := fmt.Sprintf("%s/v3/tron/transaction/account/%s/trc20?onlyConfirmed=true", tatumBaseURL, address)
+	req, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("x-api-key", tatumApiKey)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tatum responded with %s", res.Status)
+	}
+
+	txResp := &tatumTRC20TxResp{}
+	if err := json.NewDecoder(res.Body).Decode(txResp); err != nil {
+		return nil, err
+	}
+
+	transfers := make([]*ChainTransfer, 0)
+	for _, tx := range txResp.Transactions {
+		if tx.To != address || tx.TokenInfo.Address != usdtTRC20ContractAddress {
+			continue
+		}
+		value, ok := new(big.Int).SetString(tx.Value, 10)
+		if !ok {
+			continue
+		}
+		transfers = append(transfers, &ChainTransfer{
+			TxHash:      tx.TxID,
+			Currency:    "USDT",
+			FromAddress: tx.From,
+			ToAddress:   tx.To,
+			Amount:      utils.FromBaseUnits(value, tx.TokenInfo.Decimals),
+		})
+		if tx.BlockTimestamp > 0 {
+			transfers[len(transfers)-1].BlockTime = time.UnixMilli(tx.BlockTimestamp)
+		}
+	}
+	return transfers, nil
+}
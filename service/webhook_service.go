@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"cryptoshare/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrUnsafeWebhookURL refuses endpoints that aren't https or that reach
+// loopback, private or link-local addresses, so webhooks can't be pointed at
+// the server's own network
+var ErrUnsafeWebhookURL = errors.New("webhook url must be https and reach a public address")
+
+// carrier-grade nat and "this network" aren't covered by net.IP helpers
+var nonPublicNets = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+}
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookIDHeader        = "X-Webhook-ID"
+)
+
+// WebhookSender posts signed payloads. Client can be swapped, e.g. for one
+// pointing at an httptest server.
+type WebhookSender struct {
+	Client *http.Client
+}
+
+// NewWebhookSender uses client as is, without it the default client checks
+// every address it dials and doesn't follow redirects
+func NewWebhookSender(client *http.Client) *WebhookSender {
+	if client == nil {
+		dialer := &net.Dialer{
+			Timeout: 5 * time.Second,
+			// the address is checked after resolving, so a host that resolves
+			// to a public address at creation can't be rebound later
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+					return ErrUnsafeWebhookURL
+				}
+				return nil
+			},
+		}
+		client = &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: 5 * time.Second,
+				MaxIdleConns:        10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			// a redirect is answered as the endpoint's failure
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	return &WebhookSender{Client: client}
+}
+
+// CheckWebhookURL checks the form of an endpoint url, an absolute https url
+func CheckWebhookURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return nil, ErrUnsafeWebhookURL
+	}
+	return u, nil
+}
+
+// ValidateWebhookURL checks an endpoint url before it is saved, every
+// address its host resolves to must be public
+func ValidateWebhookURL(ctx context.Context, raw string) error {
+	u, err := CheckWebhookURL(raw)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		if !PublicIP(ip) {
+			return ErrUnsafeWebhookURL
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("webhook host can't be resolved: %s", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !PublicIP(addr.IP) {
+			return ErrUnsafeWebhookURL
+		}
+	}
+	return nil
+}
+
+// PublicIP reports whether ip is routable on the internet
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, ipNet := range nonPublicNets {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
+
+// Send posts payload to url and returns the response status code. Anything
+// other than 2xx is an error so the delivery is retried, except for
+// ErrUnsafeWebhookURL which won't get better.
+func (s *WebhookSender) Send(ctx context.Context, url, secret, eventID, event string, payload []byte) (int, error) {
+	// endpoints saved before urls were checked may still be plain http
+	if _, err := CheckWebhookURL(url); err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, eventID)
+	req.Header.Set(WebhookEventHeader, event)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, utils.SignWebhook(secret, timestamp, payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drain so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
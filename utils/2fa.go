@@ -1,11 +1,7 @@
 package utils
 
 import (
-	"bytes"
 	"cryptoshare/conf"
-	"image/png"
-	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/pquerna/otp"
@@ -21,20 +17,12 @@ func Create2fa(username string) (*otp.Key, error) {
 	if err != nil {
 		return nil, err
 	}
-	var buf bytes.Buffer
 	img, err := key.Image(200, 200)
 	if err != nil {
 		return nil, err
 	}
-	png.Encode(&buf, img)
 
-	dir, err := os.Getwd()
-	if err != nil {
-		return nil, err
-	}
-
-	fullPath := filepath.Join(dir, "storage", "qrcode", username+".png")
-	if err := os.WriteFile(fullPath, buf.Bytes(), 0644); err != nil {
+	if _, err := saveQRCode(username, img); err != nil {
 		return nil, err
 	}
 
@@ -21,6 +21,12 @@ func EncryptAES(plaintext string) (string, error) {
 		return "", errors.New("invalid private key")
 	}
 
+	return EncryptSecret(plaintext)
+}
+
+// EncryptSecret encrypts any secret that has to be read back, unlike
+// EncryptAES it doesn't require a private key
+func EncryptSecret(plaintext string) (string, error) {
 	c, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// DeviceID identifies the client of a request. Apps send their own id in
+// the X-Device-ID header, browsers fall back to a hash of the user agent.
+func DeviceID(header, userAgent string) string {
+	id := strings.TrimSpace(header)
+	if id == "" {
+		id = "ua:" + userAgent
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
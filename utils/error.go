@@ -73,6 +73,61 @@ func GenerateAuthErrorResponse(err error) *dto.Response {
 	return res
 }
 
+func GenerateForbiddenResponse(err error) *dto.Response {
+	res := &dto.Response{}
+	res.ErrCode = 403
+	res.ErrMsg = "forbidden"
+	if err != nil {
+		res.ErrMsg = err.Error()
+	}
+	res.HttpStatusCode = http.StatusForbidden
+	return res
+}
+
+// GenerateAcceptedResponse is a request taken but not done yet, err says why
+func GenerateAcceptedResponse(err error) *dto.Response {
+	res := &dto.Response{}
+	res.ErrCode = 202
+	res.ErrMsg = err.Error()
+	res.HttpStatusCode = http.StatusAccepted
+	return res
+}
+
+// GenerateGeoBlockedResponse refuses a client from a blocked jurisdiction
+func GenerateGeoBlockedResponse(err error) *dto.Response {
+	res := &dto.Response{}
+	res.ErrCode = 451
+	res.ErrMsg = err.Error()
+	res.HttpStatusCode = http.StatusUnavailableForLegalReasons
+	return res
+}
+
+// GenerateInvalidCredentialsResponse is the one answer to every failed
+// login, it doesn't tell which part was wrong or whether the account exists
+func GenerateInvalidCredentialsResponse() *dto.Response {
+	res := &dto.Response{}
+	res.ErrCode = 401
+	res.ErrMsg = "invalid credentials"
+	res.HttpStatusCode = http.StatusUnauthorized
+	return res
+}
+
+func GenerateTooManyRequestsResponse(err error) *dto.Response {
+	res := &dto.Response{}
+	res.ErrCode = 429
+	res.ErrMsg = err.Error()
+	res.HttpStatusCode = http.StatusTooManyRequests
+	return res
+}
+
+func GenerateConflictResponse(err error) *dto.Response {
+	res := &dto.Response{}
+	res.ErrCode = 409
+	res.ErrMsg = err.Error()
+	res.HttpStatusCode = http.StatusConflict
+	return res
+}
+
 func GenerateWrongOTPResponse(err error) *dto.Response {
 	res := &dto.Response{}
 	res.ErrCode = 401
@@ -89,6 +144,25 @@ func GenerateBadRequestResponse(err error) *dto.Response {
 	return res
 }
 
+// GenerateBadRequestErrorResponse is a bad request that tells the client why
+func GenerateBadRequestErrorResponse(err error) *dto.Response {
+	res := &dto.Response{}
+	res.ErrCode = 400
+	res.ErrMsg = err.Error()
+	res.HttpStatusCode = http.StatusBadRequest
+	return res
+}
+
+// GenerateServiceUnavailableResponse is for dependencies that are down or
+// out of date, the client may retry later
+func GenerateServiceUnavailableResponse(err error) *dto.Response {
+	res := &dto.Response{}
+	res.ErrCode = 503
+	res.ErrMsg = err.Error()
+	res.HttpStatusCode = http.StatusServiceUnavailable
+	return res
+}
+
 func GenerateServerError(err error) *dto.Response {
 	res := &dto.Response{}
 	res.ErrCode = 500
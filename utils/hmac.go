@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RandomHex returns n random bytes hex encoded
+func RandomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SHA256Hex is used to store tokens and keys that are only compared
+func SHA256Hex(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func HMACSHA256Hex(secret string, message []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(message)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest is the merchant api signature, an HMAC-SHA256 of
+// METHOD\nPATH\nTIMESTAMP\nNONCE\nSHA256(BODY)
+func SignRequest(secret, method, path, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	message := strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+	return HMACSHA256Hex(secret, []byte(message))
+}
+
+// EqualSignature compares signatures in constant time
+func EqualSignature(a, b string) bool {
+	return hmac.Equal([]byte(a), []byte(b))
+}
+
+// SignWebhook is the webhook signature, an HMAC-SHA256 of TIMESTAMP.BODY
+func SignWebhook(secret, timestamp string, body []byte) string {
+	message := append([]byte(timestamp+"."), body...)
+	return HMACSHA256Hex(secret, message)
+}
+
+// SignToken makes an emailed token, RANDOM.EXPIRES.HMAC(PURPOSE.RANDOM.EXPIRES),
+// so forged or expired ones are refused before any lookup
+func SignToken(secret, purpose string, expires time.Time) (string, error) {
+	random, err := RandomHex(32)
+	if err != nil {
+		return "", err
+	}
+	payload := fmt.Sprintf("%s.%d", random, expires.Unix())
+	return payload + "." + HMACSHA256Hex(secret, []byte(purpose+"."+payload)), nil
+}
+
+// VerifyToken checks the signature and expiry of a SignToken token
+func VerifyToken(secret, purpose, token string, now time.Time) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return false
+	}
+	payload := parts[0] + "." + parts[1]
+	return EqualSignature(parts[2], HMACSHA256Hex(secret, []byte(purpose+"."+payload)))
+}
@@ -7,25 +7,31 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
 // Create a struct that will be encoded to a JWT.
 // We add jwt.RegisteredClaims as an embedded type, to provide fields like expiry time
+// The jti (RegisteredClaims.ID) is unique per token, SessionID ties it to
+// the login it was issued for so the whole session can be revoked.
 type Claims struct {
-	Username string `json:"username"`
-	IsAdmin  bool   `json:"is_admin"`
+	Username  string `json:"username"`
+	IsAdmin   bool   `json:"is_admin"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// generate admin access token
-func GenerateAccessToken(username string, isAdmin bool) (string, error) {
+// generate admin access token, it expires after conf.AccessTokenTTL and is
+// renewed with a refresh token
+func GenerateAccessToken(username string, isAdmin bool, sessionID string) (string, error) {
 	// Declare the expiration time of the token
-	// here, we have kept it as 5 minutes
-	expirationTime := time.Now().Add(24 * time.Hour)
+	expirationTime := time.Now().Add(conf.AccessTokenTTL)
 	claims := &Claims{
-		Username: username,
-		IsAdmin:  isAdmin,
+		Username:  username,
+		IsAdmin:   isAdmin,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
@@ -69,23 +75,3 @@ func ValidateAccessToken(tokenString string) (*Claims, error) {
 	return claims, nil
 
 }
-
-func GenerateRefreshToken(token string) (string, error) {
-	claim, err := ValidateAccessToken(token)
-	if err != nil {
-		return "", err
-	}
-
-	// We ensure that a new token is not issued until enough time has elapsed
-	// In this case, a new token will only be issued if the old token is within
-	// 30 seconds of expiry. Otherwise, return a bad request status
-	if time.Until(claim.ExpiresAt.Time) > 30*time.Second {
-		return "", errors.New("token is not expired, yet")
-	}
-
-	refreshToken, err := GenerateAccessToken(claim.Username, claim.IsAdmin)
-	if err != nil {
-		return "", err
-	}
-	return refreshToken, nil
-}
@@ -1,15 +1,38 @@
 package utils
 
 import (
+	"sync"
+
 	"github.com/ip2location/ip2location-go/v9"
 )
 
+const locationDBPath = "./conf/ip2location_region.bin"
+
+var (
+	locationOnce sync.Once
+	locationDB   *ip2location.DB
+	locationErr  error
+)
+
+// openLocationDB opens the ip2location database on first use, lookups only
+// read from the file so the handle is shared
+func openLocationDB() (*ip2location.DB, error) {
+	locationOnce.Do(func() {
+		locationDB, locationErr = ip2location.OpenDB(locationDBPath)
+	})
+	return locationDB, locationErr
+}
+
+func isLocalIP(ip string) bool {
+	return ip == "127.0.0.1" || ip == "::1"
+}
+
 func GetArea(ip string) (string, error) {
-	db, err := ip2location.OpenDB("./conf/ip2location_region.bin")
-	if ip == "127.0.0.1" || ip == "::1" {
+	if isLocalIP(ip) {
 		return "Local City, Local Country", nil
 	}
 
+	db, err := openLocationDB()
 	if err != nil {
 		return "", nil
 	}
@@ -17,6 +40,27 @@ func GetArea(ip string) (string, error) {
 	if err != nil {
 		return "", nil
 	}
-	// fmt.Printf("region: %s\n", results.Region)
 	return results.Country_long + ", " + results.City, nil
 }
+
+// GetCountry returns the ISO 3166 code of the country of ip, it is empty
+// for local addresses and ones the database doesn't know
+func GetCountry(ip string) (string, error) {
+	if isLocalIP(ip) {
+		return "", nil
+	}
+
+	db, err := openLocationDB()
+	if err != nil {
+		return "", err
+	}
+	results, err := db.Get_country_short(ip)
+	if err != nil {
+		return "", err
+	}
+	// the database answers "-" for reserved and unknown ranges
+	if len(results.Country_short) != 2 {
+		return "", nil
+	}
+	return results.Country_short, nil
+}
@@ -31,3 +31,7 @@ func NewUInt32(no uint32) *uint32 {
 func NewUInt8(no uint8) *uint8 {
 	return &no
 }
+
+func NewFloat64(no float64) *float64 {
+	return &no
+}
@@ -1,6 +1,9 @@
 package utils
 
-import "strconv"
+import (
+	"math/big"
+	"strconv"
+)
 
 // asInt returns the parameter as a int64
 // or panics if it can't convert
@@ -12,3 +15,22 @@ func asInt(param string) int64 {
 
 	return i
 }
+
+// ToBaseUnits converts an amount like 1.5 USDT to its smallest unit
+// (1500000 for 6 decimals)
+func ToBaseUnits(amount float64, decimals int) *big.Int {
+	value := new(big.Float).SetPrec(256).SetFloat64(amount)
+	value.Mul(value, new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	// round half up instead of truncating 0.29999... to 0.2
+	value.Add(value, big.NewFloat(0.5))
+	result, _ := value.Int(nil)
+	return result
+}
+
+// FromBaseUnits is the reverse of ToBaseUnits
+func FromBaseUnits(value *big.Int, decimals int) float64 {
+	fvalue := new(big.Float).SetInt(value)
+	fvalue.Quo(fvalue, new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	result, _ := fvalue.Float64()
+	return result
+}
@@ -1,6 +1,10 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -11,3 +15,19 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// dummyHash has the cost of real hashes, it is made on first use
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// CheckNoPassword takes as long as CheckPasswordHash, for logins to unknown
+// accounts so timing doesn't tell them apart
+func CheckNoPassword(password string) bool {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("no password"), 14)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+	return false
+}
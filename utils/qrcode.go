@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+)
+
+// QRCodePath is where the png of name is stored under storage/qrcode
+func QRCodePath(name string) (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "storage", "qrcode", name+".png"), nil
+}
+
+// CreateQRCode encodes content as a 200x200 png named name and returns its path
+func CreateQRCode(name, content string) (string, error) {
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return "", err
+	}
+	img, err := barcode.Scale(code, 200, 200)
+	if err != nil {
+		return "", err
+	}
+	return saveQRCode(name, img)
+}
+
+func saveQRCode(name string, img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+
+	fullPath, err := QRCodePath(name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(fullPath, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return fullPath, nil
+}
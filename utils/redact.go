@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// secretKeys are redacted from audit records wherever they appear in a key,
+// secretNames only when they are the whole key
+var (
+	secretKeys  = []string{"password", "secret", "private", "passphrase", "mnemonic", "token", "auth_url", "api_key", "apikey", "recovery"}
+	secretNames = []string{"otp", "code"}
+)
+
+const redacted = "[REDACTED]"
+
+// RedactJSON marshals v and replaces the values of secret looking keys, at
+// any depth. Raw JSON bytes are redacted as they are.
+func RedactJSON(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	var raw []byte
+	switch value := v.(type) {
+	case []byte:
+		raw = value
+	case json.RawMessage:
+		raw = value
+	default:
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return nil
+		}
+	}
+
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil
+	}
+	out, err := json.Marshal(redact(doc))
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+func redact(doc any) any {
+	switch value := doc.(type) {
+	case map[string]any:
+		for key, item := range value {
+			if isSecretKey(key) {
+				value[key] = redacted
+				continue
+			}
+			value[key] = redact(item)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = redact(item)
+		}
+	}
+	return doc
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, name := range secretNames {
+		if key == name {
+			return true
+		}
+	}
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	at := func(value string) time.Time {
+		t.Helper()
+		parsed, err := time.Parse("2006-01-02 15:04:05", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	start := at("2024-01-01 00:00:00")
+
+	// 2024-01-01 is a monday and 2024 a leap year
+	tests := []struct {
+		name string
+		rule string
+		from string
+		want string
+	}{
+		{"every minute", "* * * * *", "2024-01-01 10:00:30", "2024-01-01 10:01:00"},
+		{"strictly after", "0 10 * * *", "2024-01-01 10:00:00", "2024-01-02 10:00:00"},
+		{"next day", "30 9 * * *", "2024-01-01 10:00:00", "2024-01-02 09:30:00"},
+		{"minute step", "*/15 * * * *", "2024-01-01 10:07:00", "2024-01-01 10:15:00"},
+		{"range step", "0 9-17/4 * * *", "2024-01-01 10:00:00", "2024-01-01 13:00:00"},
+		{"value step", "5/20 * * * *", "2024-01-01 10:30:00", "2024-01-01 10:45:00"},
+		{"list", "0 0 1,15 * *", "2024-01-02 00:00:00", "2024-01-15 00:00:00"},
+		{"list of ranges", "0 8-9,17-18 * * *", "2024-01-01 10:00:00", "2024-01-01 17:00:00"},
+		{"weekdays", "0 0 * * 1-5", "2024-01-06 12:00:00", "2024-01-08 00:00:00"},
+		{"sunday as 7", "0 0 * * 7", "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+		{"sunday as 0", "0 0 * * 0", "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+		{"dom or dow, dow first", "0 0 13 * 5", "2024-01-01 00:00:00", "2024-01-05 00:00:00"},
+		{"dom or dow, dom first", "0 0 13 * 5", "2024-01-12 00:00:00", "2024-01-13 00:00:00"},
+		{"dom and star dow", "0 0 13 * *", "2024-01-01 00:00:00", "2024-01-13 00:00:00"},
+		{"month rollover", "0 0 31 * *", "2024-01-31 00:00:00", "2024-03-31 00:00:00"},
+		{"year rollover", "0 0 1 1 *", "2024-06-01 00:00:00", "2025-01-01 00:00:00"},
+		{"month field", "0 0 1 6,12 *", "2024-06-01 00:00:00", "2024-12-01 00:00:00"},
+		{"leap day", "0 0 29 2 *", "2024-02-01 00:00:00", "2024-02-29 00:00:00"},
+		{"next leap day", "0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		{"impossible date", "0 0 30 2 *", "2024-01-01 00:00:00", ""},
+		{"alias", "@daily", "2024-01-01 10:00:00", "2024-01-02 00:00:00"},
+		{"weekly alias", "@weekly", "2024-01-01 10:00:00", "2024-01-07 00:00:00"},
+		{"monthly alias", "@monthly", "2024-01-31 10:00:00", "2024-02-01 00:00:00"},
+		{"interval", "@every 90m", "2024-01-01 02:00:00", "2024-01-01 03:00:00"},
+		{"interval on a run", "@every 90m", "2024-01-01 03:00:00", "2024-01-01 04:30:00"},
+		{"interval before start", "@every 1h", "2023-12-31 12:00:00", "2024-01-01 00:00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.rule, start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := schedule.Next(at(tt.from))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("got %s, want no run", got)
+				}
+				return
+			}
+			if want := at(tt.want); !got.Equal(want) {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	rules := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"5-1 * * * *",
+		"1-2-3 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"@yearly",
+		"@every 30s",
+		"@every soon",
+	}
+	for _, rule := range rules {
+		if _, err := ParseSchedule(rule, time.Now()); err == nil {
+			t.Errorf("%q parsed", rule)
+		}
+	}
+}
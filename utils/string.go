@@ -9,6 +9,15 @@ func GenerateRepeatedLetter(char string, times int) string {
 	return strings.Repeat(char, times)
 }
 
+// Truncate cuts s to at most n runes, for varchar columns
+func Truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
 // if IDWallet like that, it will get idwallet
 // otherwise, it's fine
 func CapitalToUnderScore(word string) string {
@@ -3,6 +3,8 @@ package utils
 import (
 	"strings"
 
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -15,3 +17,25 @@ var Checkphrase validator.Func = func(fl validator.FieldLevel) bool {
 	}
 	return false
 }
+
+// IsValidAddress checks the format of an address on network, TRC20 addresses
+// are base58check with the 0x41 prefix
+func IsValidAddress(network, address string) bool {
+	switch network {
+	case "ERC20":
+		return common.IsHexAddress(address)
+	case "TRC20":
+		payload, version, err := base58.CheckDecode(address)
+		return err == nil && version == 0x41 && len(payload) == 20
+	}
+	return false
+}
+
+// NormalizeAddress gives ERC20 addresses their checksum case, so the same
+// address always compares equal
+func NormalizeAddress(network, address string) string {
+	if network == "ERC20" && common.IsHexAddress(address) {
+		return common.HexToAddress(address).Hex()
+	}
+	return address
+}
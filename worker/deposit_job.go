@@ -0,0 +1,306 @@
+package worker
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// most blocks scanned in one run, so a long downtime catches up gradually
+const maxScanBlocks = 50
+
+// trc20ScanOverlap is how far before the last scan TRC20 transfers are looked
+// at again, tatum may list a transfer only some time after it was mined
+const trc20ScanOverlap = time.Hour
+
+type depositJob struct {
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func newDepositJob(w *Worker) *depositJob {
+	return &depositJob{
+		repo: w.repo,
+		svc:  w.svc,
+	}
+}
+
+func (j *depositJob) run(ctx context.Context) {
+	j.scanERC20(ctx)
+	j.scanTRC20(ctx)
+
+	expired, err := j.repo.Invoice.ExpireOpen(ctx, time.Now())
+	if err != nil {
+		log.Println(err, "Error expiring invoices")
+	} else if expired > 0 {
+		log.Printf("%d invoices expired\n", expired)
+	}
+}
+
+// scanERC20 records transfers into user wallets from the blocks after the
+// cursor and confirms those with enough confirmations.
+func (j *depositJob) scanERC20(ctx context.Context) {
+	head, err := j.svc.ERC20.BlockNumber()
+	if err != nil {
+		log.Println(err, "Error getting block number")
+		return
+	}
+
+	cursor, found, err := j.repo.Deposit.Cursor(ctx, "ERC20")
+	if err != nil {
+		log.Println(err, "Error reading scan cursor")
+		return
+	}
+	// the first run starts from the current block
+	if !found {
+		cursor = head - 1
+	}
+
+	if cursor < head {
+		fromBlock, toBlock := cursor+1, head
+		if toBlock-fromBlock+1 > maxScanBlocks {
+			toBlock = fromBlock + maxScanBlocks - 1
+		}
+
+		wallets, err := j.repo.Wallet.ListByNetwork(ctx, "ERC20")
+		if err != nil {
+			log.Println(err, "Error loading wallets")
+			return
+		}
+		byAddress := map[string]*model.Wallet{}
+		addresses := make([]string, 0, len(wallets))
+		for _, wallet := range wallets {
+			byAddress[strings.ToLower(wallet.Address)] = wallet
+			addresses = append(addresses, wallet.Address)
+		}
+
+		transfers, err := j.svc.ERC20.ScanTransfers(fromBlock, toBlock, addresses)
+		if err != nil {
+			log.Printf("%v Error scanning ERC20 transfers in blocks %d-%d\n", err, fromBlock, toBlock)
+			return
+		}
+		for _, transfer := range transfers {
+			wallet, ok := byAddress[strings.ToLower(transfer.ToAddress)]
+			if !ok {
+				continue
+			}
+			j.detect(ctx, "ERC20", wallet, transfer)
+		}
+
+		if err := j.repo.Deposit.SetCursor(ctx, "ERC20", toBlock); err != nil {
+			log.Println(err, "Error saving scan cursor")
+			return
+		}
+	}
+
+	deposits, err := j.repo.Deposit.ListByStatus(ctx, "ERC20", model.DepositStatusDetected)
+	if err != nil {
+		log.Println(err, "Error loading detected deposits")
+		return
+	}
+	for _, deposit := range deposits {
+		if head+1 < deposit.BlockNumber+conf.ERC20Confirmations {
+			continue
+		}
+		if !j.inChain(ctx, deposit) {
+			continue
+		}
+		j.confirm(ctx, deposit)
+	}
+}
+
+// inChain checks a deposit against the canonical chain before it is
+// confirmed. A reorged deposit waits for its confirmations again from its new
+// block, one no longer in the chain is dropped.
+func (j *depositJob) inChain(ctx context.Context, deposit *model.Deposit) bool {
+	block, found, err := j.svc.ERC20.TransferInChain(deposit.TxHash, deposit.LogIndex)
+	if err != nil {
+		log.Println(err, "Error checking deposit ", deposit.TxHash)
+		return false
+	}
+	if !found {
+		if _, err := j.repo.Deposit.Drop(ctx, deposit); err != nil {
+			log.Println(err, "Error dropping deposit ", deposit.TxHash)
+			return false
+		}
+		log.Printf("deposit dropped: %s is no longer in the chain\n", deposit.TxHash)
+		return false
+	}
+	if block != deposit.BlockNumber {
+		if err := j.repo.Deposit.SetBlock(ctx, deposit, block); err != nil {
+			log.Println(err, "Error moving deposit ", deposit.TxHash)
+			return false
+		}
+		log.Printf("deposit reorged: %s moved to block %d\n", deposit.TxHash, block)
+		return false
+	}
+	return true
+}
+
+// scanTRC20 records USDT transfers into user wallets mined since the last
+// scan, tatum only lists confirmed ones so they are confirmed right away. The
+// first run only starts the cursor, past transfers are not deposits.
+func (j *depositJob) scanTRC20(ctx context.Context) {
+	startedAt := time.Now()
+	cursor, found, err := j.repo.Deposit.Cursor(ctx, "TRC20")
+	if err != nil {
+		log.Println(err, "Error reading scan cursor")
+		return
+	}
+
+	if found {
+		since := time.UnixMilli(int64(cursor)).Add(-trc20ScanOverlap)
+		if !j.scanTRC20Wallets(ctx, since) {
+			return
+		}
+	}
+	if err := j.repo.Deposit.SetCursor(ctx, "TRC20", uint64(startedAt.UnixMilli())); err != nil {
+		log.Println(err, "Error saving scan cursor")
+		return
+	}
+
+	deposits, err := j.repo.Deposit.ListByStatus(ctx, "TRC20", model.DepositStatusDetected)
+	if err != nil {
+		log.Println(err, "Error loading detected deposits")
+		return
+	}
+	for _, deposit := range deposits {
+		j.confirm(ctx, deposit)
+	}
+}
+
+// scanTRC20Wallets detects the transfers mined from since, it reports false
+// when a wallet could not be listed so the cursor stays put
+func (j *depositJob) scanTRC20Wallets(ctx context.Context, since time.Time) bool {
+	wallets, err := j.repo.Wallet.ListByNetwork(ctx, "TRC20")
+	if err != nil {
+		log.Println(err, "Error loading wallets")
+		return false
+	}
+
+	scanned := true
+	for _, wallet := range wallets {
+		transfers, err := j.svc.TRC20.ListIncomingUSDT(wallet.Address)
+		if err != nil {
+			log.Println(err, "Error listing transfers of ", wallet.Address)
+			scanned = false
+			continue
+		}
+		for _, transfer := range transfers {
+			// without a block time the transfer is kept, deposits are
+			// recorded once anyway
+			if !transfer.BlockTime.IsZero() && transfer.BlockTime.Before(since) {
+				continue
+			}
+			j.detect(ctx, "TRC20", wallet, transfer)
+		}
+	}
+	return scanned
+}
+
+// detect records a transfer into a user wallet. Transfers from a screened
+// address are held for review instead of being confirmed.
+func (j *depositJob) detect(ctx context.Context, network string, wallet *model.Wallet, transfer *service.ChainTransfer) {
+	deposit := &model.Deposit{
+		UserID:      wallet.UserID,
+		WalletID:    wallet.ID,
+		TxHash:      transfer.TxHash,
+		LogIndex:    transfer.LogIndex,
+		Network:     network,
+		Currency:    transfer.Currency,
+		FromAddress: transfer.FromAddress,
+		ToAddress:   wallet.Address,
+		Amount:      transfer.Amount,
+		BlockNumber: transfer.BlockNumber,
+		Status:      model.DepositStatusDetected,
+	}
+	if !transfer.BlockTime.IsZero() {
+		deposit.BlockTime = &transfer.BlockTime
+	}
+	entry := j.svc.Screen.Check(transfer.FromAddress)
+	if entry != nil {
+		deposit.Status = model.DepositStatusHeld
+	}
+	created, err := j.repo.Deposit.Create(ctx, deposit)
+	if err != nil {
+		log.Println(err, "Error saving deposit ", transfer.TxHash)
+		return
+	}
+	if created && entry != nil {
+		j.recordHit(ctx, deposit, entry)
+		return
+	}
+	if created {
+		log.Printf("deposit detected: %s %v %s to %s\n", transfer.TxHash, transfer.Amount, transfer.Currency, wallet.Address)
+		j.notify(ctx, deposit.UserID, model.EventDepositDetected, deposit)
+	}
+}
+
+// confirm screens the sender again, the lists may have changed since the
+// deposit was detected, and credits the deposit. Deposits released by an
+// admin are not screened again.
+func (j *depositJob) confirm(ctx context.Context, deposit *model.Deposit) {
+	if deposit.ReviewedBy == nil {
+		if entry := j.svc.Screen.Check(deposit.FromAddress); entry != nil {
+			held, err := j.repo.Deposit.Hold(ctx, deposit)
+			if err != nil {
+				log.Println(err, "Error holding deposit ", deposit.TxHash)
+				return
+			}
+			if held {
+				j.recordHit(ctx, deposit, entry)
+			}
+			return
+		}
+	}
+
+	invoice, confirmed, err := j.repo.Deposit.Confirm(ctx, deposit, time.Now())
+	if err != nil {
+		log.Println(err, "Error confirming deposit ", deposit.TxHash)
+		return
+	}
+	if !confirmed {
+		return
+	}
+	log.Printf("deposit confirmed: %s\n", deposit.TxHash)
+	j.notify(ctx, deposit.UserID, model.EventDepositConfirmed, deposit)
+	if invoice != nil {
+		log.Printf("invoice %s is %s\n", invoice.ID, invoice.Status)
+		if invoice.Status == model.InvoiceStatusPaid || invoice.Status == model.InvoiceStatusOverpaid {
+			j.notify(ctx, invoice.UserID, model.EventInvoicePaid, invoice)
+		}
+	}
+}
+
+// recordHit logs a deposit held by screening for the review queue
+func (j *depositJob) recordHit(ctx context.Context, deposit *model.Deposit, entry *service.ScreeningEntry) {
+	log.Printf("deposit held: %s from listed address %s\n", deposit.TxHash, deposit.FromAddress)
+	hit := &model.ScreeningHit{
+		UserID:    &deposit.UserID,
+		Direction: model.ScreeningDeposit,
+		Network:   deposit.Network,
+		Currency:  deposit.Currency,
+		Address:   deposit.FromAddress,
+		Amount:    deposit.Amount,
+		List:      entry.List,
+		Reason:    entry.Reason,
+		Reference: deposit.TxHash,
+	}
+	if err := j.repo.Screening.RecordHit(ctx, hit); err != nil {
+		log.Println(err, "Error recording screening hit")
+	}
+}
+
+// notify queues a webhook event, a failure only costs the notification
+func (j *depositJob) notify(ctx context.Context, userID uuid.UUID, event string, data any) {
+	if err := j.repo.Webhook.Enqueue(ctx, userID, event, data); err != nil {
+		log.Println(err, "Error queueing webhook ", event)
+	}
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/payment"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"log"
+	"time"
+)
+
+type payoutJob struct {
+	repo *repository.Repository
+	pay  *payment.Payments
+}
+
+func newPayoutJob(w *Worker) *payoutJob {
+	return &payoutJob{
+		repo: w.repo,
+		pay:  w.pay,
+	}
+}
+
+// run resumes the executing batches nobody has touched for
+// conf.PayoutStaleAfter, the server sending them stopped halfway
+func (j *payoutJob) run(ctx context.Context) {
+	before := time.Now().Add(-conf.PayoutStaleAfter)
+	batches, err := j.repo.Payout.ListStale(ctx, before)
+	if err != nil {
+		log.Println(err, "Error loading stale payout batches")
+		return
+	}
+
+	for _, batch := range batches {
+		resumed, err := j.repo.Payout.Resume(ctx, batch, before)
+		if err != nil {
+			log.Println(err, "Error resuming payout batch ", batch.ID)
+			continue
+		}
+		if !resumed {
+			continue
+		}
+
+		bank, err := j.repo.Bank.FindByID(batch.BankID)
+		if err != nil {
+			log.Println(err, "Error loading bank of payout batch ", batch.ID)
+			continue
+		}
+		privateKey, err := utils.DecryptAES(*bank.PrivateKey)
+		if err != nil {
+			log.Println(err, "Error decrypting bank key of payout batch ", batch.ID)
+			continue
+		}
+
+		log.Printf("resuming payout batch %d\n", batch.ID)
+		j.pay.ExecutePayout(ctx, batch, bank, privateKey)
+	}
+}
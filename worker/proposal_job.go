@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"context"
+	"cryptoshare/repository"
+	"log"
+	"time"
+)
+
+type proposalJob struct {
+	repo *repository.Repository
+}
+
+func newProposalJob(w *Worker) *proposalJob {
+	return &proposalJob{
+		repo: w.repo,
+	}
+}
+
+// run expires the four-eyes proposals nobody decided on in time
+func (j *proposalJob) run(ctx context.Context) {
+	expired, err := j.repo.Proposal.Expire(ctx, time.Now())
+	if err != nil {
+		log.Println(err, "Error expiring proposals")
+	}
+	if expired > 0 {
+		log.Printf("%d proposals expired\n", expired)
+	}
+}
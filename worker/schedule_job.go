@@ -0,0 +1,171 @@
+package worker
+
+import (
+	"context"
+	"cryptoshare/dto"
+	"cryptoshare/model"
+	"cryptoshare/payment"
+	"cryptoshare/repository"
+	"cryptoshare/utils"
+	"errors"
+	"log"
+	"time"
+)
+
+// schedules run in one pass
+const scheduleBatchSize = 100
+
+type scheduleJob struct {
+	repo *repository.Repository
+	pay  *payment.Payments
+}
+
+func newScheduleJob(w *Worker) *scheduleJob {
+	return &scheduleJob{
+		repo: w.repo,
+		pay:  w.pay,
+	}
+}
+
+func (j *scheduleJob) run(ctx context.Context) {
+	now := time.Now()
+	schedules, err := j.repo.Schedule.ListDue(ctx, now, scheduleBatchSize)
+	if err != nil {
+		log.Println(err, "Error loading scheduled transfers")
+		return
+	}
+
+	for _, schedule := range schedules {
+		j.runSchedule(ctx, schedule, now)
+	}
+}
+
+// runSchedule sends one due run. Runs missed while the worker was down are
+// not caught up, the next run is the first one after now.
+func (j *scheduleJob) runSchedule(ctx context.Context, schedule *model.ScheduledTransfer, now time.Time) {
+	dueAt := *schedule.NextRunAt
+
+	var next *time.Time
+	rule, err := utils.ParseSchedule(schedule.Rule, schedule.StartAt)
+	if err != nil {
+		log.Println(err, "Error parsing rule of scheduled transfer ", schedule.ID)
+	} else if at := rule.Next(now); !at.IsZero() && (schedule.EndAt == nil || !at.After(*schedule.EndAt)) {
+		next = &at
+	}
+
+	claimed, err := j.repo.Schedule.Claim(ctx, schedule, next, now)
+	if err != nil {
+		log.Println(err, "Error claiming scheduled transfer ", schedule.ID)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	j.expireHeld(ctx, schedule)
+
+	run := &model.ScheduledTransferRun{
+		ScheduleID: schedule.ID,
+		DueAt:      dueAt,
+	}
+	tx, err := j.send(ctx, schedule)
+	var review *payment.ReviewError
+	switch {
+	case err == nil:
+		run.Status = model.ScheduleRunSent
+		run.TxHash = tx.TxHash
+	case errors.Is(err, payment.ErrInsufficientFunds):
+		run.Status = model.ScheduleRunSkipped
+		run.Error = err.Error()
+		j.notify(ctx, schedule, run, model.EventScheduleSkipped)
+	case errors.As(err, &review):
+		run.Status = model.ScheduleRunHeld
+		run.Error = err.Error()
+		run.AssessmentID = &review.Assessment.ID
+		j.notify(ctx, schedule, run, model.EventScheduleHeld)
+	default:
+		run.Status = model.ScheduleRunFailed
+		run.Error = err.Error()
+		if len(run.Error) > 500 {
+			run.Error = run.Error[:500]
+		}
+	}
+
+	if err := j.repo.Schedule.RecordRun(ctx, schedule, run); err != nil {
+		log.Println(err, "Error saving run of scheduled transfer ", schedule.ID)
+		return
+	}
+	log.Printf("scheduled transfer %s run %s\n", schedule.ID, run.Status)
+}
+
+// expireHeld ends the reviews of earlier runs still held, so a late approval
+// doesn't send them on top of the run now due
+func (j *scheduleJob) expireHeld(ctx context.Context, schedule *model.ScheduledTransfer) {
+	runs, err := j.repo.Schedule.ListHeldRuns(ctx, schedule.ID)
+	if err != nil {
+		log.Println(err, "Error loading held runs of scheduled transfer ", schedule.ID)
+		return
+	}
+	for _, run := range runs {
+		if run.AssessmentID == nil {
+			continue
+		}
+		assessment, err := j.repo.Risk.FindByID(ctx, *run.AssessmentID)
+		if err != nil {
+			log.Println(err, "Error loading risk review ", *run.AssessmentID)
+			continue
+		}
+		expired, err := j.repo.Risk.Expire(ctx, assessment)
+		if err != nil {
+			log.Println(err, "Error expiring risk review ", assessment.ID)
+			continue
+		}
+		if !expired {
+			// decided meanwhile, the review records the run itself
+			continue
+		}
+		if err := j.repo.Schedule.FinishHeldRun(ctx, assessment); err != nil {
+			log.Println(err, "Error saving held run of scheduled transfer ", schedule.ID)
+		}
+	}
+}
+
+// send goes through the same path as a withdrawal or internal transfer made
+// by the user
+func (j *scheduleJob) send(ctx context.Context, schedule *model.ScheduledTransfer) (*model.Transaction, error) {
+	if schedule.User == nil {
+		return nil, errors.New("user not found")
+	}
+
+	if schedule.Kind == model.ScheduleKindInternal {
+		req := &dto.InternalTransferReq{
+			Username: schedule.Username,
+			Network:  schedule.Network,
+			Currency: schedule.Currency,
+			Amount:   schedule.Amount,
+		}
+		return j.pay.InternalTransfer(ctx, schedule.User, req, "", "", "")
+	}
+
+	return j.pay.Withdraw(ctx, &payment.Withdrawal{
+		User: schedule.User,
+		Req: &dto.WithdrawReq{
+			Network:   schedule.Network,
+			Currency:  schedule.Currency,
+			Amount:    schedule.Amount,
+			ToAddress: schedule.ToAddress,
+		},
+		Type: model.TxTypeWithdrawal,
+	})
+}
+
+// notify tells the user about a run that didn't go out
+func (j *scheduleJob) notify(ctx context.Context, schedule *model.ScheduledTransfer, run *model.ScheduledTransferRun, event string) {
+	data := &dto.ScheduledTransferResp{
+		ScheduledTransfer: schedule,
+		Runs:              []*model.ScheduledTransferRun{run},
+	}
+	if err := j.repo.Webhook.Enqueue(ctx, schedule.UserID, event, data); err != nil {
+		log.Println(err, "Error queueing webhook ", event)
+	}
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/model"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"log"
+	"time"
+)
+
+type snapshotJob struct {
+	repo *repository.Repository
+	svc  *service.Service
+}
+
+func newSnapshotJob(w *Worker) *snapshotJob {
+	return &snapshotJob{
+		repo: w.repo,
+		svc:  w.svc,
+	}
+}
+
+// run records the balance and value of every user's assets
+func (j *snapshotJob) run(ctx context.Context) {
+	takenAt := time.Now()
+
+	holdings, err := j.repo.Wallet.Holdings(ctx, nil)
+	if err != nil {
+		log.Println(err, "Error loading holdings")
+		return
+	}
+	if err := service.ValueHoldings(ctx, j.svc.Price, holdings, conf.PortfolioQuote); err != nil {
+		log.Println(err, "Error pricing holdings")
+		return
+	}
+
+	snapshots := make([]*model.BalanceSnapshot, 0, len(holdings))
+	for _, holding := range holdings {
+		snapshots = append(snapshots, &model.BalanceSnapshot{
+			UserID:        holding.UserID,
+			WalletID:      holding.WalletID,
+			Network:       holding.Network,
+			Currency:      holding.Currency,
+			Balance:       holding.Balance,
+			QuoteCurrency: conf.PortfolioQuote,
+			Price:         holding.Price,
+			Value:         holding.Value,
+			TakenAt:       takenAt,
+		})
+	}
+
+	if err := j.repo.Snapshot.CreateMany(ctx, snapshots); err != nil {
+		log.Println(err, "Error saving balance snapshots")
+		return
+	}
+	log.Printf("saved %d balance snapshots\n", len(snapshots))
+}
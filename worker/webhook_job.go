@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/model"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	// deliveries sent in one run
+	webhookBatchSize = 100
+	// longest wait between two attempts
+	webhookMaxBackoff = 6 * time.Hour
+	// how long a claimed delivery is held before another run may retry it
+	webhookClaimTTL = time.Minute
+)
+
+// webhookStore is the part of repository.Webhook the job uses
+type webhookStore interface {
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*model.WebhookDelivery, error)
+	Claim(ctx context.Context, delivery *model.WebhookDelivery, until time.Time) (bool, error)
+	MarkDelivered(ctx context.Context, delivery *model.WebhookDelivery, code int, at time.Time) error
+	MarkFailed(ctx context.Context, delivery *model.WebhookDelivery, code int, reason string, next *time.Time) error
+}
+
+type webhookJob struct {
+	store  webhookStore
+	sender *service.WebhookSender
+}
+
+func newWebhookJob(w *Worker) *webhookJob {
+	return &webhookJob{
+		store:  w.repo.Webhook,
+		sender: w.svc.Webhook,
+	}
+}
+
+func (j *webhookJob) run(ctx context.Context) {
+	deliveries, err := j.store.ListDue(ctx, time.Now(), webhookBatchSize)
+	if err != nil {
+		log.Println(err, "Error loading webhook deliveries")
+		return
+	}
+
+	for _, delivery := range deliveries {
+		claimed, err := j.store.Claim(ctx, delivery, time.Now().Add(webhookClaimTTL))
+		if err != nil {
+			log.Println(err, "Error claiming webhook delivery ", delivery.ID)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		j.deliver(ctx, delivery)
+	}
+}
+
+func (j *webhookJob) deliver(ctx context.Context, delivery *model.WebhookDelivery) {
+	endpoint := delivery.Endpoint
+	if endpoint == nil || endpoint.DeletedAt.Valid || !endpoint.Active {
+		j.fail(ctx, delivery, 0, "endpoint removed or inactive", true)
+		return
+	}
+
+	secret, err := utils.DecryptAES(endpoint.Secret)
+	if err != nil {
+		j.fail(ctx, delivery, 0, err.Error(), false)
+		return
+	}
+
+	j.send(ctx, delivery, secret)
+}
+
+// send posts the delivery to its endpoint signed with secret
+func (j *webhookJob) send(ctx context.Context, delivery *model.WebhookDelivery, secret string) {
+	code, err := j.sender.Send(ctx, delivery.Endpoint.URL, secret, delivery.EventID.String(), delivery.Event, []byte(delivery.Payload))
+	if err != nil {
+		// an endpoint on a private address won't become reachable
+		j.fail(ctx, delivery, code, err.Error(), errors.Is(err, service.ErrUnsafeWebhookURL))
+		return
+	}
+
+	if err := j.store.MarkDelivered(ctx, delivery, code, time.Now()); err != nil {
+		log.Println(err, "Error saving webhook delivery ", delivery.ID)
+	}
+}
+
+// fail schedules the next attempt, or dead-letters the delivery once it is
+// out of attempts or final
+func (j *webhookJob) fail(ctx context.Context, delivery *model.WebhookDelivery, code int, reason string, final bool) {
+	var next *time.Time
+	if !final && delivery.Attempts+1 < conf.WebhookMaxAttempts {
+		at := time.Now().Add(webhookBackoff(delivery.Attempts + 1))
+		next = &at
+	}
+	if err := j.store.MarkFailed(ctx, delivery, code, reason, next); err != nil {
+		log.Println(err, "Error saving webhook delivery ", delivery.ID)
+		return
+	}
+	if next == nil {
+		log.Printf("webhook delivery %d dead after %d attempts: %s\n", delivery.ID, delivery.Attempts+1, reason)
+	}
+}
+
+// webhookBackoff doubles the wait after every failed attempt
+func webhookBackoff(attempts int) time.Duration {
+	backoff := conf.WebhookRetryBase
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/model"
+	"cryptoshare/service"
+	"cryptoshare/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memWebhookStore records what the job saves
+type memWebhookStore struct {
+	delivered []*model.WebhookDelivery
+	failed    []*model.WebhookDelivery
+	next      []*time.Time
+}
+
+func (s *memWebhookStore) ListDue(ctx context.Context, now time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (s *memWebhookStore) Claim(ctx context.Context, delivery *model.WebhookDelivery, until time.Time) (bool, error) {
+	return true, nil
+}
+
+func (s *memWebhookStore) MarkDelivered(ctx context.Context, delivery *model.WebhookDelivery, code int, at time.Time) error {
+	delivery.ResponseCode = code
+	s.delivered = append(s.delivered, delivery)
+	return nil
+}
+
+func (s *memWebhookStore) MarkFailed(ctx context.Context, delivery *model.WebhookDelivery, code int, reason string, next *time.Time) error {
+	delivery.ResponseCode = code
+	delivery.LastError = reason
+	s.failed = append(s.failed, delivery)
+	s.next = append(s.next, next)
+	return nil
+}
+
+func newTestDelivery(url string, attempts int) *model.WebhookDelivery {
+	return &model.WebhookDelivery{
+		ID:       1,
+		EventID:  uuid.New(),
+		Event:    model.EventDepositConfirmed,
+		Payload:  `{"amount":1}`,
+		Attempts: attempts,
+		Endpoint: &model.WebhookEndpoint{URL: url, Active: true},
+	}
+}
+
+func TestWebhookJobSignsDelivery(t *testing.T) {
+	const secret = "whsec_test"
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	store := &memWebhookStore{}
+	job := &webhookJob{store: store, sender: service.NewWebhookSender(srv.Client())}
+	delivery := newTestDelivery(srv.URL, 0)
+	job.send(context.Background(), delivery, secret)
+
+	if len(store.delivered) != 1 || len(store.failed) != 0 {
+		t.Fatalf("delivery not marked delivered: %+v", delivery)
+	}
+	if got.Header.Get(service.WebhookIDHeader) != delivery.EventID.String() ||
+		got.Header.Get(service.WebhookEventHeader) != delivery.Event {
+		t.Fatalf("missing event headers: %v", got.Header)
+	}
+	timestamp := got.Header.Get(service.WebhookTimestampHeader)
+	want := utils.SignWebhook(secret, timestamp, []byte(delivery.Payload))
+	if string(body) != delivery.Payload || !utils.EqualSignature(got.Header.Get(service.WebhookSignatureHeader), want) {
+		t.Fatalf("signature does not match the body")
+	}
+}
+
+func TestWebhookJobRetriesServerErrors(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	store := &memWebhookStore{}
+	job := &webhookJob{store: store, sender: service.NewWebhookSender(srv.Client())}
+
+	delivery := newTestDelivery(srv.URL, 0)
+	job.send(context.Background(), delivery, "secret")
+	if len(store.failed) != 1 || store.next[0] == nil {
+		t.Fatal("a 5xx should schedule another attempt")
+	}
+	if delivery.ResponseCode != http.StatusServiceUnavailable {
+		t.Fatalf("response code %d not recorded", delivery.ResponseCode)
+	}
+	if wait := time.Until(*store.next[0]); wait <= 0 || wait > conf.WebhookRetryBase {
+		t.Fatalf("next attempt in %v, want within %v", wait, conf.WebhookRetryBase)
+	}
+
+	// the last attempt dead-letters it
+	last := newTestDelivery(srv.URL, conf.WebhookMaxAttempts-1)
+	job.send(context.Background(), last, "secret")
+	if len(store.failed) != 2 || store.next[1] != nil {
+		t.Fatal("the last failed attempt should dead-letter the delivery")
+	}
+}
+
+func TestWebhookJobDeadLetters(t *testing.T) {
+	store := &memWebhookStore{}
+	job := &webhookJob{store: store, sender: service.NewWebhookSender(nil)}
+
+	inactive := newTestDelivery("https://8.8.8.8/hook", 0)
+	inactive.Endpoint.Active = false
+	job.deliver(context.Background(), inactive)
+
+	// saved before urls were checked, it will never be sent
+	insecure := newTestDelivery("http://8.8.8.8/hook", 0)
+	job.send(context.Background(), insecure, "secret")
+
+	if len(store.failed) != 2 || store.next[0] != nil || store.next[1] != nil {
+		t.Fatalf("deliveries should be dead at once, next attempts %v", store.next)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	if webhookBackoff(1) != conf.WebhookRetryBase || webhookBackoff(3) != 4*conf.WebhookRetryBase {
+		t.Fatal("backoff should double after every attempt")
+	}
+	if webhookBackoff(100) != webhookMaxBackoff {
+		t.Fatal("backoff should be capped")
+	}
+}
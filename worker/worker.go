@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"cryptoshare/conf"
+	"cryptoshare/payment"
+	"cryptoshare/repository"
+	"cryptoshare/service"
+	"log"
+	"time"
+)
+
+type Worker struct {
+	repo *repository.Repository
+	svc  *service.Service
+	pay  *payment.Payments
+}
+
+type WConfig struct {
+	Repo *repository.Repository
+	Svc  *service.Service
+}
+
+func NewWorker(c *WConfig) *Worker {
+	return &Worker{
+		repo: c.Repo,
+		svc:  c.Svc,
+		pay:  payment.New(c.Repo, c.Svc),
+	}
+}
+
+// Start runs every job in the background until ctx is done
+func (w *Worker) Start(ctx context.Context) {
+	// balance snapshots for portfolio history
+	snapshotJob := newSnapshotJob(w)
+	go every(ctx, "snapshot", conf.SnapshotInterval, snapshotJob.run)
+
+	// incoming transfers and invoice payments
+	depositJob := newDepositJob(w)
+	go every(ctx, "deposit", conf.DepositScanInterval, depositJob.run)
+
+	// outbound webhook deliveries
+	webhookJob := newWebhookJob(w)
+	go every(ctx, "webhook", conf.WebhookInterval, webhookJob.run)
+
+	// scheduled and recurring transfers
+	scheduleJob := newScheduleJob(w)
+	go every(ctx, "schedule", conf.ScheduleInterval, scheduleJob.run)
+
+	// payout batches whose server stopped while executing
+	payoutJob := newPayoutJob(w)
+	go every(ctx, "payout", conf.PayoutResumeInterval, payoutJob.run)
+
+	// four-eyes proposals past their expiry
+	proposalJob := newProposalJob(w)
+	go every(ctx, "proposal", conf.ProposalExpireInterval, proposalJob.run)
+}
+
+// every runs job right away and then once per interval
+func every(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("%s job started, runs every %s\n", name, interval)
+	for {
+		job(ctx)
+		select {
+		case <-ctx.Done():
+			log.Printf("%s job stopped\n", name)
+			return
+		case <-ticker.C:
+		}
+	}
+}